@@ -0,0 +1,158 @@
+// Package bridge connects a chat platform to the Ping server. A platform only
+// implements Bridge, the Runner does the Ping side: the shared connection,
+// relaying inbound messages and the supervised subscription.
+package bridge
+
+import (
+	"context"
+	"strings"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// Event is what happened to a platform message.
+type Event int
+
+const (
+	EventMessage  Event = iota // A new message
+	EventEdit                  // Message ID was edited, Content is the new content
+	EventDelete                // Message ID was deleted
+	EventReaction              // Count users reacted with Emoji to message ID now
+)
+
+// Message is a platform message on its way to Ping.
+type Message struct {
+	Event     Event
+	ID        string // ID of the message on the platform, unique per platform
+	Author    string // Display name on the platform
+	Recipient string // Ping room or client, not needed for edits and deletions
+	Content   string
+
+	// For edits, tells edits of the same message apart, e.g. the time of the
+	// edit on the platform
+	Version string
+
+	// Files sent with a new message, uploaded to Ping by the Runner
+	Attachments []Attachment
+
+	// The message a new message replies to, if any
+	ReplyTo *Reply
+
+	// For reactions, a NormalizeEmoji emoji and how many users reacted with
+	// it, without the bridge's own reactions
+	Emoji string
+	Count int
+}
+
+// Reply is the message a platform message replies to.
+type Reply struct {
+	ID      string // ID of the parent on the platform
+	Author  string // Display name of the parent's author, if known
+	Excerpt string // Content of the parent, if known
+}
+
+// Attachment is a file sent with a message.
+type Attachment struct {
+	Name        string
+	ContentType string // MIME type, e.g. "image/png"
+	Data        []byte
+}
+
+// Quote returns a line quoting the message msg replies to, to put before it
+// on platforms that can't reply natively. It is empty if msg is no reply.
+func Quote(msg *ping.MessageResponse) string {
+	reply := msg.GetReplyTo()
+	if reply == nil {
+		return ""
+	}
+	quote := "> "
+	if reply.GetAuthor() != "" {
+		quote += reply.GetAuthor() + ": "
+	}
+	return quote + strings.ReplaceAll(reply.GetExcerpt(), "\n", " ") + "\n"
+}
+
+// WithNote appends a mention of an attachment that could not be relayed to
+// content.
+func WithNote(content, name string) string {
+	note := "[attachment: " + name + "]"
+	if content == "" {
+		return note
+	}
+	return content + "\n" + note
+}
+
+// NormalizeEmoji spells a Unicode emoji the way Ping relays reactions:
+// without variation selectors, which platforms use inconsistently, and
+// without skin tones, which not every platform has.
+func NormalizeEmoji(emoji string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\uFE0E' || r == '\uFE0F' || (r >= 0x1F3FB && r <= 0x1F3FF) {
+			return -1
+		}
+		return r
+	}, emoji)
+}
+
+// Bridge is the platform specific half of a bridge.
+type Bridge interface {
+	// Start connects to the platform and returns once messages are being
+	// passed to the OnInbound handler.
+	Start(ctx context.Context) error
+
+	// Send posts a message from Ping to the platform. It returns the IDs of
+	// the posted copies, which edits and deletions refer to, see Editor and
+	// Deleter. Bridges that don't support either may return none.
+	//
+	// msg.ReplyTo.Copies lists only the parent's IDs on this bridge, to reply
+	// to natively. Without a suitable one, reply with a Quote.
+	Send(msg *ping.MessageResponse) ([]string, error)
+
+	// OnInbound sets the handler platform messages are passed to. It is
+	// called before Start.
+	OnInbound(handler func(Message))
+}
+
+// RoomBridge is implemented by bridges that only want messages from some
+// rooms, e.g. the ones in their channel map.
+type RoomBridge interface {
+	Bridge
+
+	// Rooms returns the rooms to subscribe to. It is called on every
+	// (re)connect, so it may change, see Runner.Resubscribe.
+	Rooms() []string
+}
+
+// Editor is implemented by bridges that pass edits of Ping messages on to
+// the copies they posted.
+type Editor interface {
+	// Edit replaces the content of the copy with ID copyID, as returned by
+	// Send, with the edited msg.
+	Edit(copyID string, msg *ping.MessageResponse) error
+}
+
+// Deleter is implemented by bridges that pass deletions of Ping messages on
+// to the copies they posted.
+type Deleter interface {
+	// Delete removes the copy with ID copyID, as returned by Send, of the
+	// deleted msg. It fails if the bridge may not delete it.
+	Delete(copyID string, msg *ping.MessageResponse) error
+}
+
+// AttachmentSender is implemented by bridges that post the files sent with
+// Ping messages. Other bridges get the file names appended to the content.
+type AttachmentSender interface {
+	// SendWithAttachments is Send for messages with attachments, which the
+	// Runner downloaded from Ping, in the order of msg.Attachments.
+	SendWithAttachments(msg *ping.MessageResponse, attachments []Attachment) ([]string, error)
+}
+
+// Reactor is implemented by bridges that mirror reactions made elsewhere, on
+// the copies they posted and on the messages they relayed to Ping.
+type Reactor interface {
+	// React sets the bridge's own reactions to the message with ID id, a copy
+	// returned by Send or a message sent to Ping, to emoji: the reactions
+	// users made to msg elsewhere, most common first. msg.Emoji is the one
+	// that was just added or removed.
+	React(id string, emoji []string, msg *ping.MessageResponse) error
+}
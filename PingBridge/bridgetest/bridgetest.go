@@ -0,0 +1,65 @@
+// Package bridgetest helps testing bridges against stand-ins of their
+// platforms: it writes mapping files, starts bridges and collects what they
+// pass on to Ping.
+package bridgetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bridge "github.com/kallazz/Ping/PingBridge"
+)
+
+// Timeout is how long Receive waits for a message.
+const Timeout = 5 * time.Second
+
+// WriteMappings writes a mapping file, see bridge.MappingConfig, to a
+// temporary directory and returns its path.
+func WriteMappings(t testing.TB, mappings string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mappings.json")
+	if err := os.WriteFile(path, []byte(mappings), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Start starts b until the test ends and returns the messages it passes on
+// to Ping.
+func Start(t testing.TB, b bridge.Bridge) <-chan bridge.Message {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	inbound := make(chan bridge.Message, 10)
+	b.OnInbound(func(msg bridge.Message) { inbound <- msg })
+	if err := b.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return inbound
+}
+
+// Receive returns the next message the bridge passed on to Ping.
+func Receive(t testing.TB, inbound <-chan bridge.Message) bridge.Message {
+	t.Helper()
+	select {
+	case msg := <-inbound:
+		return msg
+	case <-time.After(Timeout):
+		t.Fatal("no message relayed to Ping")
+		return bridge.Message{}
+	}
+}
+
+// ExpectNothing fails if the bridge passes anything more on to Ping within a
+// moment.
+func ExpectNothing(t testing.TB, inbound <-chan bridge.Message) {
+	t.Helper()
+	select {
+	case msg := <-inbound:
+		t.Errorf("relayed %+v, want nothing more", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
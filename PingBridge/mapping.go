@@ -0,0 +1,245 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"sync/atomic"
+	"syscall"
+)
+
+// Direction says which way messages flow through a mapping.
+type Direction string
+
+const (
+	DirectionIn   Direction = "in"   // Platform -> Ping only
+	DirectionOut  Direction = "out"  // Ping -> platform only
+	DirectionBoth Direction = "both" // Both ways
+)
+
+// Mapping ties a platform channel to a Ping room and/or a message source such
+// as "Telegram".
+type Mapping struct {
+	Channel   string    `json:"channel"`
+	Room      string    `json:"room,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Direction Direction `json:"direction"`
+}
+
+// Inbound reports whether messages from the channel go to Ping.
+func (m *Mapping) Inbound() bool {
+	return m.Direction == DirectionIn || m.Direction == DirectionBoth
+}
+
+// Outbound reports whether Ping messages are posted to the channel.
+func (m *Mapping) Outbound() bool {
+	return m.Direction == DirectionOut || m.Direction == DirectionBoth
+}
+
+// matches reports whether a Ping message from source in room should be
+// posted through this mapping.
+func (m *Mapping) matches(room, source string) bool {
+	if !m.Outbound() {
+		return false
+	}
+	if m.Room != "" && m.Room != room {
+		return false
+	}
+	if m.Source != "" && m.Source != source {
+		return false
+	}
+	return true
+}
+
+// ChannelNames says how a platform names its channels in a mapping file.
+type ChannelNames struct {
+	// Key is the field holding the channel, "channel" if empty.
+	Key string
+
+	// Check, if set, validates a channel. Channels must not be empty either
+	// way.
+	Check func(channel string) error
+
+	// Fold, if set, returns the form channels are compared in, e.g. lower
+	// case.
+	Fold func(channel string) string
+}
+
+// MappingConfig is a mapping file, e.g.
+//
+//	{"mappings": [
+//	  {"channel": "123456789", "room": "general", "direction": "both"},
+//	  {"channel": "987654321", "source": "Telegram", "direction": "out"}
+//	]}
+//
+// A missing direction is both.
+type MappingConfig struct {
+	Mappings []Mapping `json:"mappings"`
+
+	names ChannelNames
+}
+
+// LoadMappings reads and validates the mapping file at path.
+func LoadMappings(path string, names ChannelNames) (*MappingConfig, error) {
+	if names.Key == "" {
+		names.Key = "channel"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file: %v", err)
+	}
+	var file struct {
+		Mappings []map[string]json.RawMessage `json:"mappings"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping file: %v", err)
+	}
+
+	config := &MappingConfig{names: names}
+	for i, fields := range file.Mappings {
+		var m Mapping
+		for key, value := range map[string]any{names.Key: &m.Channel, "room": &m.Room, "source": &m.Source, "direction": &m.Direction} {
+			if raw, ok := fields[key]; ok {
+				if err := json.Unmarshal(raw, value); err != nil {
+					return nil, fmt.Errorf("mapping %d: invalid %s: %v", i, key, err)
+				}
+			}
+		}
+
+		if m.Channel == "" {
+			return nil, fmt.Errorf("mapping %d: %s is required", i, names.Key)
+		}
+		if names.Check != nil {
+			if err := names.Check(m.Channel); err != nil {
+				return nil, fmt.Errorf("mapping %d: %v", i, err)
+			}
+		}
+		if m.Room == "" && m.Source == "" {
+			return nil, fmt.Errorf("mapping %d: room or source is required", i)
+		}
+		switch m.Direction {
+		case DirectionIn, DirectionOut, DirectionBoth:
+		case "":
+			m.Direction = DirectionBoth
+		default:
+			return nil, fmt.Errorf("mapping %d: direction must be in, out or both, not %q", i, m.Direction)
+		}
+		config.Mappings = append(config.Mappings, m)
+	}
+	return config, nil
+}
+
+// same reports whether a and b name the same channel.
+func (c *MappingConfig) same(a, b string) bool {
+	if c.names.Fold == nil {
+		return a == b
+	}
+	return c.names.Fold(a) == c.names.Fold(b)
+}
+
+// containsChannel reports whether channels has channel.
+func (c *MappingConfig) containsChannel(channels []string, channel string) bool {
+	return slices.ContainsFunc(channels, func(other string) bool {
+		return c.same(other, channel)
+	})
+}
+
+// Channels returns every channel in the config, e.g. to join them.
+func (c *MappingConfig) Channels() []string {
+	var channels []string
+	for _, m := range c.Mappings {
+		if !c.containsChannel(channels, m.Channel) {
+			channels = append(channels, m.Channel)
+		}
+	}
+	return channels
+}
+
+// Resolve returns a copy of the config with channels replaced according to
+// ids, e.g. Matrix room aliases by room IDs.
+func (c *MappingConfig) Resolve(ids map[string]string) *MappingConfig {
+	resolved := &MappingConfig{Mappings: slices.Clone(c.Mappings), names: c.names}
+	for i, m := range resolved.Mappings {
+		if id, ok := ids[m.Channel]; ok {
+			resolved.Mappings[i].Channel = id
+		}
+	}
+	return resolved
+}
+
+// InboundMappings returns the mappings that forward messages from channel.
+func (c *MappingConfig) InboundMappings(channel string) []Mapping {
+	var mappings []Mapping
+	for _, m := range c.Mappings {
+		if c.same(m.Channel, channel) && m.Inbound() {
+			mappings = append(mappings, m)
+		}
+	}
+	return mappings
+}
+
+// Mapped reports whether channel is in a mapping, either way.
+func (c *MappingConfig) Mapped(channel string) bool {
+	return slices.ContainsFunc(c.Mappings, func(m Mapping) bool {
+		return c.same(m.Channel, channel)
+	})
+}
+
+// OutboundChannels returns the channels a Ping message from source in room
+// should be posted to.
+func (c *MappingConfig) OutboundChannels(room, source string) []string {
+	var channels []string
+	for _, m := range c.Mappings {
+		if m.matches(room, source) && !c.containsChannel(channels, m.Channel) {
+			channels = append(channels, m.Channel)
+		}
+	}
+	return channels
+}
+
+// Rooms returns the rooms whose messages are posted to the platform, so the
+// bridge can subscribe to them.
+func (c *MappingConfig) Rooms() []string {
+	var rooms []string
+	for _, m := range c.Mappings {
+		if m.Room != "" && m.Outbound() && !slices.Contains(rooms, m.Room) {
+			rooms = append(rooms, m.Room)
+		}
+	}
+	slices.Sort(rooms)
+	return rooms
+}
+
+// ReloadMappings loads the mapping into config again with load on every
+// SIGHUP until ctx is done, keeping the old one if that fails. changed, if
+// set, is called with the old and the new mapping after the swap, and the
+// subscription is reopened if the rooms posted to the platform changed.
+func (r *Runner) ReloadMappings(ctx context.Context, config *atomic.Pointer[MappingConfig], load func() (*MappingConfig, error), changed func(old, config *MappingConfig)) {
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGHUP)
+	defer signal.Stop(sc)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sc:
+		}
+		mappings, err := load()
+		if err != nil {
+			fmt.Println("error reloading the mapping file, keeping the old one:", err)
+			continue
+		}
+		old := config.Swap(mappings)
+		fmt.Printf("Reloaded the mapping file with %d mappings\n", len(mappings.Mappings))
+		if changed != nil {
+			changed(old, mappings)
+		}
+
+		if old == nil || !slices.Equal(old.Rooms(), mappings.Rooms()) {
+			r.Resubscribe()
+		}
+	}
+}
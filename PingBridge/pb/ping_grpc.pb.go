@@ -0,0 +1,957 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v3.12.4
+// source: Protos/ping.proto
+
+package ping
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PingService_SendMessage_FullMethodName        = "/PingService/SendMessage"
+	PingService_ReceiveMessages_FullMethodName    = "/PingService/ReceiveMessages"
+	PingService_EditMessage_FullMethodName        = "/PingService/EditMessage"
+	PingService_AddCopy_FullMethodName            = "/PingService/AddCopy"
+	PingService_DeleteMessage_FullMethodName      = "/PingService/DeleteMessage"
+	PingService_ReactMessage_FullMethodName       = "/PingService/ReactMessage"
+	PingService_UploadBlob_FullMethodName         = "/PingService/UploadBlob"
+	PingService_DownloadBlob_FullMethodName       = "/PingService/DownloadBlob"
+	PingService_ProposeKeyExchange_FullMethodName = "/PingService/ProposeKeyExchange"
+	PingService_AcknowledgeMessage_FullMethodName = "/PingService/AcknowledgeMessage"
+	PingService_Login_FullMethodName              = "/PingService/Login"
+	PingService_Register_FullMethodName           = "/PingService/Register"
+	PingService_GetFriends_FullMethodName         = "/PingService/GetFriends"
+	PingService_AddFriend_FullMethodName          = "/PingService/AddFriend"
+	PingService_AcceptFriend_FullMethodName       = "/PingService/AcceptFriend"
+	PingService_DeclineFriend_FullMethodName      = "/PingService/DeclineFriend"
+	PingService_RemoveFriend_FullMethodName       = "/PingService/RemoveFriend"
+	PingService_BlockUser_FullMethodName          = "/PingService/BlockUser"
+	PingService_UnblockUser_FullMethodName        = "/PingService/UnblockUser"
+	PingService_CreateRoom_FullMethodName         = "/PingService/CreateRoom"
+	PingService_JoinRoom_FullMethodName           = "/PingService/JoinRoom"
+	PingService_LeaveRoom_FullMethodName          = "/PingService/LeaveRoom"
+	PingService_ListRooms_FullMethodName          = "/PingService/ListRooms"
+)
+
+// PingServiceClient is the client API for PingService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PingServiceClient interface {
+	SendMessage(ctx context.Context, in *MessageRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	ReceiveMessages(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ServerMessage], error)
+	EditMessage(ctx context.Context, in *EditRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	AddCopy(ctx context.Context, in *CopyRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	DeleteMessage(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	ReactMessage(ctx context.Context, in *ReactionRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	UploadBlob(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[BlobChunk, BlobInfo], error)
+	DownloadBlob(ctx context.Context, in *BlobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BlobChunk], error)
+	ProposeKeyExchange(ctx context.Context, in *KeyExchangeRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	AcknowledgeMessage(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	GetFriends(ctx context.Context, in *FriendListRequest, opts ...grpc.CallOption) (*FriendList, error)
+	AddFriend(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	AcceptFriend(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	DeclineFriend(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	RemoveFriend(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	BlockUser(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	UnblockUser(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	CreateRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	JoinRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	LeaveRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*ExitCode, error)
+	ListRooms(ctx context.Context, in *RoomListRequest, opts ...grpc.CallOption) (*RoomList, error)
+}
+
+type pingServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPingServiceClient(cc grpc.ClientConnInterface) PingServiceClient {
+	return &pingServiceClient{cc}
+}
+
+func (c *pingServiceClient) SendMessage(ctx context.Context, in *MessageRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_SendMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) ReceiveMessages(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ServerMessage], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PingService_ServiceDesc.Streams[0], PingService_ReceiveMessages_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Empty, ServerMessage]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PingService_ReceiveMessagesClient = grpc.ServerStreamingClient[ServerMessage]
+
+func (c *pingServiceClient) EditMessage(ctx context.Context, in *EditRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_EditMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) AddCopy(ctx context.Context, in *CopyRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_AddCopy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) DeleteMessage(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_DeleteMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) ReactMessage(ctx context.Context, in *ReactionRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_ReactMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) UploadBlob(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[BlobChunk, BlobInfo], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PingService_ServiceDesc.Streams[1], PingService_UploadBlob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BlobChunk, BlobInfo]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PingService_UploadBlobClient = grpc.ClientStreamingClient[BlobChunk, BlobInfo]
+
+func (c *pingServiceClient) DownloadBlob(ctx context.Context, in *BlobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BlobChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PingService_ServiceDesc.Streams[2], PingService_DownloadBlob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BlobRequest, BlobChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PingService_DownloadBlobClient = grpc.ServerStreamingClient[BlobChunk]
+
+func (c *pingServiceClient) ProposeKeyExchange(ctx context.Context, in *KeyExchangeRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_ProposeKeyExchange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) AcknowledgeMessage(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_AcknowledgeMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_Login_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) GetFriends(ctx context.Context, in *FriendListRequest, opts ...grpc.CallOption) (*FriendList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FriendList)
+	err := c.cc.Invoke(ctx, PingService_GetFriends_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) AddFriend(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_AddFriend_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) AcceptFriend(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_AcceptFriend_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) DeclineFriend(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_DeclineFriend_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) RemoveFriend(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_RemoveFriend_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) BlockUser(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_BlockUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) UnblockUser(ctx context.Context, in *AddFriendRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_UnblockUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) CreateRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_CreateRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) JoinRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_JoinRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) LeaveRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*ExitCode, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExitCode)
+	err := c.cc.Invoke(ctx, PingService_LeaveRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pingServiceClient) ListRooms(ctx context.Context, in *RoomListRequest, opts ...grpc.CallOption) (*RoomList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RoomList)
+	err := c.cc.Invoke(ctx, PingService_ListRooms_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PingServiceServer is the server API for PingService service.
+// All implementations must embed UnimplementedPingServiceServer
+// for forward compatibility.
+type PingServiceServer interface {
+	SendMessage(context.Context, *MessageRequest) (*ExitCode, error)
+	ReceiveMessages(*Empty, grpc.ServerStreamingServer[ServerMessage]) error
+	EditMessage(context.Context, *EditRequest) (*ExitCode, error)
+	AddCopy(context.Context, *CopyRequest) (*ExitCode, error)
+	DeleteMessage(context.Context, *DeleteRequest) (*ExitCode, error)
+	ReactMessage(context.Context, *ReactionRequest) (*ExitCode, error)
+	UploadBlob(grpc.ClientStreamingServer[BlobChunk, BlobInfo]) error
+	DownloadBlob(*BlobRequest, grpc.ServerStreamingServer[BlobChunk]) error
+	ProposeKeyExchange(context.Context, *KeyExchangeRequest) (*ExitCode, error)
+	AcknowledgeMessage(context.Context, *AckRequest) (*ExitCode, error)
+	Login(context.Context, *LoginRequest) (*ExitCode, error)
+	Register(context.Context, *RegisterRequest) (*ExitCode, error)
+	GetFriends(context.Context, *FriendListRequest) (*FriendList, error)
+	AddFriend(context.Context, *AddFriendRequest) (*ExitCode, error)
+	AcceptFriend(context.Context, *AddFriendRequest) (*ExitCode, error)
+	DeclineFriend(context.Context, *AddFriendRequest) (*ExitCode, error)
+	RemoveFriend(context.Context, *AddFriendRequest) (*ExitCode, error)
+	BlockUser(context.Context, *AddFriendRequest) (*ExitCode, error)
+	UnblockUser(context.Context, *AddFriendRequest) (*ExitCode, error)
+	CreateRoom(context.Context, *RoomRequest) (*ExitCode, error)
+	JoinRoom(context.Context, *RoomRequest) (*ExitCode, error)
+	LeaveRoom(context.Context, *RoomRequest) (*ExitCode, error)
+	ListRooms(context.Context, *RoomListRequest) (*RoomList, error)
+	mustEmbedUnimplementedPingServiceServer()
+}
+
+// UnimplementedPingServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPingServiceServer struct{}
+
+func (UnimplementedPingServiceServer) SendMessage(context.Context, *MessageRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendMessage not implemented")
+}
+func (UnimplementedPingServiceServer) ReceiveMessages(*Empty, grpc.ServerStreamingServer[ServerMessage]) error {
+	return status.Errorf(codes.Unimplemented, "method ReceiveMessages not implemented")
+}
+func (UnimplementedPingServiceServer) EditMessage(context.Context, *EditRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EditMessage not implemented")
+}
+func (UnimplementedPingServiceServer) AddCopy(context.Context, *CopyRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddCopy not implemented")
+}
+func (UnimplementedPingServiceServer) DeleteMessage(context.Context, *DeleteRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteMessage not implemented")
+}
+func (UnimplementedPingServiceServer) ReactMessage(context.Context, *ReactionRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReactMessage not implemented")
+}
+func (UnimplementedPingServiceServer) UploadBlob(grpc.ClientStreamingServer[BlobChunk, BlobInfo]) error {
+	return status.Errorf(codes.Unimplemented, "method UploadBlob not implemented")
+}
+func (UnimplementedPingServiceServer) DownloadBlob(*BlobRequest, grpc.ServerStreamingServer[BlobChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadBlob not implemented")
+}
+func (UnimplementedPingServiceServer) ProposeKeyExchange(context.Context, *KeyExchangeRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProposeKeyExchange not implemented")
+}
+func (UnimplementedPingServiceServer) AcknowledgeMessage(context.Context, *AckRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcknowledgeMessage not implemented")
+}
+func (UnimplementedPingServiceServer) Login(context.Context, *LoginRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedPingServiceServer) Register(context.Context, *RegisterRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedPingServiceServer) GetFriends(context.Context, *FriendListRequest) (*FriendList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFriends not implemented")
+}
+func (UnimplementedPingServiceServer) AddFriend(context.Context, *AddFriendRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddFriend not implemented")
+}
+func (UnimplementedPingServiceServer) AcceptFriend(context.Context, *AddFriendRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptFriend not implemented")
+}
+func (UnimplementedPingServiceServer) DeclineFriend(context.Context, *AddFriendRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeclineFriend not implemented")
+}
+func (UnimplementedPingServiceServer) RemoveFriend(context.Context, *AddFriendRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveFriend not implemented")
+}
+func (UnimplementedPingServiceServer) BlockUser(context.Context, *AddFriendRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BlockUser not implemented")
+}
+func (UnimplementedPingServiceServer) UnblockUser(context.Context, *AddFriendRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnblockUser not implemented")
+}
+func (UnimplementedPingServiceServer) CreateRoom(context.Context, *RoomRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRoom not implemented")
+}
+func (UnimplementedPingServiceServer) JoinRoom(context.Context, *RoomRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method JoinRoom not implemented")
+}
+func (UnimplementedPingServiceServer) LeaveRoom(context.Context, *RoomRequest) (*ExitCode, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LeaveRoom not implemented")
+}
+func (UnimplementedPingServiceServer) ListRooms(context.Context, *RoomListRequest) (*RoomList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRooms not implemented")
+}
+func (UnimplementedPingServiceServer) mustEmbedUnimplementedPingServiceServer() {}
+func (UnimplementedPingServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafePingServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PingServiceServer will
+// result in compilation errors.
+type UnsafePingServiceServer interface {
+	mustEmbedUnimplementedPingServiceServer()
+}
+
+func RegisterPingServiceServer(s grpc.ServiceRegistrar, srv PingServiceServer) {
+	// If the following call pancis, it indicates UnimplementedPingServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PingService_ServiceDesc, srv)
+}
+
+func _PingService_SendMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).SendMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_SendMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).SendMessage(ctx, req.(*MessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_ReceiveMessages_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PingServiceServer).ReceiveMessages(m, &grpc.GenericServerStream[Empty, ServerMessage]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PingService_ReceiveMessagesServer = grpc.ServerStreamingServer[ServerMessage]
+
+func _PingService_EditMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).EditMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_EditMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).EditMessage(ctx, req.(*EditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_AddCopy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CopyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).AddCopy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_AddCopy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).AddCopy(ctx, req.(*CopyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_DeleteMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).DeleteMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_DeleteMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).DeleteMessage(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_ReactMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).ReactMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_ReactMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).ReactMessage(ctx, req.(*ReactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_UploadBlob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PingServiceServer).UploadBlob(&grpc.GenericServerStream[BlobChunk, BlobInfo]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PingService_UploadBlobServer = grpc.ClientStreamingServer[BlobChunk, BlobInfo]
+
+func _PingService_DownloadBlob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BlobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PingServiceServer).DownloadBlob(m, &grpc.GenericServerStream[BlobRequest, BlobChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PingService_DownloadBlobServer = grpc.ServerStreamingServer[BlobChunk]
+
+func _PingService_ProposeKeyExchange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyExchangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).ProposeKeyExchange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_ProposeKeyExchange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).ProposeKeyExchange(ctx, req.(*KeyExchangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_AcknowledgeMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).AcknowledgeMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_AcknowledgeMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).AcknowledgeMessage(ctx, req.(*AckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_GetFriends_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FriendListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).GetFriends(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_GetFriends_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).GetFriends(ctx, req.(*FriendListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_AddFriend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddFriendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).AddFriend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_AddFriend_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).AddFriend(ctx, req.(*AddFriendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_AcceptFriend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddFriendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).AcceptFriend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_AcceptFriend_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).AcceptFriend(ctx, req.(*AddFriendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_DeclineFriend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddFriendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).DeclineFriend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_DeclineFriend_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).DeclineFriend(ctx, req.(*AddFriendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_RemoveFriend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddFriendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).RemoveFriend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_RemoveFriend_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).RemoveFriend(ctx, req.(*AddFriendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_BlockUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddFriendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).BlockUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_BlockUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).BlockUser(ctx, req.(*AddFriendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_UnblockUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddFriendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).UnblockUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_UnblockUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).UnblockUser(ctx, req.(*AddFriendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_CreateRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).CreateRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_CreateRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).CreateRoom(ctx, req.(*RoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_JoinRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).JoinRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_JoinRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).JoinRoom(ctx, req.(*RoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_LeaveRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).LeaveRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_LeaveRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).LeaveRoom(ctx, req.(*RoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PingService_ListRooms_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PingServiceServer).ListRooms(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PingService_ListRooms_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PingServiceServer).ListRooms(ctx, req.(*RoomListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PingService_ServiceDesc is the grpc.ServiceDesc for PingService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PingService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "PingService",
+	HandlerType: (*PingServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendMessage",
+			Handler:    _PingService_SendMessage_Handler,
+		},
+		{
+			MethodName: "EditMessage",
+			Handler:    _PingService_EditMessage_Handler,
+		},
+		{
+			MethodName: "AddCopy",
+			Handler:    _PingService_AddCopy_Handler,
+		},
+		{
+			MethodName: "DeleteMessage",
+			Handler:    _PingService_DeleteMessage_Handler,
+		},
+		{
+			MethodName: "ReactMessage",
+			Handler:    _PingService_ReactMessage_Handler,
+		},
+		{
+			MethodName: "ProposeKeyExchange",
+			Handler:    _PingService_ProposeKeyExchange_Handler,
+		},
+		{
+			MethodName: "AcknowledgeMessage",
+			Handler:    _PingService_AcknowledgeMessage_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _PingService_Login_Handler,
+		},
+		{
+			MethodName: "Register",
+			Handler:    _PingService_Register_Handler,
+		},
+		{
+			MethodName: "GetFriends",
+			Handler:    _PingService_GetFriends_Handler,
+		},
+		{
+			MethodName: "AddFriend",
+			Handler:    _PingService_AddFriend_Handler,
+		},
+		{
+			MethodName: "AcceptFriend",
+			Handler:    _PingService_AcceptFriend_Handler,
+		},
+		{
+			MethodName: "DeclineFriend",
+			Handler:    _PingService_DeclineFriend_Handler,
+		},
+		{
+			MethodName: "RemoveFriend",
+			Handler:    _PingService_RemoveFriend_Handler,
+		},
+		{
+			MethodName: "BlockUser",
+			Handler:    _PingService_BlockUser_Handler,
+		},
+		{
+			MethodName: "UnblockUser",
+			Handler:    _PingService_UnblockUser_Handler,
+		},
+		{
+			MethodName: "CreateRoom",
+			Handler:    _PingService_CreateRoom_Handler,
+		},
+		{
+			MethodName: "JoinRoom",
+			Handler:    _PingService_JoinRoom_Handler,
+		},
+		{
+			MethodName: "LeaveRoom",
+			Handler:    _PingService_LeaveRoom_Handler,
+		},
+		{
+			MethodName: "ListRooms",
+			Handler:    _PingService_ListRooms_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReceiveMessages",
+			Handler:       _PingService_ReceiveMessages_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "UploadBlob",
+			Handler:       _PingService_UploadBlob_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadBlob",
+			Handler:       _PingService_DownloadBlob_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "Protos/ping.proto",
+}
@@ -0,0 +1,279 @@
+// Package pingclient keeps one long-lived connection to the Ping server for
+// the whole bridge, instead of dialing for every message.
+package pingclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+)
+
+// Keepalive pings find dead connections while the bridge is idle. The server
+// has to permit pings this often, see its keepalive enforcement policy.
+var Keepalive = keepalive.ClientParameters{
+	Time:                30 * time.Second,
+	Timeout:             10 * time.Second,
+	PermitWithoutStream: true,
+}
+
+// SendTimeout bounds a single SendMessage call.
+const SendTimeout = 5 * time.Second
+
+// BlobTimeout bounds uploading or downloading a single attachment.
+const BlobTimeout = time.Minute
+
+// blobChunkSize is how much of an attachment is uploaded at once, well below
+// the gRPC message size limit.
+const blobChunkSize = 64 * 1024
+
+// Client is a PingServiceClient over a shared connection. gRPC reconnects the
+// connection by itself, so one Client lives as long as the process.
+type Client struct {
+	ping.PingServiceClient
+
+	// BridgeID tells this instance of the bridge apart from others of the
+	// same platform. It is sent as the origin of every message, and messages
+	// with it are not received back.
+	BridgeID string
+
+	// Secret is the server's BRIDGE_SECRET, sent with every call. The server
+	// only takes messages from bridges with it, and lets them receive the
+	// rooms they subscribe to without being a member.
+	Secret string
+
+	conn   *grpc.ClientConn
+	client string // Platform name sent as MessageRequest.Client
+}
+
+// New connects to the Ping server at address. client is the platform name
+// messages are sent as, e.g. "Discord".
+func New(address, client string) (*Client, error) {
+	c := &Client{BridgeID: newBridgeID(), client: client}
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(Keepalive),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(c.withSecret(ctx), method, req, reply, cc, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(c.withSecret(ctx), desc, cc, method, opts...)
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect with server: %v", err)
+	}
+	c.PingServiceClient = ping.NewPingServiceClient(conn)
+	c.conn = conn
+	conn.Connect()
+	go c.monitor()
+	return c, nil
+}
+
+// SecretKey is the gRPC metadata entry carrying the Secret.
+const SecretKey = "bridge-secret"
+
+// withSecret adds the Secret, if there is one, to the metadata of a call.
+func (c *Client) withSecret(ctx context.Context) context.Context {
+	if c.Secret == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, SecretKey, c.Secret)
+}
+
+// FromEnv connects to the server at HOST:PORT. BRIDGE_ID, if set, is used as
+// the BridgeID, which is random otherwise, and BRIDGE_SECRET as the Secret.
+func FromEnv(client string) (*Client, error) {
+	host, port := os.Getenv("HOST"), os.Getenv("PORT")
+	if port == "" {
+		return nil, errors.New("PORT not set")
+	}
+	c, err := New(fmt.Sprintf("%s:%s", host, port), client)
+	if err != nil {
+		return nil, err
+	}
+	if id := os.Getenv("BRIDGE_ID"); id != "" {
+		c.BridgeID = id
+	}
+	c.Secret = os.Getenv("BRIDGE_SECRET")
+	return c, nil
+}
+
+// Send relays a chat message over the shared connection. The client and the
+// origin's platform and bridge ID are filled in.
+func (c *Client) Send(req *ping.MessageRequest) (*ping.ExitCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), SendTimeout)
+	defer cancel()
+
+	req.Client = c.client
+	if req.Origin == nil {
+		req.Origin = &ping.Origin{}
+	}
+	req.Origin.Platform = c.client
+	req.Origin.BridgeId = c.BridgeID
+	r, err := c.SendMessage(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %v", err)
+	}
+	return r, nil
+}
+
+// Edit relays an edit of the platform message messageID, which was sent to
+// Ping before, over the shared connection. version tells edits of the
+// message apart, see ping.EditRequest.
+func (c *Client) Edit(messageID, version, content string) (*ping.ExitCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), SendTimeout)
+	defer cancel()
+
+	r, err := c.EditMessage(ctx, &ping.EditRequest{
+		Client:  c.client,
+		Origin:  &ping.Origin{Platform: c.client, BridgeId: c.BridgeID, MessageId: messageID},
+		Message: content,
+		Version: version,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send edit: %v", err)
+	}
+	return r, nil
+}
+
+// Delete relays the deletion of the platform message messageID, which was
+// sent to Ping before, over the shared connection.
+func (c *Client) Delete(messageID string) (*ping.ExitCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), SendTimeout)
+	defer cancel()
+
+	r, err := c.DeleteMessage(ctx, &ping.DeleteRequest{
+		Client: c.client,
+		Origin: &ping.Origin{Platform: c.client, BridgeId: c.BridgeID, MessageId: messageID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send deletion: %v", err)
+	}
+	return r, nil
+}
+
+// React reports that count users reacted with emoji to the platform message
+// messageID, a message sent to Ping or a copy of one, over the shared
+// connection.
+func (c *Client) React(messageID, emoji string, count int) (*ping.ExitCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), SendTimeout)
+	defer cancel()
+
+	r, err := c.ReactMessage(ctx, &ping.ReactionRequest{
+		Client: c.client,
+		Origin: &ping.Origin{Platform: c.client, BridgeId: c.BridgeID, MessageId: messageID},
+		Emoji:  emoji,
+		Count:  uint32(count),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send reaction: %v", err)
+	}
+	return r, nil
+}
+
+// AddCopy tells the server that this bridge posted Ping message id as the
+// platform message copyID, so edits and deletions of it reach the copy.
+func (c *Client) AddCopy(id uint64, copyID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), SendTimeout)
+	defer cancel()
+
+	_, err := c.PingServiceClient.AddCopy(ctx, &ping.CopyRequest{
+		Client:    c.client,
+		MessageId: id,
+		Copy:      &ping.Origin{Platform: c.client, BridgeId: c.BridgeID, MessageId: copyID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to report copy of message %d: %v", id, err)
+	}
+	return nil
+}
+
+// Upload stores an attachment on the server and returns its blob ID.
+func (c *Client) Upload(data []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), BlobTimeout)
+	defer cancel()
+
+	stream, err := c.UploadBlob(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload attachment: %v", err)
+	}
+	// Empty files still need a chunk with the client
+	for first := true; first || len(data) > 0; first = false {
+		n := min(len(data), blobChunkSize)
+		if err := stream.Send(&ping.BlobChunk{Client: c.client, Data: data[:n]}); err != nil {
+			break // The server closed the stream, CloseAndRecv says why
+		}
+		data = data[n:]
+	}
+	info, err := stream.CloseAndRecv()
+	if err != nil {
+		return "", fmt.Errorf("failed to upload attachment: %v", err)
+	}
+	if info.GetExitCode().GetStatus() != 1 {
+		return "", fmt.Errorf("failed to upload attachment: %s", info.GetExitCode().GetMessage())
+	}
+	return info.BlobId, nil
+}
+
+// Download fetches the content of an attachment.
+func (c *Client) Download(blobID string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), BlobTimeout)
+	defer cancel()
+
+	stream, err := c.DownloadBlob(ctx, &ping.BlobRequest{Client: c.client, BlobId: blobID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download attachment %s: %v", blobID, err)
+	}
+	var data []byte
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to download attachment %s: %v", blobID, err)
+		}
+		data = append(data, chunk.Data...)
+	}
+}
+
+// State returns the current state of the connection.
+func (c *Client) State() connectivity.State {
+	return c.conn.GetState()
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// newBridgeID returns a random ID for this process.
+func newBridgeID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// monitor logs connection state changes until the connection is closed.
+func (c *Client) monitor() {
+	state := c.conn.GetState()
+	for state != connectivity.Shutdown {
+		if !c.conn.WaitForStateChange(context.Background(), state) {
+			return
+		}
+		state = c.conn.GetState()
+		fmt.Println("Ping server connection:", state)
+	}
+}
@@ -0,0 +1,303 @@
+package pingclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// Backoff is how long a subscription waits before reconnecting. The delay
+// grows by Multiplier per failed attempt up to Max, and up to Jitter of it is
+// taken off at random, so bridges don't all reconnect at once.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64 // 0 to 1
+}
+
+var DefaultBackoff = Backoff{
+	Initial:    time.Second,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.5,
+}
+
+// Delay returns the delay before reconnect attempt n, counting from 0.
+func (b Backoff) Delay(n int) time.Duration {
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(n))
+	if d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	d -= d * b.Jitter * rand.Float64()
+	return time.Duration(d)
+}
+
+// Health is the state of a subscription, for monitoring.
+type Health struct {
+	Connected   bool      `json:"connected"`
+	Since       time.Time `json:"since"` // When Connected last changed
+	LastMessage time.Time `json:"last_message"`
+	Cursor      uint64    `json:"cursor"`
+	Reconnects  int       `json:"reconnects"`
+	LastError   string    `json:"last_error,omitempty"`
+	Connection  string    `json:"connection"` // gRPC connection state
+}
+
+// errResubscribe ends a stream that was closed by Resubscribe.
+var errResubscribe = errors.New("resubscribing")
+
+// Subscription is a ReceiveMessages stream that is reopened whenever it
+// breaks. It resumes from the cursor of the last message handled, so the
+// server replays what was missed, and messages it has already handled are
+// skipped. A new subscription starts at the newest message on the server,
+// and with a state file it resumes after restarts too, see LoadState.
+type Subscription struct {
+	Backoff Backoff
+
+	client      *Client
+	name        string
+	rooms       func() []string
+	handle      func(*ping.ServerMessage)
+	resubscribe chan struct{}
+	stateFile   string // See LoadState
+
+	mu     sync.Mutex
+	health Health
+}
+
+// state is what a subscription keeps in its state file across restarts.
+type state struct {
+	BridgeID string `json:"bridge_id"`
+	Cursor   uint64 `json:"cursor"`
+}
+
+// Subscribe prepares a subscription as client name, e.g. "DiscordBot". rooms
+// is called on every (re)connect, it may be nil. handle is called for each
+// message, one at a time. Nothing is received until Run is called.
+func (c *Client) Subscribe(name string, rooms func() []string, handle func(*ping.ServerMessage)) *Subscription {
+	return &Subscription{
+		Backoff:     DefaultBackoff,
+		client:      c,
+		name:        name,
+		rooms:       rooms,
+		handle:      handle,
+		resubscribe: make(chan struct{}, 1),
+		health:      Health{Since: time.Now()},
+	}
+}
+
+// Run receives messages until ctx is done, reconnecting with backoff.
+func (s *Subscription) Run(ctx context.Context) error {
+	attempt := 0
+	for {
+		start := time.Now()
+		delivered, err := s.receive(ctx)
+		if ctx.Err() != nil {
+			s.setConnected(false, ctx.Err())
+			return ctx.Err()
+		}
+		if errors.Is(err, errResubscribe) {
+			fmt.Printf("%s: resubscribing\n", s.name)
+			attempt = 0
+			continue
+		}
+		s.setConnected(false, err)
+
+		// A stream that worked for a while starts the backoff over
+		if delivered || time.Since(start) > s.Backoff.Max {
+			attempt = 0
+		}
+		delay := s.Backoff.Delay(attempt)
+		attempt++
+		fmt.Printf("%s: %v, reconnecting in %v\n", s.name, err, delay.Round(time.Millisecond))
+
+		select {
+		case <-time.After(delay):
+		case <-s.resubscribe:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		s.mu.Lock()
+		s.health.Reconnects++
+		s.mu.Unlock()
+	}
+}
+
+// LoadState resumes from the cursor saved in path by an earlier run, and
+// keeps saving it there. The bridge ID is taken over too, unless BRIDGE_ID
+// pins it, so the replay after a restart leaves out what this bridge relayed.
+// A missing file starts a new state. Call it before Run.
+func (s *Subscription) LoadState(path string) error {
+	var saved state
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read subscription state: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("failed to decode subscription state %s: %v", path, err)
+		}
+	}
+	if saved.BridgeID != "" && os.Getenv("BRIDGE_ID") == "" {
+		s.client.BridgeID = saved.BridgeID
+	}
+
+	s.mu.Lock()
+	s.stateFile = path
+	s.health.Cursor = saved.Cursor
+	s.mu.Unlock()
+	return s.saveState(saved.Cursor)
+}
+
+// saveState writes the state file, if there is one. It is replaced at once,
+// so a crash leaves the old state.
+func (s *Subscription) saveState(cursor uint64) error {
+	if s.stateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(state{BridgeID: s.client.BridgeID, Cursor: cursor})
+	if err != nil {
+		return err
+	}
+	tmp := s.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save subscription state: %v", err)
+	}
+	if err := os.Rename(tmp, s.stateFile); err != nil {
+		return fmt.Errorf("failed to save subscription state: %v", err)
+	}
+	return nil
+}
+
+// Resubscribe reopens the stream right away, e.g. after the rooms changed.
+func (s *Subscription) Resubscribe() {
+	select {
+	case s.resubscribe <- struct{}{}:
+	default:
+	}
+}
+
+// Health returns the current state of the subscription.
+func (s *Subscription) Health() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	health := s.health
+	health.Connection = s.client.State().String()
+	return health
+}
+
+// ServeHTTP reports the subscription's health as JSON, with status 503 while
+// it is disconnected.
+func (s *Subscription) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	health := s.Health()
+	w.Header().Set("Content-Type", "application/json")
+	if !health.Connected {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
+
+// ServeHealth serves the health endpoint on addr, e.g. ":8080", in the
+// background.
+func (s *Subscription) ServeHealth(addr string) {
+	go func() {
+		if err := http.ListenAndServe(addr, s); err != nil {
+			fmt.Println("health endpoint stopped:", err)
+		}
+	}()
+}
+
+// receive handles messages from one stream until it breaks. It reports
+// whether any message was handled.
+func (s *Subscription) receive(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req := &ping.Empty{Client: s.name, Cursor: s.cursor(), BridgeId: s.client.BridgeID}
+	if s.rooms != nil {
+		req.Rooms = s.rooms()
+	}
+	stream, err := s.client.ReceiveMessages(ctx, req)
+	if err != nil {
+		return false, err
+	}
+	s.setConnected(true, nil)
+
+	var resubscribed atomic.Bool
+	go func() {
+		select {
+		case <-s.resubscribe:
+			resubscribed.Store(true)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	delivered := false
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if resubscribed.Load() {
+				return delivered, errResubscribe
+			}
+			return delivered, err
+		}
+
+		// Replayed messages overlapping what was already handled
+		cursor := msg.GetCursor()
+		if cursor != 0 && cursor <= s.cursor() {
+			continue
+		}
+		// Streams opened without a cursor start with one, and nothing else
+		if msg.GetMessageResponse() != nil {
+			s.handle(msg)
+			delivered = true
+		}
+		s.advance(cursor, msg.GetMessageResponse() != nil)
+	}
+}
+
+// advance moves the cursor past a handled message and saves it.
+func (s *Subscription) advance(cursor uint64, handled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if handled {
+		s.health.LastMessage = time.Now()
+	}
+	if cursor <= s.health.Cursor {
+		return
+	}
+	s.health.Cursor = cursor
+	if err := s.saveState(cursor); err != nil {
+		fmt.Printf("%s: %v\n", s.name, err)
+	}
+}
+
+func (s *Subscription) cursor() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.health.Cursor
+}
+
+func (s *Subscription) setConnected(connected bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.health.Connected != connected {
+		s.health.Since = time.Now()
+	}
+	s.health.Connected = connected
+	if err != nil {
+		s.health.LastError = err.Error()
+	}
+}
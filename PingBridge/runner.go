@@ -0,0 +1,334 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/PingBridge/pingclient"
+)
+
+// Runner runs a Bridge against the Ping server.
+type Runner struct {
+	// Name is the platform, e.g. "Discord". Messages are sent to Ping as it.
+	Name string
+
+	// HealthAddr, if set, is where the subscription's health is served.
+	HealthAddr string
+
+	// StateFile, if set, keeps the subscription's cursor across restarts, so
+	// messages sent while the bridge was down are relayed once it is back.
+	StateFile string
+
+	bridge       Bridge
+	client       *pingclient.Client
+	subscription *pingclient.Subscription
+
+	// Bridges send a platform message once per recipient, its attachments
+	// are uploaded for the first and reused for the rest.
+	uploadsMu   sync.Mutex
+	uploads     map[string]uploaded // Platform message ID -> upload
+	uploadOrder []string
+}
+
+// uploaded is what upload made of a platform message.
+type uploaded struct {
+	content     string
+	attachments []*ping.Attachment
+}
+
+// Platform messages whose uploads are kept
+const maxUploads = 100
+
+// NewRunner connects to the Ping server at HOST:PORT for bridge b.
+func NewRunner(name string, b Bridge) (*Runner, error) {
+	client, err := pingclient.FromEnv(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &Runner{Name: name, bridge: b, client: client}
+
+	var rooms func() []string
+	if rb, ok := b.(RoomBridge); ok {
+		rooms = rb.Rooms
+	}
+	r.subscription = client.Subscribe(name+"Bot", rooms, r.relay)
+	return r, nil
+}
+
+// Client returns the shared connection to the Ping server.
+func (r *Runner) Client() *pingclient.Client {
+	return r.client
+}
+
+// Health returns the state of the Ping subscription.
+func (r *Runner) Health() pingclient.Health {
+	return r.subscription.Health()
+}
+
+// Resubscribe reopens the Ping subscription, e.g. after the bridge's rooms
+// changed.
+func (r *Runner) Resubscribe() {
+	r.subscription.Resubscribe()
+}
+
+// Run starts the bridge and relays messages both ways until ctx is done.
+func (r *Runner) Run(ctx context.Context) error {
+	defer r.client.Close()
+
+	if r.StateFile != "" {
+		if err := r.subscription.LoadState(r.StateFile); err != nil {
+			return err
+		}
+	}
+	r.bridge.OnInbound(r.send)
+	if err := r.bridge.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start %s bridge: %v", r.Name, err)
+	}
+	if r.HealthAddr != "" {
+		r.subscription.ServeHealth(r.HealthAddr)
+	}
+	return r.subscription.Run(ctx)
+}
+
+// send relays a platform message, edit, deletion or reaction to Ping.
+func (r *Runner) send(msg Message) {
+	var response *ping.ExitCode
+	var err error
+	switch msg.Event {
+	case EventEdit:
+		response, err = r.client.Edit(msg.ID, msg.Version, msg.Content)
+	case EventDelete:
+		response, err = r.client.Delete(msg.ID)
+	case EventReaction:
+		response, err = r.client.React(msg.ID, msg.Emoji, msg.Count)
+	default:
+		content, attachments := r.upload(msg)
+		req := &ping.MessageRequest{
+			Recipient:   msg.Recipient,
+			Message:     content,
+			Author:      msg.Author,
+			Origin:      &ping.Origin{MessageId: msg.ID},
+			Attachments: attachments,
+		}
+		if msg.ReplyTo != nil {
+			req.ReplyTo = &ping.ReplyTo{
+				MessageId: msg.ReplyTo.ID,
+				Author:    msg.ReplyTo.Author,
+				Excerpt:   msg.ReplyTo.Excerpt,
+			}
+		}
+		response, err = r.client.Send(req)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Response from ping server: %v\n", response.GetMessage())
+}
+
+// relay sends a Ping message, edit, deletion or reaction to the platform,
+// unless this bridge relayed it to Ping. Other instances of the same platform
+// do get it, and reactions are shown on the message this bridge relayed.
+func (r *Runner) relay(msg *ping.ServerMessage) {
+	response := msg.GetMessageResponse()
+	if response == nil {
+		return
+	}
+	switch response.GetEvent() {
+	case ping.Event_EVENT_REACTION_ADD, ping.Event_EVENT_REACTION_REMOVE:
+		r.react(response)
+		return
+	}
+	if origin := response.GetOrigin(); origin != nil && origin.GetBridgeId() == r.client.BridgeID {
+		return
+	}
+	if response.GetOrigin() == nil && response.GetType() == r.Name {
+		return // Sent by something that doesn't set an origin
+	}
+
+	// Only the parent's IDs on this bridge can be replied to, see Bridge.Send
+	if reply := response.GetReplyTo(); reply != nil {
+		var own []*ping.Origin
+		for _, copy := range reply.GetCopies() {
+			if copy.GetBridgeId() == r.client.BridgeID {
+				own = append(own, copy)
+			}
+		}
+		reply.Copies = own
+	}
+
+	switch response.GetEvent() {
+	case ping.Event_EVENT_EDIT:
+		r.edit(response)
+		return
+	case ping.Event_EVENT_DELETE:
+		r.delete(response)
+		return
+	}
+
+	var copies []string
+	var err error
+	if sender, ok := r.bridge.(AttachmentSender); ok && len(response.GetAttachments()) > 0 {
+		copies, err = sender.SendWithAttachments(response, r.download(response))
+	} else {
+		for _, a := range response.GetAttachments() {
+			response.Content = WithNote(response.Content, a.GetName())
+		}
+		copies, err = r.bridge.Send(response)
+	}
+	if err != nil {
+		fmt.Printf("failed to send message to %s: %v\n", r.Name, err)
+	}
+	for _, id := range copies {
+		if err := r.client.AddCopy(response.GetId(), id); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// upload stores the attachments of msg on the server, once per platform
+// message. Attachments that fail to upload are mentioned in the returned
+// content instead.
+func (r *Runner) upload(msg Message) (string, []*ping.Attachment) {
+	if len(msg.Attachments) == 0 {
+		return msg.Content, nil
+	}
+	r.uploadsMu.Lock()
+	u, ok := r.uploads[msg.ID]
+	r.uploadsMu.Unlock()
+	if ok {
+		return u.content, u.attachments
+	}
+
+	content := msg.Content
+	var attachments []*ping.Attachment
+	for _, a := range msg.Attachments {
+		blobID, err := r.client.Upload(a.Data)
+		if err != nil {
+			fmt.Println(err)
+			content = WithNote(content, a.Name)
+			continue
+		}
+		attachments = append(attachments, &ping.Attachment{
+			Name:        a.Name,
+			ContentType: a.ContentType,
+			BlobId:      blobID,
+		})
+	}
+
+	if msg.ID != "" {
+		r.uploadsMu.Lock()
+		if r.uploads == nil {
+			r.uploads = make(map[string]uploaded)
+		}
+		if _, ok := r.uploads[msg.ID]; !ok {
+			r.uploadOrder = append(r.uploadOrder, msg.ID)
+			if len(r.uploadOrder) > maxUploads {
+				delete(r.uploads, r.uploadOrder[0])
+				r.uploadOrder = r.uploadOrder[1:]
+			}
+		}
+		r.uploads[msg.ID] = uploaded{content, attachments}
+		r.uploadsMu.Unlock()
+	}
+	return content, attachments
+}
+
+// download fetches the attachments of a Ping message. Attachments that fail
+// to download are mentioned in its content instead.
+func (r *Runner) download(response *ping.MessageResponse) []Attachment {
+	var attachments []Attachment
+	for _, a := range response.GetAttachments() {
+		data, err := r.client.Download(a.GetBlobId())
+		if err != nil {
+			fmt.Println(err)
+			response.Content = WithNote(response.Content, a.GetName())
+			continue
+		}
+		attachments = append(attachments, Attachment{
+			Name:        a.GetName(),
+			ContentType: a.GetContentType(),
+			Data:        data,
+		})
+	}
+	return attachments
+}
+
+// edit passes an edit on to the copies this bridge instance posted.
+func (r *Runner) edit(response *ping.MessageResponse) {
+	editor, ok := r.bridge.(Editor)
+	if !ok {
+		return
+	}
+	for _, id := range r.ownCopies(response) {
+		if err := editor.Edit(id, response); err != nil {
+			fmt.Printf("failed to edit message %s on %s: %v\n", id, r.Name, err)
+		}
+	}
+}
+
+// delete passes a deletion on to the copies this bridge instance posted.
+func (r *Runner) delete(response *ping.MessageResponse) {
+	deleter, ok := r.bridge.(Deleter)
+	if !ok {
+		return
+	}
+	for _, id := range r.ownCopies(response) {
+		if err := deleter.Delete(id, response); err != nil {
+			fmt.Printf("failed to delete message %s on %s: %v\n", id, r.Name, err)
+		}
+	}
+}
+
+// react mirrors the reactions of a message on the copies this bridge instance
+// posted, and on the message itself if this instance relayed it.
+func (r *Runner) react(response *ping.MessageResponse) {
+	reactor, ok := r.bridge.(Reactor)
+	if !ok {
+		return
+	}
+	ids := r.ownCopies(response)
+	if o := response.GetOrigin(); o.GetBridgeId() == r.client.BridgeID && o.GetMessageId() != "" {
+		ids = append(ids, o.GetMessageId())
+	}
+	for _, id := range ids {
+		if err := reactor.React(id, r.reactionsElsewhere(response, id), response); err != nil {
+			fmt.Printf("failed to react to message %s on %s: %v\n", id, r.Name, err)
+		}
+	}
+}
+
+// reactionsElsewhere returns the emoji users reacted with to a message on
+// every platform message but id, most common first.
+func (r *Runner) reactionsElsewhere(response *ping.MessageResponse, id string) []string {
+	counts := make(map[string]uint32)
+	var emoji []string
+	for _, reaction := range response.GetReactions() {
+		if from := reaction.GetFrom(); from.GetPlatform() == r.Name && from.GetMessageId() == id {
+			continue
+		}
+		if _, ok := counts[reaction.GetEmoji()]; !ok {
+			emoji = append(emoji, reaction.GetEmoji())
+		}
+		counts[reaction.GetEmoji()] += reaction.GetCount()
+	}
+	sort.SliceStable(emoji, func(i, j int) bool {
+		return counts[emoji[i]] > counts[emoji[j]]
+	})
+	return emoji
+}
+
+// ownCopies returns the IDs of the copies of a changed message that this
+// bridge instance posted.
+func (r *Runner) ownCopies(response *ping.MessageResponse) []string {
+	var ids []string
+	for _, copy := range response.GetCopies() {
+		if copy.GetBridgeId() == r.client.BridgeID {
+			ids = append(ids, copy.GetMessageId())
+		}
+	}
+	return ids
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// Largest attachment relayed to Ping, larger ones are only mentioned. It
+// matches the server's default limit.
+const maxAttachmentSize = 50 << 20
+
+// Most a message posted to Discord may upload in all, the limit of servers
+// without boosts.
+const maxUploadSize = 25 << 20
+
+var attachmentClient = &http.Client{Timeout: time.Minute}
+
+// fetchAttachments downloads the files of a Discord message. Files that can't
+// be downloaded are mentioned in the returned content instead.
+func fetchAttachments(content string, files []*discordgo.MessageAttachment) (string, []bridge.Attachment) {
+	var attachments []bridge.Attachment
+	for _, file := range files {
+		data, err := fetchAttachment(file)
+		if err != nil {
+			fmt.Printf("failed to download attachment %s: %v\n", file.Filename, err)
+			content = bridge.WithNote(content, file.Filename)
+			continue
+		}
+		attachments = append(attachments, bridge.Attachment{
+			Name:        file.Filename,
+			ContentType: file.ContentType,
+			Data:        data,
+		})
+	}
+	return content, attachments
+}
+
+func fetchAttachment(file *discordgo.MessageAttachment) ([]byte, error) {
+	if file.Size > maxAttachmentSize {
+		return nil, fmt.Errorf("%d bytes is too large", file.Size)
+	}
+	resp, err := attachmentClient.Get(file.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxAttachmentSize))
+}
+
+// sendToChannel posts msg with the attachments to a channel. Files over
+// Discord's upload limit, or all of them if the upload fails, are mentioned
+// instead so the text still gets through.
+func sendToChannel(dg *discordgo.Session, channelID string, msg *ping.MessageResponse, attachments []bridge.Attachment) (*discordgo.Message, error) {
+	text, reference := outboundText(msg, channelID)
+	send := &discordgo.MessageSend{Content: text, Reference: reference}
+	size := 0
+	for _, a := range attachments {
+		if size+len(a.Data) > maxUploadSize {
+			send.Content = bridge.WithNote(send.Content, a.Name)
+			continue
+		}
+		size += len(a.Data)
+		send.Files = append(send.Files, &discordgo.File{
+			Name:        a.Name,
+			ContentType: a.ContentType,
+			Reader:      bytes.NewReader(a.Data),
+		})
+	}
+	sent, err := dg.ChannelMessageSendComplex(channelID, send)
+	if err == nil || len(send.Files) == 0 {
+		return sent, err
+	}
+
+	fmt.Printf("failed to upload files to channel %s: %v\n", channelID, err)
+	for _, file := range send.Files {
+		send.Content = bridge.WithNote(send.Content, file.Name)
+	}
+	send.Files = nil
+	return dg.ChannelMessageSendComplex(channelID, send)
+}
@@ -2,65 +2,134 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
-	"strings"
 
 	"github.com/bwmarrin/discordgo"
-	ping "github.com/kallazz/Ping/PingDiscord/pb"
-	"google.golang.org/grpc"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
 	"github.com/joho/godotenv"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 var (
 	Token string
+
+	// Path of the JSON file mapping Discord channels to Ping rooms, see
+	// bridge.MappingConfig
+	ChannelMapPath string
+
+	// Address of the health endpoint, see pingclient.Subscription
+	HealthAddr string
+
+	// Where the Ping subscription's cursor is kept, see bridge.Runner
+	StateFile string
 )
 
 func init() {
 	flag.StringVar(&Token, "t", "", "Bot Token")
+	flag.StringVar(&HealthAddr, "health", "", "Address to serve the health endpoint on, e.g. :8080")
+	flag.StringVar(&StateFile, "state", "discord.state", "File to keep the position in Ping's message history in across restarts")
 	flag.Parse()
 
     if err := godotenv.Load(); err != nil {
         fmt.Println("Error loading .env file")
         os.Exit(1)
     }
+
+	ChannelMapPath = os.Getenv("CHANNEL_MAP")
+	if ChannelMapPath == "" {
+		fmt.Println("CHANNEL_MAP not set, relaying to the first text channel of every guild")
+		return
+	}
+	config, err := bridge.LoadMappings(ChannelMapPath, bridge.ChannelNames{})
+	if err != nil {
+		fmt.Println("error loading channel map:", err)
+		os.Exit(1)
+	}
+	channelConfig.Store(config)
 }
 
-func main() {
+// channelConfig is the current channel map, swapped atomically on reload. It
+// is nil if no map is configured.
+var channelConfig atomic.Pointer[bridge.MappingConfig]
+
+// loadChannelConfig re-reads the channel map for runner.ReloadMappings.
+func loadChannelConfig() (*bridge.MappingConfig, error) {
+	if ChannelMapPath == "" {
+		return nil, errors.New("CHANNEL_MAP not set, nothing to reload")
+	}
+	return bridge.LoadMappings(ChannelMapPath, bridge.ChannelNames{})
+}
 
-	dg, err := discordgo.New("Bot " + Token)
+func main() {
+	discord, err := newDiscordBridge(Token)
 	if err != nil {
 		fmt.Println("error creating Discord session,", err)
 		return
 	}
-	dg.AddHandler(messageCreate)
-	dg.Identify.Intents = discordgo.IntentsGuildMessages
+	// Cleanly close down the Discord session.
+	defer discord.dg.Close()
 
-	// Open a websocket connection to Discord and begin listening.
-	err = dg.Open()
+	runner, err := bridge.NewRunner("Discord", discord)
 	if err != nil {
-		fmt.Println("error opening connection,", err)
+		fmt.Println("error connecting to the Ping server,", err)
 		return
 	}
+	runner.HealthAddr = HealthAddr
+	runner.StateFile = StateFile
 
-	go receiveMessagesFromPingGRPCServer(dg)
+	// Run until CTRL-C or other term signal is received.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	go runner.ReloadMappings(ctx, &channelConfig, loadChannelConfig, nil)
 
-	// Wait here until CTRL-C or other term signal is received.
 	fmt.Println("Bot is now running.  Press CTRL-C to exit.")
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-	<-sc
+	if err := runner.Run(ctx); err != nil && ctx.Err() == nil {
+		fmt.Println(err)
+	}
+}
 
-	// Cleanly close down the Discord session.
-	dg.Close()
+// discordBridge is the Discord side of the bridge.
+type discordBridge struct {
+	dg      *discordgo.Session
+	inbound func(bridge.Message)
+}
+
+func newDiscordBridge(token string) (*discordBridge, error) {
+	dg, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, err
+	}
+	b := &discordBridge{dg: dg}
+	dg.AddHandler(b.messageCreate)
+	dg.AddHandler(b.messageUpdate)
+	dg.AddHandler(b.messageDelete)
+	dg.AddHandler(b.messageReactionAdd)
+	dg.AddHandler(b.messageReactionRemove)
+	dg.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsGuildMessageReactions
+	return b, nil
+}
+
+// Start opens a websocket connection to Discord and begins listening.
+func (b *discordBridge) Start(ctx context.Context) error {
+	if err := b.dg.Open(); err != nil {
+		return fmt.Errorf("error opening connection: %v", err)
+	}
+	return nil
+}
+
+func (b *discordBridge) OnInbound(handler func(bridge.Message)) {
+	b.inbound = handler
 }
 
-func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (b *discordBridge) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	if m.Author.ID == s.State.User.ID {
 		return
 	}
@@ -78,81 +147,159 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		return
     }
 
-	response, err := sendMessageToPingGRPCServer(author.Username, m.ChannelID, m.Content)
-	if err != nil {
+	// Without a channel map every message goes out, addressed to its channel.
+	// With one, only mapped channels are forwarded, to their rooms.
+	recipients := []string{m.ChannelID}
+	if config := channelConfig.Load(); config != nil {
+		recipients = nil
+		for _, mapping := range config.InboundMappings(m.ChannelID) {
+			if mapping.Room != "" {
+				recipients = append(recipients, mapping.Room)
+			} else {
+				recipients = append(recipients, m.ChannelID)
+			}
+		}
+	}
+
+	if len(recipients) == 0 {
 		return
 	}
-	fmt.Printf("Response from ping server: %v\n", response)
+	content, attachments := fetchAttachments(m.Content, m.Attachments)
+	for _, recipient := range recipients {
+		b.inbound(bridge.Message{
+			ID:          discordMessageID(m.ChannelID, m.ID),
+			Author:      author.Username,
+			Recipient:   recipient,
+			Content:     content,
+			Attachments: attachments,
+			ReplyTo:     inboundReply(m.Message),
+		})
+	}
 }
 
-func sendMessageToPingGRPCServer(authorUsername, recipientID, message string) (string, error) {
-	host := os.Getenv("HOST")
-    port := os.Getenv("PORT")
-	address := fmt.Sprintf("%s:%s", host, port)
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return "", fmt.Errorf("failed to connect with server: %v", err)
+// messageUpdate passes edits on to Ping. Discord also sends updates when it
+// adds link previews, those have no edit timestamp.
+func (b *discordBridge) messageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	if m.Author == nil || m.Author.ID == s.State.User.ID || m.EditedTimestamp == nil {
+		return
 	}
-	defer conn.Close()
-	c := ping.NewPingServiceClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-
-	msgRequest := &ping.MessageRequest{}
-	msgRequest.Client = "Discord"
-	msgRequest.Author = authorUsername
-	msgRequest.Recipient = recipientID
-	msgRequest.Message = message
-	r, err := c.SendMessage(ctx, msgRequest)
-
-	return r.GetMessage(), nil
+	if config := channelConfig.Load(); config != nil && len(config.InboundMappings(m.ChannelID)) == 0 {
+		return
+	}
+	b.inbound(bridge.Message{
+		Event:   bridge.EventEdit,
+		ID:      discordMessageID(m.ChannelID, m.ID),
+		Content: m.Content,
+		Version: m.EditedTimestamp.Format(time.RFC3339Nano),
+	})
 }
 
-func receiveMessagesFromPingGRPCServer(dg *discordgo.Session) {
-	host := os.Getenv("HOST")
-    port := os.Getenv("PORT")
-	address := fmt.Sprintf("%s:%s", host, port)
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		fmt.Println("failed to connect with gRPC server:", err)
+// messageDelete passes deletions on to Ping. Only the IDs are known, messages
+// that were not relayed are dropped by the server.
+func (b *discordBridge) messageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
+	if m.BeforeDelete != nil && m.BeforeDelete.Author != nil && m.BeforeDelete.Author.ID == s.State.User.ID {
+		return
+	}
+	if config := channelConfig.Load(); config != nil && len(config.InboundMappings(m.ChannelID)) == 0 {
 		return
 	}
-	defer conn.Close()
+	b.inbound(bridge.Message{
+		Event: bridge.EventDelete,
+		ID:    discordMessageID(m.ChannelID, m.ID),
+	})
+}
 
-	c := ping.NewPingServiceClient(conn)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// Rooms returns the rooms to receive messages from.
+func (b *discordBridge) Rooms() []string {
+	if config := channelConfig.Load(); config != nil {
+		return config.Rooms()
+	}
+	return nil
+}
 
-	// define Empty message
+// Send posts a message from the Ping server to Discord and returns the
+// posted messages as "channel:message" IDs.
+func (b *discordBridge) Send(msg *ping.MessageResponse) ([]string, error) {
+	// Broadcast the received message to all Discord channels. Messages this
+	// bridge relayed to Ping don't come back, see bridge.Runner.
+	fmt.Println("Broadcasting message to Discord:", msg.Content)
+	copies := broadcastMessageToDiscord(b.dg, msg, nil)
 
-	connect_req := &ping.Empty{Client: "DiscordBot"}
+	// micro sleep 
+	time.Sleep(50 * time.Millisecond)
+	return copies, nil
+}
 
-	stream, err := c.ReceiveMessages(ctx, connect_req)
-	if err != nil {
-		fmt.Println("error starting gRPC stream:", err)
-		return
+// SendWithAttachments is Send for messages with files, which are uploaded
+// to Discord with the message.
+func (b *discordBridge) SendWithAttachments(msg *ping.MessageResponse, attachments []bridge.Attachment) ([]string, error) {
+	fmt.Printf("Broadcasting message with %d attachments to Discord: %s\n", len(attachments), msg.Content)
+	copies := broadcastMessageToDiscord(b.dg, msg, attachments)
+	time.Sleep(50 * time.Millisecond)
+	return copies, nil
+}
+
+// Edit updates a message the bridge posted, copyID is "channel:message".
+func (b *discordBridge) Edit(copyID string, msg *ping.MessageResponse) error {
+	channelID, messageID, ok := strings.Cut(copyID, ":")
+	if !ok {
+		return fmt.Errorf("invalid Discord message ID %q", copyID)
 	}
+	fmt.Println("Editing Discord message:", copyID)
+	text, _ := outboundText(msg, channelID)
+	_, err := b.dg.ChannelMessageEdit(channelID, messageID, text)
+	return err
+}
 
-	for {
-		msg, err := stream.Recv()
-		if err != nil {
-			fmt.Println("error receiving message from gRPC stream:", err)
-			return
+// Delete removes a message the bridge posted, copyID is "channel:message".
+// The bot may always delete its own messages, but not once it was removed
+// from the channel.
+func (b *discordBridge) Delete(copyID string, msg *ping.MessageResponse) error {
+	channelID, messageID, ok := strings.Cut(copyID, ":")
+	if !ok {
+		return fmt.Errorf("invalid Discord message ID %q", copyID)
+	}
+	fmt.Println("Deleting Discord message:", copyID)
+	err := b.dg.ChannelMessageDelete(channelID, messageID)
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		switch restErr.Message.Code {
+		case discordgo.ErrCodeUnknownMessage:
+			return nil // Deleted on Discord already
+		case discordgo.ErrCodeMissingPermissions, discordgo.ErrCodeMissingAccess:
+			return fmt.Errorf("no permission to delete messages in channel %s", channelID)
 		}
+	}
+	return err
+}
 
-		// Broadcast the received message to all Discord channels
-		// if msg is already a Discord message, you can skip this step
-		if msg.MessageResponse.Type != "Discord" && !strings.Contains(msg.MessageResponse.Content, "[Discord]") {
-			fmt.Println("Broadcasting message to Discord:", msg.MessageResponse.Content)
-			broadcastMessageToDiscord(dg, msg)
-		}
+// discordMessageID identifies a Discord message across the bridge. Messages
+// are only addressable together with their channel, so origins and copies
+// both use "channel:message".
+func discordMessageID(channelID, id string) string {
+	return channelID + ":" + id
+}
 
-		// micro sleep 
-		time.Sleep(50 * time.Millisecond)
-	}
+// formatMessage renders a Ping message as Discord text.
+func formatMessage(msg *ping.MessageResponse) string {
+	return fmt.Sprintf("[%s] %s: %s", msg.Type, msg.Sender, msg.Content)
 }
 
-func broadcastMessageToDiscord(dg *discordgo.Session, msg *ping.ServerMessage) {
+func broadcastMessageToDiscord(dg *discordgo.Session, msg *ping.MessageResponse, attachments []bridge.Attachment) []string {
+	var copies []string
+	if config := channelConfig.Load(); config != nil {
+		for _, channelID := range config.OutboundChannels(msg.Room, msg.Type) {
+			fmt.Println("Broadcasting message to channel:", channelID)
+			sent, err := sendToChannel(dg, channelID, msg, attachments)
+			if err != nil {
+				fmt.Println("error sending message to channel:", channelID, err)
+				continue
+			}
+			copies = append(copies, discordMessageID(channelID, sent.ID))
+		}
+		return copies
+	}
+
 	guilds := dg.State.Guilds
 	for _, guild := range guilds {
 		// Get the first available text channel in the guild
@@ -165,9 +312,12 @@ func broadcastMessageToDiscord(dg *discordgo.Session, msg *ping.ServerMessage) {
 		for _, channel := range channels {
 			if channel.Type == discordgo.ChannelTypeGuildText {
 				fmt.Println("Broadcasting message to channel:", channel.ID)
-				dg.ChannelMessageSend(channel.ID, fmt.Sprintf("[%s] %s: %s", msg.MessageResponse.Type, msg.MessageResponse.Sender, msg.MessageResponse.Content))
+				if sent, err := sendToChannel(dg, channel.ID, msg, attachments); err == nil {
+					copies = append(copies, discordMessageID(channel.ID, sent.ID))
+				}
 				break
 			}
 		}
 	}
+	return copies
 }
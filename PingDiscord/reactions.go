@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// Symbols that are text unless a variation selector follows them. Discord
+// names their emoji with the selector, which bridge.NormalizeEmoji drops.
+var textDefaultEmoji = map[rune]bool{
+	'#': true, '*': true, '0': true, '1': true, '2': true, '3': true, '4': true,
+	'5': true, '6': true, '7': true, '8': true, '9': true, // Keycaps
+	'©': true, '®': true, '‼': true, '⁉': true, '™': true,
+	'ℹ': true, '↔': true, '↕': true, '↩': true, '↪': true,
+	'⌨': true, '⏏': true, '⏭': true, '⏮': true, '⏯': true,
+	'⏱': true, '⏲': true, '⏸': true, '⏹': true, '⏺': true,
+	'Ⓜ': true, '▪': true, '▫': true, '▶': true, '◀': true,
+	'☀': true, '☁': true, '☂': true, '☃': true, '☄': true,
+	'☎': true, '☑': true, '☘': true, '☝': true, '☠': true,
+	'☢': true, '☣': true, '☦': true, '☪': true, '☮': true,
+	'☯': true, '☸': true, '☹': true, '☺': true, '♀': true,
+	'♂': true, '♟': true, '♠': true, '♣': true, '♥': true,
+	'♦': true, '♨': true, '♻': true, '♾': true, '⚒': true,
+	'⚔': true, '⚕': true, '⚖': true, '⚗': true, '⚙': true,
+	'⚛': true, '⚜': true, '⚠': true, '⚧': true, '⚰': true,
+	'⚱': true, '⛈': true, '⛏': true, '⛑': true, '⛓': true,
+	'⛩': true, '⛰': true, '⛱': true, '⛴': true, '⛷': true,
+	'⛸': true, '⛹': true, '✂': true, '✈': true, '✉': true,
+	'✌': true, '✍': true, '✏': true, '✒': true, '✔': true,
+	'✖': true, '✝': true, '✡': true, '✳': true, '✴': true,
+	'❄': true, '❇': true, '❣': true, '❤': true, '➡': true,
+	'⤴': true, '⤵': true, '⬅': true, '⬆': true, '⬇': true,
+	'〰': true, '〽': true, '㊗': true, '㊙': true,
+	'\U0001F321': true, '\U0001F324': true, '\U0001F325': true, '\U0001F326': true,
+	'\U0001F327': true, '\U0001F328': true, '\U0001F329': true, '\U0001F32A': true,
+	'\U0001F32B': true, '\U0001F32C': true, '\U0001F336': true, '\U0001F37D': true,
+	'\U0001F396': true, '\U0001F397': true, '\U0001F399': true, '\U0001F39A': true,
+	'\U0001F39B': true, '\U0001F39E': true, '\U0001F39F': true, '\U0001F3CB': true,
+	'\U0001F3CC': true, '\U0001F3CD': true, '\U0001F3CE': true, '\U0001F3D4': true,
+	'\U0001F3F3': true, '\U0001F3F5': true, '\U0001F3F7': true, '\U0001F43F': true,
+	'\U0001F441': true, '\U0001F4FD': true, '\U0001F549': true, '\U0001F54A': true,
+	'\U0001F56F': true, '\U0001F570': true, '\U0001F573': true, '\U0001F574': true,
+	'\U0001F575': true, '\U0001F576': true, '\U0001F577': true, '\U0001F578': true,
+	'\U0001F579': true, '\U0001F587': true, '\U0001F58A': true, '\U0001F58B': true,
+	'\U0001F58C': true, '\U0001F58D': true, '\U0001F590': true, '\U0001F5A5': true,
+	'\U0001F5A8': true, '\U0001F5B1': true, '\U0001F5B2': true, '\U0001F5BC': true,
+	'\U0001F5C2': true, '\U0001F5C3': true, '\U0001F5C4': true, '\U0001F5D1': true,
+	'\U0001F5D2': true, '\U0001F5D3': true, '\U0001F5DC': true, '\U0001F5DD': true,
+	'\U0001F5DE': true, '\U0001F5E1': true, '\U0001F5E3': true, '\U0001F5E8': true,
+	'\U0001F5EF': true, '\U0001F5F3': true, '\U0001F5FA': true, '\U0001F6CB': true,
+	'\U0001F6CD': true, '\U0001F6CE': true, '\U0001F6CF': true, '\U0001F6E0': true,
+	'\U0001F6E1': true, '\U0001F6E2': true, '\U0001F6E3': true, '\U0001F6E4': true,
+	'\U0001F6E5': true, '\U0001F6E9': true, '\U0001F6F0': true, '\U0001F6F3': true,
+}
+
+// discordEmoji spells a normalized emoji the way Discord names it.
+func discordEmoji(emoji string) string {
+	var b strings.Builder
+	for _, r := range emoji {
+		b.WriteRune(r)
+		if textDefaultEmoji[r] {
+			b.WriteRune('\uFE0F')
+		}
+	}
+	return b.String()
+}
+
+func (b *discordBridge) messageReactionAdd(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	b.reactionChanged(s, r.MessageReaction)
+}
+
+func (b *discordBridge) messageReactionRemove(s *discordgo.Session, r *discordgo.MessageReactionRemove) {
+	b.reactionChanged(s, r.MessageReaction)
+}
+
+// reactionChanged passes the number of users reacting to a message with the
+// emoji of r on to Ping. The bot's own reactions are mirrored ones and left
+// out, as are custom emoji, which other platforms don't have.
+func (b *discordBridge) reactionChanged(s *discordgo.Session, r *discordgo.MessageReaction) {
+	if r.UserID == s.State.User.ID || r.Emoji.ID != "" {
+		return
+	}
+	if config := channelConfig.Load(); config != nil && !config.Mapped(r.ChannelID) {
+		return
+	}
+
+	// Users may react with the same emoji in several skin tones, count all
+	emoji := bridge.NormalizeEmoji(r.Emoji.Name)
+	m, err := s.ChannelMessage(r.ChannelID, r.MessageID)
+	if err != nil {
+		fmt.Printf("failed to fetch reactions of message %s: %v\n", r.MessageID, err)
+		return
+	}
+	count := 0
+	for _, reaction := range m.Reactions {
+		if reaction.Emoji == nil || reaction.Emoji.ID != "" || bridge.NormalizeEmoji(reaction.Emoji.Name) != emoji {
+			continue
+		}
+		count += reaction.Count
+		if reaction.Me {
+			count--
+		}
+	}
+
+	b.inbound(bridge.Message{
+		Event: bridge.EventReaction,
+		ID:    discordMessageID(r.ChannelID, r.MessageID),
+		Emoji: emoji,
+		Count: count,
+	})
+}
+
+// React mirrors msg.Emoji on a message, id is "channel:message". The bot
+// reacts with it while users elsewhere do and takes it back after.
+func (b *discordBridge) React(id string, emoji []string, msg *ping.MessageResponse) error {
+	channelID, messageID, ok := strings.Cut(id, ":")
+	if !ok {
+		return fmt.Errorf("invalid Discord message ID %q", id)
+	}
+	name := discordEmoji(msg.GetEmoji())
+	var err error
+	if slices.Contains(emoji, msg.GetEmoji()) {
+		fmt.Printf("Reacting with %s to Discord message %s\n", name, id)
+		err = b.dg.MessageReactionAdd(channelID, messageID, name)
+	} else {
+		fmt.Printf("Removing reaction %s from Discord message %s\n", name, id)
+		err = b.dg.MessageReactionRemove(channelID, messageID, name, "@me")
+	}
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		switch restErr.Message.Code {
+		case discordgo.ErrCodeUnknownMessage:
+			return nil // Deleted on Discord
+		case discordgo.ErrCodeUnknownEmoji:
+			return fmt.Errorf("Discord has no emoji %s", name)
+		case discordgo.ErrCodeMissingPermissions, discordgo.ErrCodeMissingAccess:
+			return fmt.Errorf("no permission to react in channel %s", channelID)
+		}
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// inboundReply returns the message m replies to, if any.
+func inboundReply(m *discordgo.Message) *bridge.Reply {
+	ref := m.MessageReference
+	if m.Type != discordgo.MessageTypeReply || ref == nil {
+		return nil
+	}
+	channelID := ref.ChannelID
+	if channelID == "" {
+		channelID = m.ChannelID
+	}
+	reply := &bridge.Reply{ID: discordMessageID(channelID, ref.MessageID)}
+	// Discord only includes the parent if it still exists
+	if parent := m.ReferencedMessage; parent != nil {
+		reply.Excerpt = parent.Content
+		if parent.Author != nil {
+			reply.Author = parent.Author.Username
+		}
+	}
+	return reply
+}
+
+// outboundText renders msg for channelID. Replies to a message that is in the
+// channel reply to it natively, the returned reference is nil for others,
+// which quote their parent instead.
+func outboundText(msg *ping.MessageResponse, channelID string) (string, *discordgo.MessageReference) {
+	for _, copy := range msg.GetReplyTo().GetCopies() {
+		parentChannel, parentID, ok := strings.Cut(copy.GetMessageId(), ":")
+		if ok && parentChannel == channelID {
+			// The parent may have been deleted since, send the reply anyway
+			failIfNotExists := false
+			return formatMessage(msg), &discordgo.MessageReference{
+				MessageID:       parentID,
+				ChannelID:       channelID,
+				FailIfNotExists: &failIfNotExists,
+			}
+		}
+	}
+	return bridge.Quote(msg) + formatMessage(msg), nil
+}
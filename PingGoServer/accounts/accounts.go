@@ -0,0 +1,151 @@
+package accounts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	usersBucket  = []byte("users")
+	emailsBucket = []byte("emails") // Lowercased email -> username, keeps emails unique
+
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything past 72 bytes
+)
+
+var (
+	ErrInvalidUsername  = errors.New("username must be 3-32 letters, digits, '_', '.' or '-'")
+	ErrInvalidEmail     = errors.New("invalid email address")
+	ErrPasswordTooShort = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	ErrPasswordTooLong  = fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+	ErrPasswordMismatch = errors.New("passwords do not match")
+	ErrUsernameTaken    = errors.New("username is already taken")
+	ErrEmailTaken       = errors.New("email is already registered")
+	ErrUnknownUser      = errors.New("no such user")
+	ErrWrongPassword    = errors.New("wrong password")
+)
+
+// User is a registered account. Only the bcrypt hash of the password is kept.
+type User struct {
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	PasswordHash []byte    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Store keeps user accounts in a bolt database.
+type Store struct {
+	db *bolt.DB
+}
+
+// NewStore creates the account buckets in db if needed.
+func NewStore(db *bolt.DB) (*Store, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(usersBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(emailsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account buckets: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Register validates the sign up form and creates the account.
+func (s *Store) Register(username, email, password1, password2 string) (*User, error) {
+	if !usernamePattern.MatchString(username) {
+		return nil, ErrInvalidUsername
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return nil, ErrInvalidEmail
+	}
+	if password1 != password2 {
+		return nil, ErrPasswordMismatch
+	}
+	if len(password1) < minPasswordLength {
+		return nil, ErrPasswordTooShort
+	}
+	if len(password1) > maxPasswordLength {
+		return nil, ErrPasswordTooLong
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password1), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	user := &User{
+		Username:     username,
+		Email:        email,
+		PasswordHash: hash,
+		CreatedAt:    time.Now().UTC(),
+	}
+	emailKey := []byte(strings.ToLower(email))
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		emails := tx.Bucket(emailsBucket)
+		if users.Get([]byte(username)) != nil {
+			return ErrUsernameTaken
+		}
+		if emails.Get(emailKey) != nil {
+			return ErrEmailTaken
+		}
+
+		data, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		if err := users.Put([]byte(username), data); err != nil {
+			return err
+		}
+		return emails.Put(emailKey, []byte(username))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Authenticate checks the credentials and returns the matching user.
+func (s *Store) Authenticate(username, password string) (*User, error) {
+	user, err := s.Get(username)
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
+		return nil, ErrWrongPassword
+	}
+	return user, nil
+}
+
+// Get looks up a user by username.
+func (s *Store) Get(username string) (*User, error) {
+	var user User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(usersBucket).Get([]byte(username))
+		if data == nil {
+			return ErrUnknownUser
+		}
+		return json.Unmarshal(data, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,88 @@
+package accounts
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// newTestStore opens an account store in a temporary bolt database.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "ping.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRegister(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Register("alice", "alice@example.org", "password1", "password1"); err != nil {
+		t.Fatalf("Register() = %v", err)
+	}
+
+	tests := []struct {
+		name                          string
+		username, email, pass1, pass2 string
+		want                          error
+	}{
+		{"valid", "bob", "bob@example.org", "password1", "password1", nil},
+		{"short username", "bo", "bo@example.org", "password1", "password1", ErrInvalidUsername},
+		{"long username", strings.Repeat("b", 33), "b@example.org", "password1", "password1", ErrInvalidUsername},
+		{"username with space", "bob smith", "smith@example.org", "password1", "password1", ErrInvalidUsername},
+		{"invalid email", "carol", "carol", "password1", "password1", ErrInvalidEmail},
+		{"email with name", "carol", "Carol <carol@example.org>", "password1", "password1", ErrInvalidEmail},
+		{"passwords differ", "carol", "carol@example.org", "password1", "password2", ErrPasswordMismatch},
+		{"short password", "carol", "carol@example.org", "pass", "pass", ErrPasswordTooShort},
+		{"long password", "carol", "carol@example.org", strings.Repeat("p", 73), strings.Repeat("p", 73), ErrPasswordTooLong},
+		{"username taken", "alice", "other@example.org", "password1", "password1", ErrUsernameTaken},
+		{"email taken", "carol", "ALICE@example.org", "password1", "password1", ErrEmailTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := s.Register(tt.username, tt.email, tt.pass1, tt.pass2)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Register() = %v, want %v", err, tt.want)
+			}
+			if err == nil && (user.Username != tt.username || string(user.PasswordHash) == tt.pass1) {
+				t.Errorf("Register() = %+v, want %s with a hashed password", user, tt.username)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Register("alice", "alice@example.org", "password1", "password1"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		username, password string
+		want               error
+	}{
+		{"alice", "password1", nil},
+		{"alice", "password2", ErrWrongPassword},
+		{"alice", "", ErrWrongPassword},
+		{"Alice", "password1", ErrUnknownUser},
+		{"bob", "password1", ErrUnknownUser},
+	}
+	for _, tt := range tests {
+		user, err := s.Authenticate(tt.username, tt.password)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Authenticate(%q, %q) = %v, want %v", tt.username, tt.password, err, tt.want)
+		}
+		if err == nil && user.Username != tt.username {
+			t.Errorf("Authenticate(%q) = %s", tt.username, user.Username)
+		}
+	}
+}
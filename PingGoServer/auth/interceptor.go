@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MetadataKey is the gRPC metadata entry carrying "Bearer <token>".
+const MetadataKey = "authorization"
+
+type userKey struct{}
+
+// User returns the authenticated username stored in ctx by the interceptors.
+func User(ctx context.Context) (string, bool) {
+	username, ok := ctx.Value(userKey{}).(string)
+	return username, ok
+}
+
+// authenticate verifies the token in the incoming metadata.
+func (s *Signer) authenticate(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "missing session token")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "malformed authorization header")
+	}
+	username, err := s.Verify(token)
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+	return username, nil
+}
+
+// setClient overwrites the request's "client" field, if it has one, so a
+// caller can only ever act as the user the token was issued to.
+func setClient(req any, username string) {
+	m, ok := req.(proto.Message)
+	if !ok {
+		return
+	}
+	msg := m.ProtoReflect()
+	fd := msg.Descriptor().Fields().ByName("client")
+	if fd != nil && fd.Kind() == protoreflect.StringKind {
+		msg.Set(fd, protoreflect.ValueOfString(username))
+	}
+}
+
+// UnaryInterceptor rejects calls without a valid session token, except for
+// the methods in public (e.g. Login and Register).
+func (s *Signer) UnaryInterceptor(public ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if slices.Contains(public, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		username, err := s.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		setClient(req, username)
+		return handler(context.WithValue(ctx, userKey{}, username), req)
+	}
+}
+
+// StreamInterceptor is the streaming counterpart of UnaryInterceptor.
+func (s *Signer) StreamInterceptor(public ...string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if slices.Contains(public, info.FullMethod) {
+			return handler(srv, ss)
+		}
+		username, err := s.authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), userKey{}, username),
+			username:     username,
+		})
+	}
+}
+
+// authenticatedStream overrides the client field of every received request.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	username string
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authenticatedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	setClient(m, s.username)
+	return nil
+}
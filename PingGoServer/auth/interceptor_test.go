@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryInterceptor(t *testing.T) {
+	signer, _ := NewSigner([]byte("secret"), time.Hour)
+	token, _ := signer.Issue("alice")
+	interceptor := signer.UnaryInterceptor(ping.PingService_Login_FullMethodName)
+
+	tests := []struct {
+		name       string
+		method     string
+		auth       string // Authorization metadata, none if empty
+		wantCode   codes.Code
+		wantClient string
+	}{
+		{"token", ping.PingService_SendMessage_FullMethodName, "Bearer " + token, codes.OK, "alice"},
+		{"no token", ping.PingService_SendMessage_FullMethodName, "", codes.Unauthenticated, ""},
+		{"no bearer", ping.PingService_SendMessage_FullMethodName, token, codes.Unauthenticated, ""},
+		{"invalid token", ping.PingService_SendMessage_FullMethodName, "Bearer " + token + "x", codes.Unauthenticated, ""},
+		{"public", ping.PingService_Login_FullMethodName, "", codes.OK, "mallory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.auth != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataKey, tt.auth))
+			}
+			// Callers claim to be someone else, the token decides
+			req := &ping.MessageRequest{Client: "mallory"}
+			var user string
+			handler := func(ctx context.Context, req any) (any, error) {
+				user, _ = User(ctx)
+				return nil, nil
+			}
+			_, err := interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("interceptor returned %v, want %v", err, tt.wantCode)
+			}
+			if err == nil && req.Client != tt.wantClient {
+				t.Errorf("request client = %s, want %s", req.Client, tt.wantClient)
+			}
+			if tt.wantClient == "alice" && user != "alice" {
+				t.Errorf("User() = %q, want alice", user)
+			}
+		})
+	}
+}
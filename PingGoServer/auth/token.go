@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid session token")
+	ErrExpiredToken = errors.New("session token expired")
+)
+
+// claims is the signed part of a session token.
+type claims struct {
+	Username  string `json:"sub"`
+	ExpiresAt int64  `json:"exp"` // Unix seconds
+}
+
+// Signer issues and verifies session tokens of the form
+// base64url(claims) + "." + base64url(HMAC-SHA256(claims)).
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewSigner creates a signer. If secret is empty a random one is generated,
+// which means tokens stop working when the server restarts.
+func NewSigner(secret []byte, ttl time.Duration) (*Signer, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate session secret: %v", err)
+		}
+	}
+	return &Signer{secret: secret, ttl: ttl}, nil
+}
+
+// Issue creates a token for username, valid for the signer's TTL.
+func (s *Signer) Issue(username string) (string, error) {
+	payload, err := json.Marshal(claims{
+		Username:  username,
+		ExpiresAt: time.Now().Add(s.ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded), nil
+}
+
+// Verify checks the token's signature and expiry and returns the username.
+func (s *Signer) Verify(token string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return "", ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Username == "" {
+		return "", ErrInvalidToken
+	}
+	if time.Now().Unix() >= c.ExpiresAt {
+		return "", ErrExpiredToken
+	}
+	return c.Username, nil
+}
+
+func (s *Signer) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	signer, err := NewSigner([]byte("secret"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signer.Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, signature, _ := strings.Cut(token, ".")
+
+	expired, _ := NewSigner([]byte("secret"), -time.Second)
+	expiredToken, _ := expired.Issue("alice")
+	other, _ := NewSigner([]byte("other secret"), time.Hour)
+	otherToken, _ := other.Issue("alice")
+	// A payload naming someone else, with the signature of alice's
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory","exp":9999999999}`)) + "." + signature
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", token, nil},
+		{"expired", expiredToken, ErrExpiredToken},
+		{"other secret", otherToken, ErrInvalidToken},
+		{"forged payload", forged, ErrInvalidToken},
+		{"changed signature", payload + "." + strings.ToUpper(signature), ErrInvalidToken},
+		{"no signature", payload, ErrInvalidToken},
+		{"empty", "", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username, err := signer.Verify(tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.want)
+			}
+			if err == nil && username != "alice" {
+				t.Errorf("Verify() = %s, want alice", username)
+			}
+		})
+	}
+}
+
+func TestRandomSecret(t *testing.T) {
+	// Without a secret every signer has its own
+	a, _ := NewSigner(nil, time.Hour)
+	b, _ := NewSigner(nil, time.Hour)
+	token, err := a.Issue("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Verify(token); err != nil {
+		t.Errorf("Verify() of its own token = %v", err)
+	}
+	if _, err := b.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() of another signer's token = %v, want %v", err, ErrInvalidToken)
+	}
+}
@@ -0,0 +1,110 @@
+// Package blobs keeps the files sent with messages on the local filesystem.
+// Blobs are content-addressed: a blob's ID is the SHA-256 of its content, so
+// the same file sent twice is stored once.
+package blobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrNotFound = errors.New("blob not found")
+	ErrTooLarge = errors.New("blob too large")
+)
+
+// Store keeps blobs in a directory, each in a subdirectory named after the
+// first two characters of its ID.
+type Store struct {
+	dir     string
+	maxSize int64
+}
+
+// NewStore creates dir if needed. Blobs larger than maxSize bytes are refused.
+func NewStore(dir string, maxSize int64) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %v", err)
+	}
+	return &Store{dir: dir, maxSize: maxSize}, nil
+}
+
+// Put stores the content read from r and returns its ID and size.
+func (s *Store) Put(r io.Reader) (string, int64, error) {
+	tmp, err := os.CreateTemp(s.dir, "upload-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create blob: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	// Read one byte more than allowed to notice blobs that are too large
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, s.maxSize+1))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to write blob: %v", err)
+	}
+	if size > s.maxSize {
+		return "", 0, ErrTooLarge
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to write blob: %v", err)
+	}
+
+	id := hex.EncodeToString(hash.Sum(nil))
+	path := s.path(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", 0, fmt.Errorf("failed to store blob: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, fmt.Errorf("failed to store blob: %v", err)
+	}
+	return id, size, nil
+}
+
+// Open returns the content of blob id.
+func (s *Store) Open(id string) (*os.File, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob %s: %v", id, err)
+	}
+	return f, nil
+}
+
+// Size returns the size of blob id.
+func (s *Store) Size(id string) (int64, error) {
+	if !validID(id) {
+		return 0, ErrNotFound
+	}
+	info, err := os.Stat(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up blob %s: %v", id, err)
+	}
+	return info.Size(), nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id[:2], id)
+}
+
+// validID reports whether id is a hex SHA-256, so it can't name other files.
+func validID(id string) bool {
+	if len(id) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
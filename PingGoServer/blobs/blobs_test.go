@@ -0,0 +1,141 @@
+package blobs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPut(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr error
+	}{
+		{"empty", "", nil},
+		{"small", "hello", nil},
+		{"at the limit", strings.Repeat("x", 16), nil},
+		{"too large", strings.Repeat("x", 17), ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := NewStore(dir, 16)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, size, err := s.Put(strings.NewReader(tt.content))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Put() = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				// Nothing is left behind, not even the upload
+				if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+					t.Errorf("blob directory has %d entries after a refused upload", len(entries))
+				}
+				return
+			}
+			sum := sha256.Sum256([]byte(tt.content))
+			if id != hex.EncodeToString(sum[:]) || size != int64(len(tt.content)) {
+				t.Errorf("Put() = %s, %d, want the SHA-256 and %d", id, size, len(tt.content))
+			}
+
+			f, err := s.Open(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if content, _ := io.ReadAll(f); string(content) != tt.content {
+				t.Errorf("Open() content = %q, want %q", content, tt.content)
+			}
+			if got, err := s.Size(id); err != nil || got != size {
+				t.Errorf("Size() = %d, %v, want %d", got, err, size)
+			}
+		})
+	}
+}
+
+func TestPutTwice(t *testing.T) {
+	s, err := NewStore(t.TempDir(), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _, err := s.Put(strings.NewReader("same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := s.Put(strings.NewReader("same"))
+	if err != nil || second != first {
+		t.Errorf("Put() of the same content = %s, %v, want %s", second, err, first)
+	}
+}
+
+func TestInvalidID(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(filepath.Join(dir, "blobs"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A file outside the store that a crafted ID could point at
+	secret := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secret, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := sha256.Sum256([]byte("missing"))
+
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{"empty", ""},
+		{"short", "abcd"},
+		{"path", "../secret"},
+		{"padded path", "../secret" + strings.Repeat("0", 64-len("../secret"))},
+		{"not hex", strings.Repeat("g", 64)},
+		{"unknown", hex.EncodeToString(missing[:])},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if f, err := s.Open(tt.id); !errors.Is(err, ErrNotFound) {
+				if f != nil {
+					f.Close()
+				}
+				t.Errorf("Open(%q) = %v, want %v", tt.id, err, ErrNotFound)
+			}
+			if _, err := s.Size(tt.id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Size(%q) = %v, want %v", tt.id, err, ErrNotFound)
+			}
+		})
+	}
+}
+
+// failingReader fails after returning some content.
+type failingReader struct{ r io.Reader }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection lost")
+	}
+	return n, err
+}
+
+func TestPutFails(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Put(&failingReader{bytes.NewReader([]byte("partial"))}); err == nil {
+		t.Fatal("Put() of a failed upload succeeded")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("blob directory has %d entries after a failed upload", len(entries))
+	}
+}
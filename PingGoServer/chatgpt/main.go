@@ -3,67 +3,110 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net"
+	"os"
 	"sync"
 	"time"
 
-	ping "github.com/kallazz/Ping/pb"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/accounts"
+	"github.com/kallazz/Ping/auth"
+	"github.com/kallazz/Ping/friends"
+	"github.com/kallazz/Ping/hub"
+	"github.com/kallazz/Ping/inbox"
+	bolt "go.etcd.io/bbolt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Values of ExitCode.Status
+const (
+	StatusOK               = 0
+	StatusFailed           = 1
+	StatusInvalidRequest   = 2 // Malformed username, email or password
+	StatusPasswordMismatch = 3
+	StatusUsernameTaken    = 4
+	StatusEmailTaken       = 5
+	StatusUnknownUser      = 6
+	StatusWrongPassword    = 7
+	StatusInternalError    = 8
+	StatusAlreadyFriends   = 9 // Also returned for a repeated friend request
+	StatusNoFriendRequest  = 10
+	StatusNotFriends       = 11
+	StatusBlocked          = 12
 )
 
 // pingServer will implement the PingServiceServer interface.
 type pingServer struct {
 	ping.UnimplementedPingServiceServer
 	// In the C# code we have clientConnections and messageQueues as
-	// ConcurrentDictionaries. Here we’ll do a map plus a mutex.
-	mu sync.RWMutex
+	// ConcurrentDictionaries. Here the hub keeps a buffered queue per
+	// connected userId instead.
+	hub *hub.Hub
+	mu  sync.Mutex // Keeps inbox writes and pushes to the hub in order
+
+	// Messages waiting to be delivered or read, per user
+	inbox *inbox.Store
+
+	// Registered users with their password hashes
+	accounts *accounts.Store
 
-	// Map of userId -> streaming connection
-	clientConnections map[string]ping.PingService_ReceiveMessagesServer
+	// Issues the session tokens handed out by Login
+	sessions *auth.Signer
 
-	// Map of userId -> queue of messages
-	messageQueues map[string]chan *ping.ServerMessage
+	// Friendships, pending friend requests and blocks
+	friends *friends.Store
 }
 
 // NewPingServer creates and returns our server instance.
-func NewPingServer() *pingServer {
+func NewPingServer(h *hub.Hub, inboxStore *inbox.Store, accountStore *accounts.Store, sessions *auth.Signer, friendStore *friends.Store) *pingServer {
 	return &pingServer{
-		clientConnections: make(map[string]ping.PingService_ReceiveMessagesServer),
-		messageQueues:     make(map[string]chan *ping.ServerMessage),
+		hub:      h,
+		inbox:    inboxStore,
+		accounts: accountStore,
+		sessions: sessions,
+		friends:  friendStore,
 	}
 }
 
-// SendMessage is analogous to SendMessage in C#.
+// SendMessage is analogous to SendMessage in C#. The message goes to the
+// recipient's inbox first, so it reaches them even if they are offline.
 func (s *pingServer) SendMessage(ctx context.Context, req *ping.MessageRequest) (*ping.ExitCode, error) {
-	// "Database calls" omitted. We'll just do a simple pass-through:
 	clientID := req.Client
 	recipientID := req.Recipient
 	message := req.Message
 
 	fmt.Printf("Sending message to %s from %s: %s\n", recipientID, clientID, message)
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	_, ok := s.clientConnections[recipientID]
-	if !ok {
-		fmt.Printf("Recipient %s not connected.\n", recipientID)
-		return &ping.ExitCode{Status: 1, Message: "Recipient not connected"}, nil
+	if _, err := s.accounts.Get(recipientID); err != nil {
+		return accountExitCode(err), nil
+	}
+	if blocked, err := s.friends.IsBlocked(recipientID, clientID); err != nil || blocked {
+		fmt.Printf("Recipient %s does not accept messages from %s.\n", recipientID, clientID)
+		return &ping.ExitCode{Status: StatusBlocked, Message: "Recipient does not accept your messages"}, nil
 	}
 
-	// Enqueue message
-	msg := &ping.ServerMessage{
-		MessageResponse: &ping.MessageResponse{
-			Type:    "Message",
-			Content: message,
-			Sender:  clientID,
-		},
-		ExitCode: &ping.ExitCode{Status: 0, Message: "Message enqueued"},
+	entry := &inbox.Entry{
+		Type:    "Message",
+		Content: message,
+		Sender:  clientID,
+	}
+	online, err := s.deliver(recipientID, entry)
+	if err != nil {
+		fmt.Printf("Failed to enqueue message for %s: %v\n", recipientID, err)
+		return &ping.ExitCode{Status: StatusInternalError, Message: "Internal server error"}, nil
+	}
+	if !online {
+		fmt.Printf("Recipient %s not connected, message queued.\n", recipientID)
+		return &ping.ExitCode{Status: StatusOK, Message: "Message queued", MessageId: entry.ID}, nil
 	}
-	s.messageQueues[recipientID] <- msg
 
-	return &ping.ExitCode{Status: 0, Message: "Message sent"}, nil
+	return &ping.ExitCode{Status: StatusOK, Message: "Message sent", MessageId: entry.ID}, nil
 }
 
 // ProposeKeyExchange is analogous to ProposeKeyExchange in C#.
@@ -75,13 +118,8 @@ func (s *pingServer) ProposeKeyExchange(ctx context.Context, req *ping.KeyExchan
 
 	fmt.Printf("Key exchange proposed from %s to %s\n", clientID, recipientID)
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	_, ok := s.clientConnections[recipientID]
-	if !ok {
-		fmt.Printf("Recipient %s not connected.\n", recipientID)
-		return &ping.ExitCode{Status: 1, Message: "Recipient not connected"}, nil
+	if _, err := s.accounts.Get(recipientID); err != nil {
+		return accountExitCode(err), nil
 	}
 
 	exchangeType := "KeyExchangeResponse"
@@ -89,17 +127,74 @@ func (s *pingServer) ProposeKeyExchange(ctx context.Context, req *ping.KeyExchan
 		exchangeType = "KeyExchangeInit"
 	}
 
-	msg := &ping.ServerMessage{
-		MessageResponse: &ping.MessageResponse{
-			Type:    exchangeType,
-			Content: string(publicKey), // or base64 if needed
-			Sender:  clientID,
-		},
-		ExitCode: &ping.ExitCode{Status: 0, Message: "Key exchange forwarded"},
+	entry := &inbox.Entry{
+		Type:    exchangeType,
+		Content: base64.StdEncoding.EncodeToString(publicKey), // Content is text, keys are not
+		Sender:  clientID,
+	}
+	if _, err := s.deliver(recipientID, entry); err != nil {
+		fmt.Printf("Failed to enqueue key exchange for %s: %v\n", recipientID, err)
+		return &ping.ExitCode{Status: StatusInternalError, Message: "Internal server error"}, nil
+	}
+
+	return &ping.ExitCode{Status: StatusOK, Message: "Key exchange proposed", MessageId: entry.ID}, nil
+}
+
+// AcknowledgeMessage is called by the recipient once a message from its inbox
+// was delivered, and again once it was read. The sender gets a "Delivered" or
+// "Read" receipt for chat messages.
+func (s *pingServer) AcknowledgeMessage(ctx context.Context, req *ping.AckRequest) (*ping.ExitCode, error) {
+	var entry *inbox.Entry
+	var err error
+	if req.Read {
+		entry, err = s.inbox.Remove(req.Client, req.MessageId)
+	} else {
+		entry, err = s.inbox.MarkDelivered(req.Client, req.MessageId)
+	}
+	if errors.Is(err, inbox.ErrNotFound) {
+		return &ping.ExitCode{Status: StatusInvalidRequest, Message: err.Error()}, nil
+	}
+	if err != nil {
+		fmt.Printf("Failed to acknowledge message %d of %s: %v\n", req.MessageId, req.Client, err)
+		return &ping.ExitCode{Status: StatusInternalError, Message: "Internal server error"}, nil
+	}
+
+	// Only chat messages get receipts, and only they are kept until read
+	if entry.Type != "Message" {
+		if !req.Read {
+			if _, err := s.inbox.Remove(req.Client, req.MessageId); err != nil && !errors.Is(err, inbox.ErrNotFound) {
+				fmt.Printf("Failed to remove message %d of %s: %v\n", req.MessageId, req.Client, err)
+				return &ping.ExitCode{Status: StatusInternalError, Message: "Internal server error"}, nil
+			}
+		}
+		return &ping.ExitCode{Status: StatusOK, Message: "Acknowledged"}, nil
+	}
+
+	receiptType := "Delivered"
+	if req.Read {
+		receiptType = "Read"
+	}
+	receipt := &inbox.Entry{
+		Type:           receiptType,
+		Sender:         req.Client,
+		AcknowledgedID: entry.ID,
 	}
-	s.messageQueues[recipientID] <- msg
+	if _, err := s.deliver(entry.Sender, receipt); err != nil {
+		fmt.Printf("Failed to enqueue receipt for %s: %v\n", entry.Sender, err)
+	}
+
+	return &ping.ExitCode{Status: StatusOK, Message: "Acknowledged"}, nil
+}
 
-	return &ping.ExitCode{Status: 0, Message: "Key exchange proposed"}, nil
+// deliver stores entry in the recipient's inbox and pushes it right away if
+// they are connected. Holding the lock keeps messages in inbox order.
+func (s *pingServer) deliver(recipientID string, entry *inbox.Entry) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.inbox.Enqueue(recipientID, entry); err != nil {
+		return false, err
+	}
+	return s.hub.Send(recipientID, entry.ServerMessage()), nil
 }
 
 // ReceiveMessages corresponds to the streaming method where the server
@@ -112,77 +207,252 @@ func (s *pingServer) ReceiveMessages(req *ping.Empty, stream ping.PingService_Re
 
 	fmt.Printf("Client %s connected (stream)\n", clientID)
 
-	// Setup a message queue for this client if not already existing
+	// Setup a message queue for this client, replacing any older connection.
+	// Subscribing under the lock means every message is either already in the
+	// inbox or will come through the queue.
 	s.mu.Lock()
-	s.clientConnections[clientID] = stream
-	if _, exists := s.messageQueues[clientID]; !exists {
-		s.messageQueues[clientID] = make(chan *ping.ServerMessage, 100)
-	}
+	sub := s.hub.Subscribe(clientID)
 	s.mu.Unlock()
+	defer s.hub.Unsubscribe(sub)
 
-	// Loop while context is alive, reading from the message channel
-	for {
-		select {
-		case <-stream.Context().Done():
-			// Client disconnected
-			fmt.Printf("Client %s disconnected\n", clientID)
-			s.mu.Lock()
-			delete(s.clientConnections, clientID)
-			delete(s.messageQueues, clientID)
-			s.mu.Unlock()
-			return nil
-
-		case msg := <-s.messageQueues[clientID]:
-			// In real code, you'd do DB lookups to convert sender ID to username, etc.
-			// Here we just forward what we got.
-			if err := stream.Send(msg); err != nil {
-				fmt.Printf("Error sending message to %s: %v\n", clientID, err)
-			}
-		case <-time.After(100 * time.Millisecond):
-			// Sleep to avoid busy-looping; adjust as needed
+	// Deliver what arrived while the client was offline. Entries stay in the
+	// inbox until the client acknowledges them.
+	pending, err := s.inbox.Pending(clientID)
+	if err != nil {
+		fmt.Printf("Failed to load inbox of %s: %v\n", clientID, err)
+		return err
+	}
+	var delivered uint64
+	for _, entry := range pending {
+		if err := stream.Send(entry.ServerMessage()); err != nil {
+			return err
 		}
+		delivered = entry.ID
 	}
+
+	// Forward queued messages until the client disconnects or falls behind.
+	// In real code, you'd do DB lookups to convert sender ID to username, etc.
+	// Here we just forward what we got.
+	err = sub.Serve(stream.Context(), func(msg *ping.ServerMessage) error {
+		if id := msg.GetMessageResponse().GetId(); id != 0 && id <= delivered {
+			return nil // Already sent from the inbox
+		}
+		return stream.Send(msg)
+	})
+	if err != nil {
+		fmt.Printf("Error sending message to %s: %v\n", clientID, err)
+	}
+	fmt.Printf("Client %s disconnected\n", clientID)
+	return err
 }
 
 // Login corresponds to the Login method in the C# server.
 func (s *pingServer) Login(ctx context.Context, req *ping.LoginRequest) (*ping.ExitCode, error) {
-	// Do "auth" check, omitted for brevity
-	// If successful:
+	if _, err := s.accounts.Authenticate(req.Username, req.Password); err != nil {
+		fmt.Printf("Login failed for user %s: %v\n", req.Username, err)
+		return accountExitCode(err), nil
+	}
+
+	token, err := s.sessions.Issue(req.Username)
+	if err != nil {
+		fmt.Printf("Failed to issue session token for %s: %v\n", req.Username, err)
+		return accountExitCode(err), nil
+	}
+
 	fmt.Printf("User %s logged in\n", req.Username)
-	return &ping.ExitCode{Status: 0, Message: "Welcome to server"}, nil
+	return &ping.ExitCode{Status: StatusOK, Message: "Welcome to server", Token: token}, nil
 }
 
 // Register corresponds to the Register method in the C# server.
 func (s *pingServer) Register(ctx context.Context, req *ping.RegisterRequest) (*ping.ExitCode, error) {
-	// Do "registration" check, omitted for brevity
-	// If successful:
+	if _, err := s.accounts.Register(req.Username, req.Email, req.Password1, req.Password2); err != nil {
+		fmt.Printf("Registration failed for user %s: %v\n", req.Username, err)
+		return accountExitCode(err), nil
+	}
+
 	fmt.Printf("Registration success for user: %s\n", req.Username)
-	return &ping.ExitCode{Status: 0, Message: "Welcome to server"}, nil
+	return &ping.ExitCode{Status: StatusOK, Message: "Welcome to server"}, nil
+}
+
+// accountExitCode maps errors from the account store to the status the client sees.
+func accountExitCode(err error) *ping.ExitCode {
+	status := int32(StatusInternalError)
+	message := err.Error()
+	switch {
+	case errors.Is(err, accounts.ErrInvalidUsername),
+		errors.Is(err, accounts.ErrInvalidEmail),
+		errors.Is(err, accounts.ErrPasswordTooShort),
+		errors.Is(err, accounts.ErrPasswordTooLong):
+		status = StatusInvalidRequest
+	case errors.Is(err, accounts.ErrPasswordMismatch):
+		status = StatusPasswordMismatch
+	case errors.Is(err, accounts.ErrUsernameTaken):
+		status = StatusUsernameTaken
+	case errors.Is(err, accounts.ErrEmailTaken):
+		status = StatusEmailTaken
+	case errors.Is(err, accounts.ErrUnknownUser):
+		status = StatusUnknownUser
+	case errors.Is(err, accounts.ErrWrongPassword):
+		status = StatusWrongPassword
+	default:
+		message = "Internal server error" // Don't leak storage errors to clients
+	}
+	return &ping.ExitCode{Status: status, Message: message}
 }
 
-// GetFriends is analogous to GetFriends in C#.
-func (s *pingServer) GetFriends(ctx context.Context, req *ping.FriendListRequest) (*ping.ServerMessage, error) {
-	// For simplicity, pretend user has 2 friends:
-	friendList := "alice;bob"
-	msg := &ping.ServerMessage{
-		MessageResponse: &ping.MessageResponse{Content: friendList},
-		ExitCode:        &ping.ExitCode{Status: 0},
+// GetFriends is analogous to GetFriends in C#. Besides friends it lists
+// pending requests and blocked users, each with their status.
+func (s *pingServer) GetFriends(ctx context.Context, req *ping.FriendListRequest) (*ping.FriendList, error) {
+	relations, err := s.friends.List(req.Client)
+	if err != nil {
+		fmt.Printf("Failed to list friends of %s: %v\n", req.Client, err)
+		return &ping.FriendList{ExitCode: friendExitCode(err)}, nil
 	}
-	fmt.Printf("Returning friends for %s: %s\n", req.Client, friendList)
-	return msg, nil
+
+	list := &ping.FriendList{ExitCode: &ping.ExitCode{Status: StatusOK}}
+	for _, r := range relations {
+		friend := &ping.Friend{
+			Username: r.Username,
+			Status:   friendStatuses[r.Status],
+		}
+		// Presence is only shared between friends
+		if r.Status == friends.Friend {
+			friend.Online = s.hub.Connected(r.Username)
+		}
+		list.Friends = append(list.Friends, friend)
+	}
+	fmt.Printf("Returning %d friends for %s\n", len(list.Friends), req.Client)
+	return list, nil
+}
+
+// friendStatuses maps stored relations to their protocol counterpart.
+var friendStatuses = map[friends.Status]ping.FriendStatus{
+	friends.Friend:   ping.FriendStatus_FRIEND_STATUS_FRIEND,
+	friends.Incoming: ping.FriendStatus_FRIEND_STATUS_INCOMING,
+	friends.Outgoing: ping.FriendStatus_FRIEND_STATUS_OUTGOING,
+	friends.Blocked:  ping.FriendStatus_FRIEND_STATUS_BLOCKED,
 }
 
-// AddFriend is analogous to AddFriend in C#.
+// AddFriend is analogous to AddFriend in C#. It sends a friend request, or
+// accepts the one the other user already sent.
 func (s *pingServer) AddFriend(ctx context.Context, req *ping.AddFriendRequest) (*ping.ExitCode, error) {
-	// For simplicity, always succeed:
+	if _, err := s.accounts.Get(req.Friend); err != nil {
+		return accountExitCode(err), nil
+	}
+
+	status, err := s.friends.Request(req.Client, req.Friend)
+	if err != nil {
+		fmt.Printf("Friend request %s -> %s failed: %v\n", req.Client, req.Friend, err)
+		return friendExitCode(err), nil
+	}
+
+	if status == friends.Friend {
+		fmt.Printf("Friend added: %s -> %s\n", req.Client, req.Friend)
+		s.notify(req.Friend, "FriendAccepted", req.Client)
+		return &ping.ExitCode{Status: StatusOK, Message: "Friend added successfully"}, nil
+	}
+	fmt.Printf("Friend request sent: %s -> %s\n", req.Client, req.Friend)
+	s.notify(req.Friend, "FriendRequest", req.Client)
+	return &ping.ExitCode{Status: StatusOK, Message: "Friend request sent"}, nil
+}
+
+// AcceptFriend accepts a pending friend request.
+func (s *pingServer) AcceptFriend(ctx context.Context, req *ping.AddFriendRequest) (*ping.ExitCode, error) {
+	if err := s.friends.Accept(req.Client, req.Friend); err != nil {
+		return friendExitCode(err), nil
+	}
 	fmt.Printf("Friend added: %s -> %s\n", req.Client, req.Friend)
-	return &ping.ExitCode{Status: 0, Message: "Friend added successfully"}, nil
+	s.notify(req.Friend, "FriendAccepted", req.Client)
+	return &ping.ExitCode{Status: StatusOK, Message: "Friend added successfully"}, nil
+}
+
+// DeclineFriend rejects a pending friend request.
+func (s *pingServer) DeclineFriend(ctx context.Context, req *ping.AddFriendRequest) (*ping.ExitCode, error) {
+	if err := s.friends.Decline(req.Client, req.Friend); err != nil {
+		return friendExitCode(err), nil
+	}
+	fmt.Printf("Friend request declined: %s -> %s\n", req.Friend, req.Client)
+	return &ping.ExitCode{Status: StatusOK, Message: "Friend request declined"}, nil
+}
+
+// RemoveFriend ends a friendship or withdraws a sent friend request.
+func (s *pingServer) RemoveFriend(ctx context.Context, req *ping.AddFriendRequest) (*ping.ExitCode, error) {
+	if err := s.friends.Remove(req.Client, req.Friend); err != nil {
+		return friendExitCode(err), nil
+	}
+	fmt.Printf("Friend removed: %s -> %s\n", req.Client, req.Friend)
+	return &ping.ExitCode{Status: StatusOK, Message: "Friend removed"}, nil
+}
+
+// BlockUser blocks a user, ending any friendship with them.
+func (s *pingServer) BlockUser(ctx context.Context, req *ping.AddFriendRequest) (*ping.ExitCode, error) {
+	if _, err := s.accounts.Get(req.Friend); err != nil {
+		return accountExitCode(err), nil
+	}
+	if err := s.friends.Block(req.Client, req.Friend); err != nil {
+		return friendExitCode(err), nil
+	}
+	fmt.Printf("User blocked: %s -> %s\n", req.Client, req.Friend)
+	return &ping.ExitCode{Status: StatusOK, Message: "User blocked"}, nil
+}
+
+// UnblockUser lifts a block.
+func (s *pingServer) UnblockUser(ctx context.Context, req *ping.AddFriendRequest) (*ping.ExitCode, error) {
+	if err := s.friends.Unblock(req.Client, req.Friend); err != nil {
+		return friendExitCode(err), nil
+	}
+	fmt.Printf("User unblocked: %s -> %s\n", req.Client, req.Friend)
+	return &ping.ExitCode{Status: StatusOK, Message: "User unblocked"}, nil
+}
+
+// notify tells a connected user about something that happened, e.g. a new
+// friend request. Offline users see it the next time they list their friends.
+func (s *pingServer) notify(recipientID, notificationType, sender string) {
+	s.hub.Send(recipientID, &ping.ServerMessage{
+		MessageResponse: &ping.MessageResponse{
+			Type:   notificationType,
+			Sender: sender,
+		},
+		ExitCode: &ping.ExitCode{Status: StatusOK},
+	})
+}
+
+// friendExitCode maps errors from the friend store to the status the client sees.
+func friendExitCode(err error) *ping.ExitCode {
+	status := int32(StatusInternalError)
+	message := err.Error()
+	switch {
+	case errors.Is(err, friends.ErrSelf):
+		status = StatusInvalidRequest
+	case errors.Is(err, friends.ErrAlreadyFriends), errors.Is(err, friends.ErrAlreadySent):
+		status = StatusAlreadyFriends
+	case errors.Is(err, friends.ErrNoRequest):
+		status = StatusNoFriendRequest
+	case errors.Is(err, friends.ErrNotFriends):
+		status = StatusNotFriends
+	case errors.Is(err, friends.ErrBlocked), errors.Is(err, friends.ErrNotBlocked):
+		status = StatusBlocked
+	default:
+		message = "Internal server error"
+	}
+	return &ping.ExitCode{Status: status, Message: message}
 }
 
 // main function sets up and starts the gRPC server.
 func main() {
 	// Typically you'd load environment variables for port, etc.
 	port := "50051"
+	dbPath := flag.String("db", "ping-accounts.db", "Path to the server database")
+	sessionTTL := flag.Duration("session-ttl", 24*time.Hour, "How long session tokens issued by Login stay valid")
+	bufferSize := flag.Int("buffer", 100, "Number of messages buffered per client")
+	slowPolicy := flag.String("slow-policy", "drop-oldest", "What to do with clients whose buffer is full: drop-oldest, drop-newest or disconnect")
+	flag.Parse()
+
+	policy, err := hub.ParsePolicy(*slowPolicy)
+	if err != nil {
+		log.Fatalf("Invalid -slow-policy: %v", err)
+	}
+
 	fmt.Printf("Starting server on port %s...\n", port)
 
 	lis, err := net.Listen("tcp", ":"+port)
@@ -190,8 +460,49 @@ func main() {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	grpcServer := grpc.NewServer()
-	srv := NewPingServer()
+	db, err := bolt.Open(*dbPath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		log.Fatalf("Failed to open database %s: %v", *dbPath, err)
+	}
+	defer db.Close()
+
+	accountStore, err := accounts.NewStore(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize account store: %v", err)
+	}
+
+	inboxStore, err := inbox.NewStore(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize inbox store: %v", err)
+	}
+
+	friendStore, err := friends.NewStore(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize friend store: %v", err)
+	}
+
+	// Tokens are signed with SESSION_SECRET, so they survive restarts if it is set
+	secret := os.Getenv("SESSION_SECRET")
+	if secret == "" {
+		fmt.Println("SESSION_SECRET not set, sessions will not survive a restart")
+	}
+	sessions, err := auth.NewSigner([]byte(secret), *sessionTTL)
+	if err != nil {
+		log.Fatalf("Failed to initialize sessions: %v", err)
+	}
+
+	// Everything but Login and Register needs a session token
+	public := []string{ping.PingService_Login_FullMethodName, ping.PingService_Register_FullMethodName}
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(sessions.UnaryInterceptor(public...)),
+		grpc.StreamInterceptor(sessions.StreamInterceptor(public...)),
+		// Clients may keep idle connections open with keepalive pings
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             20 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
+	srv := NewPingServer(hub.New(*bufferSize, policy), inboxStore, accountStore, sessions, friendStore)
 
 	// Register our pingServer as PingServiceServer
 	ping.RegisterPingServiceServer(grpcServer, srv)
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/accounts"
+	"github.com/kallazz/Ping/auth"
+	"github.com/kallazz/Ping/friends"
+	"github.com/kallazz/Ping/hub"
+	"github.com/kallazz/Ping/inbox"
+	bolt "go.etcd.io/bbolt"
+)
+
+// newTestServer creates a server on a temporary bolt database, with alice
+// and bob registered.
+func newTestServer(t *testing.T) *pingServer {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "ping.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	accountStore, err := accounts.NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inboxStore, err := inbox.NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	friendStore, err := friends.NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := auth.NewSigner([]byte("secret"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewPingServer(hub.New(10, hub.DropOldest), inboxStore, accountStore, sessions, friendStore)
+	for _, name := range []string{"alice", "bob"} {
+		req := &ping.RegisterRequest{Username: name, Email: name + "@example.org", Password1: "password1", Password2: "password1"}
+		if code, _ := s.Register(context.Background(), req); code.Status != StatusOK {
+			t.Fatalf("Register(%s) = %v", name, code)
+		}
+	}
+	return s
+}
+
+func TestRegister(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name string
+		req  *ping.RegisterRequest
+		want int32
+	}{
+		{"valid", &ping.RegisterRequest{Username: "carol", Email: "carol@example.org", Password1: "password1", Password2: "password1"}, StatusOK},
+		{"invalid username", &ping.RegisterRequest{Username: "c", Email: "c@example.org", Password1: "password1", Password2: "password1"}, StatusInvalidRequest},
+		{"invalid email", &ping.RegisterRequest{Username: "dave", Email: "dave", Password1: "password1", Password2: "password1"}, StatusInvalidRequest},
+		{"short password", &ping.RegisterRequest{Username: "dave", Email: "dave@example.org", Password1: "pass", Password2: "pass"}, StatusInvalidRequest},
+		{"passwords differ", &ping.RegisterRequest{Username: "dave", Email: "dave@example.org", Password1: "password1", Password2: "password2"}, StatusPasswordMismatch},
+		{"username taken", &ping.RegisterRequest{Username: "alice", Email: "dave@example.org", Password1: "password1", Password2: "password1"}, StatusUsernameTaken},
+		{"email taken", &ping.RegisterRequest{Username: "dave", Email: "alice@example.org", Password1: "password1", Password2: "password1"}, StatusEmailTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := s.Register(context.Background(), tt.req)
+			if err != nil || code.Status != tt.want {
+				t.Errorf("Register() = %v, %v, want status %d", code, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name               string
+		username, password string
+		want               int32
+	}{
+		{"valid", "alice", "password1", StatusOK},
+		{"wrong password", "alice", "password2", StatusWrongPassword},
+		{"unknown user", "carol", "password1", StatusUnknownUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := s.Login(context.Background(), &ping.LoginRequest{Username: tt.username, Password: tt.password})
+			if err != nil || code.Status != tt.want {
+				t.Fatalf("Login() = %v, %v, want status %d", code, err, tt.want)
+			}
+			if tt.want != StatusOK {
+				if code.Token != "" {
+					t.Errorf("Login() gave a token for a failed login")
+				}
+				return
+			}
+			if user, err := s.sessions.Verify(code.Token); err != nil || user != tt.username {
+				t.Errorf("Verify(token) = %q, %v, want %s", user, err, tt.username)
+			}
+		})
+	}
+}
+
+// pending returns the types of the entries waiting in recipient's inbox.
+func pending(t *testing.T, s *pingServer, recipient string) []string {
+	t.Helper()
+	entries, err := s.inbox.Pending(recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, entry := range entries {
+		types = append(types, entry.Type)
+	}
+	return types
+}
+
+func TestAcknowledgeMessage(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	sent, err := s.SendMessage(ctx, &ping.MessageRequest{Client: "alice", Recipient: "bob", Message: "hi"})
+	if err != nil || sent.Status != StatusOK {
+		t.Fatalf("SendMessage() = %v, %v", sent, err)
+	}
+	exchange, err := s.ProposeKeyExchange(ctx, &ping.KeyExchangeRequest{Client: "alice", Recipient: "bob", PublicKey: []byte("key"), Init: true})
+	if err != nil || exchange.Status != StatusOK {
+		t.Fatalf("ProposeKeyExchange() = %v, %v", exchange, err)
+	}
+
+	tests := []struct {
+		name         string
+		id           uint64
+		read         bool
+		want         int32
+		wantBob      []string // Still to be delivered to bob
+		wantReceipts []string // Waiting for alice
+	}{
+		{"key exchange", exchange.MessageId, false, StatusOK, []string{"Message"}, nil},
+		{"delivered", sent.MessageId, false, StatusOK, nil, []string{"Delivered"}},
+		{"read", sent.MessageId, true, StatusOK, nil, []string{"Delivered", "Read"}},
+		{"read twice", sent.MessageId, true, StatusInvalidRequest, nil, []string{"Delivered", "Read"}},
+		{"unknown", 1000, false, StatusInvalidRequest, nil, []string{"Delivered", "Read"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := s.AcknowledgeMessage(ctx, &ping.AckRequest{Client: "bob", MessageId: tt.id, Read: tt.read})
+			if err != nil || code.Status != tt.want {
+				t.Fatalf("AcknowledgeMessage() = %v, %v, want status %d", code, err, tt.want)
+			}
+			if bob := pending(t, s, "bob"); !slices.Equal(bob, tt.wantBob) {
+				t.Errorf("bob's inbox = %v, want %v", bob, tt.wantBob)
+			}
+			if receipts := pending(t, s, "alice"); !slices.Equal(receipts, tt.wantReceipts) {
+				t.Errorf("alice's inbox = %v, want %v", receipts, tt.wantReceipts)
+			}
+		})
+	}
+}
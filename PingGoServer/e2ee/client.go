@@ -0,0 +1,184 @@
+package e2ee
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// Client sets up end-to-end encrypted sessions through ProposeKeyExchange and
+// encrypts messages with them, so the server only ever sees ciphertext.
+//
+// Messages from ReceiveMessages have to be passed to HandleServerMessage,
+// which completes key exchanges and decrypts incoming messages.
+type Client struct {
+	ping     ping.PingServiceClient
+	username string
+
+	mu       sync.Mutex
+	pending  map[string]*ecdh.PrivateKey // Key exchanges we started, per peer
+	sessions map[string]*Session
+}
+
+// NewClient creates a client for the logged in user.
+func NewClient(c ping.PingServiceClient, username string) *Client {
+	return &Client{
+		ping:     c,
+		username: username,
+		pending:  make(map[string]*ecdh.PrivateKey),
+		sessions: make(map[string]*Session),
+	}
+}
+
+// HasSession reports whether messages to peer can be encrypted yet.
+func (c *Client) HasSession(peer string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.sessions[peer]
+	return ok
+}
+
+// StartKeyExchange proposes a new session to peer. The session is ready once
+// their KeyExchangeResponse went through HandleServerMessage.
+func (c *Client) StartKeyExchange(ctx context.Context, peer string) error {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.pending[peer] = private
+	c.mu.Unlock()
+
+	return c.proposeKey(ctx, peer, private, true)
+}
+
+// SendMessage encrypts text for peer and sends it.
+func (c *Client) SendMessage(ctx context.Context, peer, text string) (*ping.ExitCode, error) {
+	c.mu.Lock()
+	session, ok := c.sessions[peer]
+	c.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no encrypted session with %s, start a key exchange first", peer)
+	}
+
+	content, err := session.Seal(c.username, peer, text)
+	if err != nil {
+		return nil, err
+	}
+	return c.ping.SendMessage(ctx, &ping.MessageRequest{
+		Client:    c.username,
+		Recipient: peer,
+		Message:   content,
+		Author:    c.username,
+	})
+}
+
+// HandleServerMessage processes a message received from ReceiveMessages.
+// Key exchanges are completed and acknowledged, encrypted chat messages are
+// decrypted in place. It returns true if msg was a key exchange, which the
+// caller should not show to the user.
+func (c *Client) HandleServerMessage(ctx context.Context, msg *ping.ServerMessage) (bool, error) {
+	resp := msg.GetMessageResponse()
+	switch resp.GetType() {
+	case "KeyExchangeInit", "KeyExchangeResponse":
+		err := c.handleKeyExchange(ctx, resp.GetSender(), resp.GetContent(), resp.GetType() == "KeyExchangeInit")
+		// Acknowledge even on failure, a broken key is not getting any better
+		if _, ackErr := c.ping.AcknowledgeMessage(ctx, &ping.AckRequest{Client: c.username, MessageId: resp.GetId()}); ackErr != nil && err == nil {
+			err = ackErr
+		}
+		return true, err
+
+	case "Message":
+		c.mu.Lock()
+		session, ok := c.sessions[resp.GetSender()]
+		c.mu.Unlock()
+		if !ok {
+			return false, nil // Plain text, or a session we don't know about
+		}
+		text, err := session.Open(resp.GetSender(), c.username, resp.GetContent())
+		if errors.Is(err, ErrNotEncrypted) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		resp.Content = text
+	}
+	return false, nil
+}
+
+func (c *Client) handleKeyExchange(ctx context.Context, peer, content string, init bool) error {
+	keyBytes, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return fmt.Errorf("malformed public key from %s: %v", peer, err)
+	}
+	peerPublic, err := ecdh.X25519().NewPublicKey(keyBytes)
+	if err != nil {
+		return fmt.Errorf("invalid public key from %s: %v", peer, err)
+	}
+
+	c.mu.Lock()
+	private, proposed := c.pending[peer]
+	if !init {
+		// Response to our own proposal
+		if !proposed {
+			c.mu.Unlock()
+			return fmt.Errorf("unexpected key exchange response from %s", peer)
+		}
+		delete(c.pending, peer)
+		c.mu.Unlock()
+		return c.setSession(peer, private, peerPublic)
+	}
+
+	// Both sides proposed at the same time: the smaller username wins and
+	// waits for the other side's response instead.
+	if proposed && c.username < peer {
+		c.mu.Unlock()
+		return nil
+	}
+	delete(c.pending, peer)
+	c.mu.Unlock()
+
+	private, err = ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	if err := c.setSession(peer, private, peerPublic); err != nil {
+		return err
+	}
+	return c.proposeKey(ctx, peer, private, false)
+}
+
+func (c *Client) setSession(peer string, private *ecdh.PrivateKey, peerPublic *ecdh.PublicKey) error {
+	session, err := newSession(private, peerPublic)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.sessions[peer] = session
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Client) proposeKey(ctx context.Context, peer string, private *ecdh.PrivateKey, init bool) error {
+	r, err := c.ping.ProposeKeyExchange(ctx, &ping.KeyExchangeRequest{
+		Client:    c.username,
+		Recipient: peer,
+		PublicKey: private.PublicKey().Bytes(),
+		Init:      init,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to propose key exchange: %v", err)
+	}
+	if r.GetStatus() != 0 {
+		return fmt.Errorf("key exchange rejected: %s", r.GetMessage())
+	}
+	return nil
+}
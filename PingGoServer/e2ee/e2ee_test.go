@@ -0,0 +1,197 @@
+package e2ee
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"google.golang.org/grpc"
+)
+
+// fakeServer stands in for the Ping server, keeping what each client sends
+// until the test delivers it.
+type fakeServer struct {
+	ping.PingServiceClient // Calls the tests don't expect panic
+
+	from     string
+	proposed []*ping.KeyExchangeRequest
+	sent     []*ping.MessageRequest
+	acked    []uint64
+}
+
+func (f *fakeServer) ProposeKeyExchange(ctx context.Context, in *ping.KeyExchangeRequest, opts ...grpc.CallOption) (*ping.ExitCode, error) {
+	f.proposed = append(f.proposed, in)
+	return &ping.ExitCode{}, nil
+}
+
+func (f *fakeServer) SendMessage(ctx context.Context, in *ping.MessageRequest, opts ...grpc.CallOption) (*ping.ExitCode, error) {
+	f.sent = append(f.sent, in)
+	return &ping.ExitCode{}, nil
+}
+
+func (f *fakeServer) AcknowledgeMessage(ctx context.Context, in *ping.AckRequest, opts ...grpc.CallOption) (*ping.ExitCode, error) {
+	f.acked = append(f.acked, in.MessageId)
+	return &ping.ExitCode{}, nil
+}
+
+// newTestClient creates a client for username on its own fake server.
+func newTestClient(username string) (*Client, *fakeServer) {
+	f := &fakeServer{from: username}
+	return NewClient(f, username), f
+}
+
+// deliverKeys passes the key exchanges proposed through from on to c, as the
+// server would.
+func deliverKeys(t *testing.T, from *fakeServer, c *Client) {
+	t.Helper()
+	proposed := from.proposed
+	from.proposed = nil
+	for i, req := range proposed {
+		msgType := "KeyExchangeResponse"
+		if req.Init {
+			msgType = "KeyExchangeInit"
+		}
+		msg := &ping.ServerMessage{MessageResponse: &ping.MessageResponse{
+			Type:    msgType,
+			Sender:  from.from,
+			Content: base64.StdEncoding.EncodeToString(req.PublicKey),
+			Id:      uint64(i + 1),
+		}}
+		if exchange, err := c.HandleServerMessage(context.Background(), msg); err != nil || !exchange {
+			t.Fatalf("HandleServerMessage(%s) = %v, %v, want a key exchange", msgType, exchange, err)
+		}
+	}
+}
+
+// receive passes the last message sent through from on to c and returns
+// the text c sees.
+func receive(t *testing.T, from *fakeServer, c *Client) string {
+	t.Helper()
+	req := from.sent[len(from.sent)-1]
+	msg := &ping.ServerMessage{MessageResponse: &ping.MessageResponse{Type: "Message", Sender: req.Client, Content: req.Message}}
+	if exchange, err := c.HandleServerMessage(context.Background(), msg); err != nil || exchange {
+		t.Fatalf("HandleServerMessage() = %v, %v, want a message", exchange, err)
+	}
+	return msg.MessageResponse.Content
+}
+
+func TestKeyExchange(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		aliceFirst bool
+		bobFirst   bool
+	}{
+		{"alice proposes", true, false},
+		{"bob proposes", false, true},
+		{"both propose", true, true}, // The smaller name waits for the other side
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alice, toBob := newTestClient("alice")
+			bob, toAlice := newTestClient("bob")
+			if tt.aliceFirst {
+				if err := alice.StartKeyExchange(ctx, "bob"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.bobFirst {
+				if err := bob.StartKeyExchange(ctx, "alice"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for range 2 {
+				deliverKeys(t, toBob, bob)
+				deliverKeys(t, toAlice, alice)
+			}
+			if !alice.HasSession("bob") || !bob.HasSession("alice") {
+				t.Fatalf("sessions: alice %v, bob %v, want both", alice.HasSession("bob"), bob.HasSession("alice"))
+			}
+			if len(toBob.acked) == 0 || len(toAlice.acked) == 0 {
+				t.Errorf("key exchanges not acknowledged: alice %v, bob %v", toBob.acked, toAlice.acked)
+			}
+
+			// Both ends derived the same key
+			if _, err := alice.SendMessage(ctx, "bob", "hello bob"); err != nil {
+				t.Fatal(err)
+			}
+			if content := toBob.sent[0].Message; !strings.HasPrefix(content, Prefix) || strings.Contains(content, "hello bob") {
+				t.Errorf("sent %q, want it encrypted", content)
+			}
+			if text := receive(t, toBob, bob); text != "hello bob" {
+				t.Errorf("bob got %q, want hello bob", text)
+			}
+			if _, err := bob.SendMessage(ctx, "alice", "hello alice"); err != nil {
+				t.Fatal(err)
+			}
+			if text := receive(t, toAlice, alice); text != "hello alice" {
+				t.Errorf("alice got %q, want hello alice", text)
+			}
+		})
+	}
+}
+
+func TestNoSession(t *testing.T) {
+	alice, _ := newTestClient("alice")
+	if _, err := alice.SendMessage(context.Background(), "bob", "hi"); err == nil {
+		t.Error("SendMessage() without a session succeeded")
+	}
+	// Without a session, messages stay as they are
+	msg := &ping.ServerMessage{MessageResponse: &ping.MessageResponse{Type: "Message", Sender: "bob", Content: "plain"}}
+	if _, err := alice.HandleServerMessage(context.Background(), msg); err != nil || msg.MessageResponse.Content != "plain" {
+		t.Errorf("HandleServerMessage() = %v, content %q, want plain", err, msg.MessageResponse.Content)
+	}
+	// Responses to exchanges nobody started are refused
+	toAlice := &fakeServer{from: "bob"}
+	bob := NewClient(toAlice, "bob")
+	bob.StartKeyExchange(context.Background(), "alice")
+	toAlice.proposed[0].Init = false
+	msg = &ping.ServerMessage{MessageResponse: &ping.MessageResponse{Type: "KeyExchangeResponse", Sender: "bob", Content: base64.StdEncoding.EncodeToString(toAlice.proposed[0].PublicKey)}}
+	if _, err := alice.HandleServerMessage(context.Background(), msg); err == nil || alice.HasSession("bob") {
+		t.Errorf("HandleServerMessage() of an unexpected response = %v, want an error", err)
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	alice, toBob := newTestClient("alice")
+	bob, toAlice := newTestClient("bob")
+	alice.StartKeyExchange(context.Background(), "bob")
+	deliverKeys(t, toBob, bob)
+	deliverKeys(t, toAlice, alice)
+	session := alice.sessions["bob"]
+	sealed, err := session.Seal("alice", "bob", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, Prefix))
+	raw[len(raw)-1] ^= 1
+	tampered := Prefix + base64.StdEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name              string
+		sender, recipient string
+		content           string
+		want              string
+		wantErr           bool
+	}{
+		{"round trip", "alice", "bob", sealed, "secret", false},
+		{"other direction", "bob", "alice", sealed, "", true},
+		{"tampered", "alice", "bob", tampered, "", true},
+		{"not base64", "alice", "bob", Prefix + "!!!", "", true},
+		{"too short", "alice", "bob", Prefix + "AAAA", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := bob.sessions["alice"].Open(tt.sender, tt.recipient, tt.content)
+			if (err != nil) != tt.wantErr || text != tt.want {
+				t.Errorf("Open() = %q, %v, want %q", text, err, tt.want)
+			}
+		})
+	}
+	if _, err := session.Open("alice", "bob", "plain"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Open() of plain text = %v, want %v", err, ErrNotEncrypted)
+	}
+}
@@ -0,0 +1,87 @@
+package e2ee
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Prefix marks message contents encrypted by this package.
+const Prefix = "e2ee:v1:"
+
+var ErrNotEncrypted = errors.New("message is not end-to-end encrypted")
+
+// Session encrypts messages between two users with a key derived from an
+// X25519 key agreement.
+type Session struct {
+	aead cipher.AEAD
+}
+
+// newSession derives the session key from our private key and the peer's
+// public key. Both sides end up with the same key, because the public keys are
+// fed into HKDF in a fixed order.
+func newSession(private *ecdh.PrivateKey, peerPublic *ecdh.PublicKey) (*Session, error) {
+	shared, err := private.ECDH(peerPublic)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %v", err)
+	}
+
+	a, b := private.PublicKey().Bytes(), peerPublic.Bytes()
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	salt := append(append([]byte{}, a...), b...)
+
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte("ping e2ee v1")), key); err != nil {
+		return nil, fmt.Errorf("key derivation failed: %v", err)
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{aead: aead}, nil
+}
+
+// Seal encrypts plaintext sent from sender to recipient. The names are
+// authenticated, so a message cannot be replayed in the other direction.
+func (s *Session) Seal(sender, recipient, plaintext string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(plaintext)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), additionalData(sender, recipient))
+	return Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts content sent from sender to recipient.
+func (s *Session) Open(sender, recipient, content string) (string, error) {
+	encoded, ok := strings.CutPrefix(content, Prefix)
+	if !ok {
+		return "", ErrNotEncrypted
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return "", errors.New("malformed encrypted message")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, additionalData(sender, recipient))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt message: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+func additionalData(sender, recipient string) []byte {
+	return []byte(sender + "\x00" + recipient)
+}
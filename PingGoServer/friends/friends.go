@@ -0,0 +1,199 @@
+package friends
+
+import (
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Status is how a user relates to someone in their friend list.
+type Status byte
+
+const (
+	Friend   Status = iota + 1
+	Incoming        // They sent us a friend request
+	Outgoing        // We sent them a friend request
+	Blocked         // We blocked them
+)
+
+var friendsBucket = []byte("friends") // username -> bucket of other username -> Status
+
+var (
+	ErrSelf           = errors.New("cannot befriend yourself")
+	ErrAlreadyFriends = errors.New("already friends")
+	ErrAlreadySent    = errors.New("friend request already sent")
+	ErrNoRequest      = errors.New("no pending friend request")
+	ErrNotFriends     = errors.New("not friends")
+	ErrBlocked        = errors.New("user is blocked")
+	ErrNotBlocked     = errors.New("user is not blocked")
+)
+
+// Relation is one entry of a user's friend list.
+type Relation struct {
+	Username string
+	Status   Status
+}
+
+// Store keeps the friend graph in a bolt database. Every relation is stored
+// on both sides, e.g. a request is Outgoing for the sender and Incoming for
+// the recipient, so listing a user's friends is a single bucket scan.
+type Store struct {
+	db *bolt.DB
+}
+
+// NewStore creates the friends bucket in db if needed.
+func NewStore(db *bolt.DB) (*Store, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(friendsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create friends bucket: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Request sends a friend request from user to other. If other already asked
+// user, the two simply become friends. It returns the resulting status.
+func (s *Store) Request(user, other string) (Status, error) {
+	if user == other {
+		return 0, ErrSelf
+	}
+	var result Status
+	err := s.update(user, other, func(mine, theirs Status) (Status, Status, error) {
+		switch {
+		case mine == Blocked || theirs == Blocked:
+			return 0, 0, ErrBlocked
+		case mine == Friend:
+			return 0, 0, ErrAlreadyFriends
+		case mine == Outgoing:
+			return 0, 0, ErrAlreadySent
+		case mine == Incoming:
+			result = Friend
+			return Friend, Friend, nil
+		}
+		result = Outgoing
+		return Outgoing, Incoming, nil
+	})
+	return result, err
+}
+
+// Accept accepts the pending friend request other sent to user.
+func (s *Store) Accept(user, other string) error {
+	return s.update(user, other, func(mine, theirs Status) (Status, Status, error) {
+		if mine != Incoming {
+			return 0, 0, ErrNoRequest
+		}
+		return Friend, Friend, nil
+	})
+}
+
+// Decline rejects the pending friend request other sent to user.
+func (s *Store) Decline(user, other string) error {
+	return s.update(user, other, func(mine, theirs Status) (Status, Status, error) {
+		if mine != Incoming {
+			return 0, 0, ErrNoRequest
+		}
+		return 0, 0, nil
+	})
+}
+
+// Remove ends a friendship, or withdraws a request user sent to other.
+func (s *Store) Remove(user, other string) error {
+	return s.update(user, other, func(mine, theirs Status) (Status, Status, error) {
+		if mine != Friend && mine != Outgoing {
+			return 0, 0, ErrNotFriends
+		}
+		return 0, 0, nil
+	})
+}
+
+// Block drops any relation between user and other and stops other from
+// sending user friend requests.
+func (s *Store) Block(user, other string) error {
+	if user == other {
+		return ErrSelf
+	}
+	return s.update(user, other, func(mine, theirs Status) (Status, Status, error) {
+		if theirs == Blocked {
+			return Blocked, Blocked, nil // Both sides blocked each other
+		}
+		return Blocked, 0, nil
+	})
+}
+
+// Unblock lifts a block set by user.
+func (s *Store) Unblock(user, other string) error {
+	return s.update(user, other, func(mine, theirs Status) (Status, Status, error) {
+		if mine != Blocked {
+			return 0, 0, ErrNotBlocked
+		}
+		return 0, theirs, nil
+	})
+}
+
+// IsBlocked reports whether user has blocked other.
+func (s *Store) IsBlocked(user, other string) (bool, error) {
+	var blocked bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		blocked = get(tx, user, other) == Blocked
+		return nil
+	})
+	return blocked, err
+}
+
+// List returns all of user's relations, sorted by username.
+func (s *Store) List(user string) ([]Relation, error) {
+	var relations []Relation
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(friendsBucket).Bucket([]byte(user))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			relations = append(relations, Relation{Username: string(k), Status: Status(v[0])})
+			return nil
+		})
+	})
+	return relations, err
+}
+
+// update reads both sides of the relation between user and other, lets fn
+// decide the new statuses and writes them back. A zero status removes that
+// side of the relation.
+func (s *Store) update(user, other string, fn func(mine, theirs Status) (Status, Status, error)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		mine, theirs, err := fn(get(tx, user, other), get(tx, other, user))
+		if err != nil {
+			return err
+		}
+		if err := put(tx, user, other, mine); err != nil {
+			return err
+		}
+		return put(tx, other, user, theirs)
+	})
+}
+
+func get(tx *bolt.Tx, user, other string) Status {
+	b := tx.Bucket(friendsBucket).Bucket([]byte(user))
+	if b == nil {
+		return 0
+	}
+	v := b.Get([]byte(other))
+	if len(v) == 0 {
+		return 0
+	}
+	return Status(v[0])
+}
+
+func put(tx *bolt.Tx, user, other string, status Status) error {
+	b, err := tx.Bucket(friendsBucket).CreateBucketIfNotExists([]byte(user))
+	if err != nil {
+		return err
+	}
+	if status == 0 {
+		return b.Delete([]byte(other))
+	}
+	return b.Put([]byte(other), []byte{byte(status)})
+}
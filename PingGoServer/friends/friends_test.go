@@ -0,0 +1,147 @@
+package friends
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// newTestStore opens a friend store in a temporary bolt database.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "ping.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// step is a change of the relation between two users.
+type step struct {
+	op          string
+	user, other string
+}
+
+func (st step) run(s *Store) error {
+	switch st.op {
+	case "request":
+		_, err := s.Request(st.user, st.other)
+		return err
+	case "accept":
+		return s.Accept(st.user, st.other)
+	case "decline":
+		return s.Decline(st.user, st.other)
+	case "remove":
+		return s.Remove(st.user, st.other)
+	case "block":
+		return s.Block(st.user, st.other)
+	case "unblock":
+		return s.Unblock(st.user, st.other)
+	}
+	panic("unknown op " + st.op)
+}
+
+// status returns how user relates to other, 0 for not at all.
+func status(t *testing.T, s *Store, user, other string) Status {
+	t.Helper()
+	relations, err := s.List(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range relations {
+		if r.Username == other {
+			return r.Status
+		}
+	}
+	return 0
+}
+
+func TestRelations(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup []step
+		step  step
+		want  error
+		alice Status // How alice relates to bob afterwards
+		bob   Status // How bob relates to alice afterwards
+	}{
+		{"request", nil, step{"request", "alice", "bob"}, nil, Outgoing, Incoming},
+		{"request yourself", nil, step{"request", "alice", "alice"}, ErrSelf, 0, 0},
+		{"request twice", []step{{"request", "alice", "bob"}}, step{"request", "alice", "bob"}, ErrAlreadySent, Outgoing, Incoming},
+		{"request back", []step{{"request", "alice", "bob"}}, step{"request", "bob", "alice"}, nil, Friend, Friend},
+		{"request a friend", []step{{"request", "alice", "bob"}, {"accept", "bob", "alice"}}, step{"request", "alice", "bob"}, ErrAlreadyFriends, Friend, Friend},
+		{"accept", []step{{"request", "alice", "bob"}}, step{"accept", "bob", "alice"}, nil, Friend, Friend},
+		{"accept own request", []step{{"request", "alice", "bob"}}, step{"accept", "alice", "bob"}, ErrNoRequest, Outgoing, Incoming},
+		{"accept without request", nil, step{"accept", "bob", "alice"}, ErrNoRequest, 0, 0},
+		{"decline", []step{{"request", "alice", "bob"}}, step{"decline", "bob", "alice"}, nil, 0, 0},
+		{"decline without request", nil, step{"decline", "bob", "alice"}, ErrNoRequest, 0, 0},
+		{"withdraw request", []step{{"request", "alice", "bob"}}, step{"remove", "alice", "bob"}, nil, 0, 0},
+		{"remove friend", []step{{"request", "alice", "bob"}, {"accept", "bob", "alice"}}, step{"remove", "bob", "alice"}, nil, 0, 0},
+		{"remove stranger", nil, step{"remove", "alice", "bob"}, ErrNotFriends, 0, 0},
+		{"block friend", []step{{"request", "alice", "bob"}, {"accept", "bob", "alice"}}, step{"block", "alice", "bob"}, nil, Blocked, 0},
+		{"block yourself", nil, step{"block", "alice", "alice"}, ErrSelf, 0, 0},
+		{"block back", []step{{"block", "bob", "alice"}}, step{"block", "alice", "bob"}, nil, Blocked, Blocked},
+		{"request blocked", []step{{"block", "alice", "bob"}}, step{"request", "alice", "bob"}, ErrBlocked, Blocked, 0},
+		{"request blocker", []step{{"block", "alice", "bob"}}, step{"request", "bob", "alice"}, ErrBlocked, Blocked, 0},
+		{"unblock", []step{{"block", "alice", "bob"}}, step{"unblock", "alice", "bob"}, nil, 0, 0},
+		{"unblock mutual", []step{{"block", "bob", "alice"}, {"block", "alice", "bob"}}, step{"unblock", "alice", "bob"}, nil, 0, Blocked},
+		{"unblock not blocked", nil, step{"unblock", "alice", "bob"}, ErrNotBlocked, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			for _, st := range tt.setup {
+				if err := st.run(s); err != nil {
+					t.Fatalf("%s %s %s: %v", st.user, st.op, st.other, err)
+				}
+			}
+			if err := tt.step.run(s); !errors.Is(err, tt.want) {
+				t.Fatalf("%s = %v, want %v", tt.step.op, err, tt.want)
+			}
+			if got := status(t, s, "alice", "bob"); got != tt.alice {
+				t.Errorf("alice's status of bob = %d, want %d", got, tt.alice)
+			}
+			if got := status(t, s, "bob", "alice"); got != tt.bob {
+				t.Errorf("bob's status of alice = %d, want %d", got, tt.bob)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	s := newTestStore(t)
+	for _, st := range []step{
+		{"request", "alice", "carol"},
+		{"request", "bob", "alice"},
+		{"request", "alice", "dave"},
+		{"accept", "dave", "alice"},
+		{"block", "alice", "eve"},
+	} {
+		if err := st.run(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	relations, err := s.List("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Relation{{"bob", Incoming}, {"carol", Outgoing}, {"dave", Friend}, {"eve", Blocked}}
+	if !slices.Equal(relations, want) {
+		t.Errorf("List() = %v, want %v", relations, want)
+	}
+	if blocked, err := s.IsBlocked("alice", "eve"); err != nil || !blocked {
+		t.Errorf("IsBlocked(alice, eve) = %v, %v, want true", blocked, err)
+	}
+	if blocked, err := s.IsBlocked("eve", "alice"); err != nil || blocked {
+		t.Errorf("IsBlocked(eve, alice) = %v, %v, want false", blocked, err)
+	}
+}
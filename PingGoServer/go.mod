@@ -3,6 +3,9 @@ module github.com/kallazz/Ping
 go 1.23.4
 
 require (
+	github.com/kallazz/Ping/PingBridge v0.0.0
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/crypto v0.28.0
 	google.golang.org/grpc v1.69.2
 	google.golang.org/protobuf v1.36.1
 )
@@ -13,3 +16,5 @@ require (
 	golang.org/x/text v0.19.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20241015192408-796eee8c2d53 // indirect
 )
+
+replace github.com/kallazz/Ping/PingBridge => ../PingBridge
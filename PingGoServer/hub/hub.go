@@ -0,0 +1,219 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// Policy decides what happens when a subscriber's buffer is full.
+type Policy int
+
+const (
+	// DropOldest discards the oldest buffered message to make room.
+	DropOldest Policy = iota
+	// DropNewest discards the message being published.
+	DropNewest
+	// Disconnect drops the subscriber altogether.
+	Disconnect
+)
+
+var (
+	ErrSlowConsumer = errors.New("disconnected for not keeping up with messages")
+	ErrReplaced     = errors.New("replaced by a newer subscription with the same client ID")
+)
+
+// ParsePolicy parses "drop-oldest", "drop-newest" or "disconnect".
+func ParsePolicy(s string) (Policy, error) {
+	switch s {
+	case "drop-oldest":
+		return DropOldest, nil
+	case "drop-newest":
+		return DropNewest, nil
+	case "disconnect":
+		return Disconnect, nil
+	}
+	return 0, fmt.Errorf("unknown slow consumer policy %q", s)
+}
+
+// Hub fans messages out to subscribers without ever blocking the publisher.
+// Every subscriber has its own bounded buffer, drained by its own goroutine
+// (see Subscriber.Serve), so one slow client cannot stall the others.
+type Hub struct {
+	mu         sync.RWMutex
+	subs       map[string]*Subscriber
+	bufferSize int
+	policy     Policy
+}
+
+// New creates a hub whose subscribers buffer up to bufferSize messages.
+func New(bufferSize int, policy Policy) *Hub {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &Hub{
+		subs:       make(map[string]*Subscriber),
+		bufferSize: bufferSize,
+		policy:     policy,
+	}
+}
+
+// Subscriber is a single client registered in the hub.
+type Subscriber struct {
+	ID string
+
+	mu     sync.Mutex // Serializes publishers, so drop-oldest can make room
+	ch     chan *ping.ServerMessage
+	done   chan struct{}
+	err    error
+	policy Policy
+}
+
+// Subscribe registers a client. An existing subscription with the same ID is
+// closed with ErrReplaced.
+func (h *Hub) Subscribe(id string) *Subscriber {
+	sub := &Subscriber{
+		ID:     id,
+		ch:     make(chan *ping.ServerMessage, h.bufferSize),
+		done:   make(chan struct{}),
+		policy: h.policy,
+	}
+
+	h.mu.Lock()
+	old := h.subs[id]
+	h.subs[id] = sub
+	h.mu.Unlock()
+
+	if old != nil {
+		old.close(ErrReplaced)
+	}
+	return sub
+}
+
+// Unsubscribe removes the subscriber, unless it was already replaced.
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	if h.subs[sub.ID] == sub {
+		delete(h.subs, sub.ID)
+	}
+	h.mu.Unlock()
+	sub.close(nil)
+}
+
+// Connected reports whether a client with this ID is subscribed.
+func (h *Hub) Connected(id string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.subs[id]
+	return ok
+}
+
+// Publish queues msg for every subscriber.
+func (h *Hub) Publish(msg *ping.ServerMessage) {
+	h.mu.RLock()
+	subs := make([]*Subscriber, 0, len(h.subs))
+	for _, sub := range h.subs {
+		subs = append(subs, sub)
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range subs {
+		h.deliver(sub, msg)
+	}
+}
+
+// Send queues msg for a single subscriber. It returns false if the client is
+// not subscribed.
+func (h *Hub) Send(id string, msg *ping.ServerMessage) bool {
+	h.mu.RLock()
+	sub, ok := h.subs[id]
+	h.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	h.deliver(sub, msg)
+	return true
+}
+
+func (h *Hub) deliver(sub *Subscriber, msg *ping.ServerMessage) {
+	if !sub.offer(msg) {
+		fmt.Printf("Client %s is not keeping up, disconnecting\n", sub.ID)
+		h.mu.Lock()
+		if h.subs[sub.ID] == sub {
+			delete(h.subs, sub.ID)
+		}
+		h.mu.Unlock()
+		sub.close(ErrSlowConsumer)
+	}
+}
+
+// offer tries to buffer msg without blocking. It returns false if the
+// subscriber should be disconnected.
+func (s *Subscriber) offer(msg *ping.ServerMessage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return true // Already gone, nothing to do
+	default:
+	}
+
+	for {
+		select {
+		case s.ch <- msg:
+			return true
+		default:
+		}
+
+		switch s.policy {
+		case DropNewest:
+			fmt.Printf("Buffer of client %s is full, dropping newest message\n", s.ID)
+			return true
+		case Disconnect:
+			return false
+		}
+
+		// DropOldest: make room and try again. The subscriber may have drained
+		// the buffer in the meantime, in which case nothing is dropped.
+		select {
+		case <-s.ch:
+			fmt.Printf("Buffer of client %s is full, dropped oldest message\n", s.ID)
+		default:
+		}
+	}
+}
+
+func (s *Subscriber) close(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+	default:
+		s.err = err
+		close(s.done)
+	}
+}
+
+// Serve passes buffered messages to send until ctx is done, send fails or the
+// hub drops the subscriber. It is meant to run in the subscriber's own
+// goroutine, e.g. the gRPC stream handler.
+func (s *Subscriber) Serve(ctx context.Context, send func(*ping.ServerMessage) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.done:
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.err
+		case msg := <-s.ch:
+			if err := send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// message creates a message with cursor id.
+func message(id uint64) *ping.ServerMessage {
+	return &ping.ServerMessage{Cursor: id}
+}
+
+// drain returns the cursors of the messages sub has buffered.
+func drain(sub *Subscriber) []uint64 {
+	var ids []uint64
+	for {
+		select {
+		case msg := <-sub.ch:
+			ids = append(ids, msg.Cursor)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Policy
+		wantErr bool
+	}{
+		{"drop-oldest", DropOldest, false},
+		{"drop-newest", DropNewest, false},
+		{"disconnect", Disconnect, false},
+		{"block", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParsePolicy(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestSlowConsumer(t *testing.T) {
+	tests := []struct {
+		policy    Policy
+		want      []uint64 // Buffered after publishing 1 to 5
+		connected bool
+		wantErr   error // Returned by Serve if disconnected
+	}{
+		{DropOldest, []uint64{3, 4, 5}, true, nil},
+		{DropNewest, []uint64{1, 2, 3}, true, nil},
+		{Disconnect, []uint64{1, 2, 3}, false, ErrSlowConsumer},
+	}
+	for _, tt := range tests {
+		h := New(3, tt.policy)
+		slow := h.Subscribe("slow")
+		for id := uint64(1); id <= 5; id++ {
+			h.Publish(message(id))
+		}
+		if got := drain(slow); !slices.Equal(got, tt.want) {
+			t.Errorf("policy %v: buffered %v, want %v", tt.policy, got, tt.want)
+		}
+		if h.Connected("slow") != tt.connected {
+			t.Errorf("policy %v: Connected() = %v, want %v", tt.policy, !tt.connected, tt.connected)
+		}
+		if tt.connected {
+			continue
+		}
+		if err := slow.Serve(context.Background(), nil); !errors.Is(err, tt.wantErr) {
+			t.Errorf("policy %v: Serve() = %v, want %v", tt.policy, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSlowConsumerAlone(t *testing.T) {
+	// A slow subscriber doesn't hold up the others
+	h := New(1, Disconnect)
+	h.Subscribe("slow")
+	fast := h.Subscribe("fast")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan uint64, 3)
+	go fast.Serve(ctx, func(msg *ping.ServerMessage) error {
+		received <- msg.Cursor
+		return nil
+	})
+
+	for id := uint64(1); id <= 3; id++ {
+		h.Publish(message(id))
+		select {
+		case got := <-received:
+			if got != id {
+				t.Fatalf("fast subscriber got %d, want %d", got, id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("fast subscriber did not get message %d", id)
+		}
+	}
+	if h.Connected("slow") {
+		t.Error("slow subscriber still connected")
+	}
+}
+
+func TestSend(t *testing.T) {
+	h := New(10, DropOldest)
+	alice := h.Subscribe("alice")
+	bob := h.Subscribe("bob")
+	if !h.Send("alice", message(1)) {
+		t.Error("Send() to a subscriber = false")
+	}
+	if h.Send("carol", message(2)) {
+		t.Error("Send() to an unknown client = true")
+	}
+	if got := drain(alice); !slices.Equal(got, []uint64{1}) {
+		t.Errorf("alice got %v, want [1]", got)
+	}
+	if got := drain(bob); len(got) > 0 {
+		t.Errorf("bob got %v, want nothing", got)
+	}
+}
+
+func TestReplaced(t *testing.T) {
+	h := New(10, DropOldest)
+	old := h.Subscribe("alice")
+	newer := h.Subscribe("alice")
+	if err := old.Serve(context.Background(), nil); !errors.Is(err, ErrReplaced) {
+		t.Errorf("Serve() of the replaced subscription = %v, want %v", err, ErrReplaced)
+	}
+	// Unsubscribing the old one leaves the new one in place
+	h.Unsubscribe(old)
+	if !h.Send("alice", message(1)) {
+		t.Error("Send() after unsubscribing the replaced subscription = false")
+	}
+	if got := drain(newer); !slices.Equal(got, []uint64{1}) {
+		t.Errorf("new subscription got %v, want [1]", got)
+	}
+	h.Unsubscribe(newer)
+	if h.Connected("alice") {
+		t.Error("Connected() after Unsubscribe() = true")
+	}
+}
@@ -0,0 +1,153 @@
+package inbox
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	bolt "go.etcd.io/bbolt"
+)
+
+var inboxBucket = []byte("inbox") // username -> bucket of entry ID -> Entry
+
+var ErrNotFound = errors.New("no such message in inbox")
+
+// Entry is a message waiting in a user's inbox until it is acknowledged.
+type Entry struct {
+	ID             uint64    `json:"id"` // Increasing per recipient
+	Type           string    `json:"type"`
+	Sender         string    `json:"sender"`
+	Content        string    `json:"content"`
+	AcknowledgedID uint64    `json:"acknowledged_id,omitempty"` // For receipts
+	SentAt         time.Time `json:"sent_at"`
+	Delivered      bool      `json:"delivered"`
+}
+
+// ServerMessage converts the entry into what gets sent to the recipient.
+func (e *Entry) ServerMessage() *ping.ServerMessage {
+	return &ping.ServerMessage{
+		MessageResponse: &ping.MessageResponse{
+			Type:           e.Type,
+			Content:        e.Content,
+			Sender:         e.Sender,
+			Id:             e.ID,
+			Timestamp:      e.SentAt.UnixMilli(),
+			AcknowledgedId: e.AcknowledgedID,
+		},
+		ExitCode: &ping.ExitCode{Status: 0, Message: "Message enqueued"},
+	}
+}
+
+// Store keeps a durable inbox per user in a bolt database, so messages sent
+// to offline users are delivered once they connect.
+type Store struct {
+	db *bolt.DB
+}
+
+// NewStore creates the inbox bucket in db if needed.
+func NewStore(db *bolt.DB) (*Store, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(inboxBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inbox bucket: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Enqueue stores entry in recipient's inbox, assigning its ID and timestamp.
+func (s *Store) Enqueue(recipient string, entry *Entry) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(inboxBucket).CreateBucketIfNotExists([]byte(recipient))
+		if err != nil {
+			return err
+		}
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		entry.ID = id
+		entry.SentAt = time.Now().UTC()
+		return putEntry(b, entry)
+	})
+}
+
+// Pending returns the entries recipient has not acknowledged as delivered yet,
+// oldest first.
+func (s *Store) Pending(recipient string) ([]*Entry, error) {
+	var entries []*Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(inboxBucket).Bucket([]byte(recipient))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("failed to decode inbox entry %d: %v", binary.BigEndian.Uint64(k), err)
+			}
+			if !entry.Delivered {
+				entries = append(entries, &entry)
+			}
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// MarkDelivered keeps the entry around, e.g. until it is read, but stops it
+// from being delivered again.
+func (s *Store) MarkDelivered(recipient string, id uint64) (*Entry, error) {
+	return s.modify(recipient, id, func(b *bolt.Bucket, entry *Entry) error {
+		entry.Delivered = true
+		return putEntry(b, entry)
+	})
+}
+
+// Remove deletes the entry from the inbox.
+func (s *Store) Remove(recipient string, id uint64) (*Entry, error) {
+	return s.modify(recipient, id, func(b *bolt.Bucket, entry *Entry) error {
+		return b.Delete(itob(id))
+	})
+}
+
+func (s *Store) modify(recipient string, id uint64, fn func(*bolt.Bucket, *Entry) error) (*Entry, error) {
+	var entry Entry
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(inboxBucket).Bucket([]byte(recipient))
+		if b == nil {
+			return ErrNotFound
+		}
+		data := b.Get(itob(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		return fn(b, &entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func putEntry(b *bolt.Bucket, entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(entry.ID), data)
+}
+
+// itob encodes an ID as a big endian key, so bolt keeps entries in ID order.
+func itob(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
@@ -0,0 +1,123 @@
+package inbox
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// newTestStore opens an inbox store in a temporary bolt database.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "ping.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// pendingIDs returns the IDs of recipient's pending entries.
+func pendingIDs(t *testing.T, s *Store, recipient string) []uint64 {
+	t.Helper()
+	entries, err := s.Pending(recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint64
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestEnqueue(t *testing.T) {
+	s := newTestStore(t)
+	for _, recipient := range []string{"bob", "bob", "carol", "bob"} {
+		if err := s.Enqueue(recipient, &Entry{Type: "Message", Sender: "alice", Content: "hi " + recipient}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// IDs count per recipient
+	if ids := pendingIDs(t, s, "bob"); !slices.Equal(ids, []uint64{1, 2, 3}) {
+		t.Errorf("bob's pending IDs = %v, want [1 2 3]", ids)
+	}
+	entries, err := s.Pending("carol")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Pending(carol) = %v, %v, want 1 entry", entries, err)
+	}
+	e := entries[0]
+	if e.ID != 1 || e.Sender != "alice" || e.Content != "hi carol" || e.SentAt.IsZero() {
+		t.Errorf("carol's entry = %+v, want 1 from alice", e)
+	}
+	if m := e.ServerMessage().GetMessageResponse(); m.Id != 1 || m.Content != "hi carol" || m.Timestamp != e.SentAt.UnixMilli() {
+		t.Errorf("ServerMessage() = %v", m)
+	}
+	if ids := pendingIDs(t, s, "dave"); len(ids) != 0 {
+		t.Errorf("dave's pending IDs = %v, want none", ids)
+	}
+}
+
+func TestAcknowledge(t *testing.T) {
+	tests := []struct {
+		name    string
+		ack     func(s *Store, recipient string, id uint64) (*Entry, error)
+		pending []uint64 // What is still delivered afterwards
+		kept    bool     // Whether the entry can still be acknowledged
+	}{
+		{"delivered", (*Store).MarkDelivered, []uint64{1, 3}, true},
+		{"removed", (*Store).Remove, []uint64{1, 3}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			for range 3 {
+				if err := s.Enqueue("bob", &Entry{Type: "Message", Sender: "alice"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			entry, err := tt.ack(s, "bob", 2)
+			if err != nil || entry.ID != 2 || entry.Sender != "alice" {
+				t.Fatalf("acknowledging 2 = %+v, %v, want entry 2", entry, err)
+			}
+			if ids := pendingIDs(t, s, "bob"); !slices.Equal(ids, tt.pending) {
+				t.Errorf("pending IDs = %v, want %v", ids, tt.pending)
+			}
+			// Read receipts remove entries that were delivered before
+			if _, err := s.Remove("bob", 2); (err == nil) != tt.kept {
+				t.Errorf("Remove() after acknowledging = %v, want kept %v", err, tt.kept)
+			}
+		})
+	}
+}
+
+func TestAcknowledgeUnknown(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Enqueue("bob", &Entry{Type: "Message", Sender: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		recipient string
+		id        uint64
+	}{
+		{"bob", 2},
+		{"carol", 1}, // Nobody else can acknowledge bob's messages
+	}
+	for _, tt := range tests {
+		if _, err := s.MarkDelivered(tt.recipient, tt.id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("MarkDelivered(%s, %d) = %v, want %v", tt.recipient, tt.id, err, ErrNotFound)
+		}
+		if _, err := s.Remove(tt.recipient, tt.id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Remove(%s, %d) = %v, want %v", tt.recipient, tt.id, err, ErrNotFound)
+		}
+	}
+}
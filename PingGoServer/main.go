@@ -2,20 +2,75 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"os"
+	"slices"
 	"sync"
 	"time"
 
-	ping "github.com/kallazz/Ping/pb"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/auth"
+	"github.com/kallazz/Ping/blobs"
+	"github.com/kallazz/Ping/hub"
+	"github.com/kallazz/Ping/rooms"
+	"github.com/kallazz/Ping/seen"
+	"github.com/kallazz/Ping/store"
+	bolt "go.etcd.io/bbolt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+// bridgeSecretKey is the gRPC metadata entry in which bridges send
+// BRIDGE_SECRET, see pingclient.SecretKey.
+const bridgeSecretKey = "bridge-secret"
+
+var (
+	DBPath     string
+	BufferSize int
+	SlowPolicy string
+	MaxHops    uint
+	SeenSize   int
+	BlobDir    string
+	MaxBlobMB  int64
+)
+
+func init() {
+	flag.StringVar(&DBPath, "db", "ping.db", "Path to the message database")
+	flag.IntVar(&BufferSize, "buffer", 100, "Number of messages buffered per client")
+	flag.StringVar(&SlowPolicy, "slow-policy", "drop-oldest", "What to do with clients whose buffer is full: drop-oldest, drop-newest or disconnect")
+	flag.UintVar(&MaxHops, "max-hops", 3, "Drop messages that were already relayed this many times")
+	flag.IntVar(&SeenSize, "seen", 10000, "Number of platform message IDs remembered to drop duplicates, 0 to remember none")
+	flag.StringVar(&BlobDir, "blobs", "ping-blobs", "Directory to keep attachments in")
+	flag.Int64Var(&MaxBlobMB, "max-blob-mb", 50, "Largest attachment accepted, in megabytes")
+}
+
 type Server struct {
 	ping.UnimplementedPingServiceServer
-	clientStreams map[string]ping.PingService_ReceiveMessagesServer // Map to store client streams
-	mu            sync.Mutex
+	hub      *hub.Hub // Fans messages out to connected clients
+	mu       sync.Mutex
+	messages *store.MessageStore // Every message is persisted here before broadcasting
+	rooms    *rooms.Store
+	seen     *seen.Set // Platform message IDs relayed recently
+	blobs    *blobs.Store
+
+	// Clients receiving a room's messages without being a member, because
+	// they listed it when calling ReceiveMessages. Guarded by mu.
+	roomSubs map[string]map[string]*hub.Subscriber
+
+	// Shared with the bridges, only they may list rooms, see isBridge
+	bridgeSecret string
+
+	// Verifies the session tokens of everyone else, see authenticate
+	sessions *auth.Signer
 }
 
 // func (s *Server) ReceiveMessages(ctx context.Context) (*ping.ServerMessage, error) {
@@ -24,52 +79,258 @@ type Server struct {
 
 func (s *Server) ReceiveMessages(req *ping.Empty, stream ping.PingService_ReceiveMessagesServer) error {
 	clientID := req.Client
-	fmt.Printf("Client %s connected to ReceiveMessages\n", clientID)
+	fmt.Printf("Client %s connected to ReceiveMessages (cursor %d, rooms %v, bridge %q)\n", clientID, req.Cursor, req.Rooms, req.BridgeId)
+
+	// Only bridges receive rooms they are not a member of
+	if len(req.Rooms) > 0 && !s.isBridge(stream.Context()) {
+		fmt.Printf("Client %s is no bridge, ignoring rooms %v\n", clientID, req.Rooms)
+		req.Rooms = nil
+	}
 
-	// Add client stream to the map
+	// Every bridge instance gets its own subscription
+	subID := clientID
+	if req.BridgeId != "" {
+		subID = clientID + "#" + req.BridgeId
+	}
+
+	// Replay everything after the cursor before subscribing, so a long replay
+	// doesn't fill the subscriber's buffer
+	replayed := req.Cursor
+	var err error
+	if req.Cursor > 0 {
+		if replayed, err = s.replay(stream, req, req.Cursor, 0); err != nil {
+			fmt.Printf("Error replaying messages to client %s: %v\n", clientID, err)
+			return err
+		}
+	}
+
+	// Holding the lock means no message is stored but not yet published, so
+	// everything after head reaches the subscription.
 	s.mu.Lock()
-	s.clientStreams[clientID] = stream
+	sub := s.hub.Subscribe(subID)
+	for _, room := range req.Rooms {
+		if s.roomSubs[room] == nil {
+			s.roomSubs[room] = make(map[string]*hub.Subscriber)
+		}
+		s.roomSubs[room][subID] = sub
+	}
+	head, err := s.messages.Head()
 	s.mu.Unlock()
+	defer s.unsubscribe(sub, req.Rooms)
+	if err != nil {
+		fmt.Printf("Error looking up the newest message: %v\n", err)
+		return err
+	}
 
-	// Keep the connection open
-	for {
-		select {
-		case <-stream.Context().Done():
-			fmt.Printf("Client %s disconnected from ReceiveMessages\n", clientID)
-			s.mu.Lock()
-			delete(s.clientStreams, clientID)
-			s.mu.Unlock()
+	if req.Cursor == 0 {
+		// Tell the client where its live messages start, so it can resume
+		// from there after a reconnect
+		if err := stream.Send(&ping.ServerMessage{Cursor: head}); err != nil {
+			return err
+		}
+		replayed = head
+	} else if head > replayed {
+		// Catch up with what was stored while replaying, newer messages wait
+		// in the subscription
+		if replayed, err = s.replay(stream, req, replayed, head); err != nil {
+			fmt.Printf("Error replaying messages to client %s: %v\n", clientID, err)
+			return err
+		}
+	}
+
+	err = sub.Serve(stream.Context(), func(msg *ping.ServerMessage) error {
+		if msg.Cursor <= replayed {
+			return nil // Already sent while replaying
+		}
+		if relayedBy(msg, req.BridgeId) {
+			return nil // Relayed by this bridge instance
+		}
+		fmt.Printf("Sending message to client %s %s\n", clientID, msg.MessageResponse.Content)
+		return stream.Send(msg)
+	})
+	if err != nil {
+		fmt.Printf("Error sending message to client %s: %v\n", clientID, err)
+	}
+	fmt.Printf("Client %s disconnected from ReceiveMessages\n", clientID)
+	return err
+}
+
+// replay sends the client the stored messages after cursor up to head, or all
+// of them if head is 0, leaving out the ones it may not see. It returns the ID
+// of the last message looked at.
+func (s *Server) replay(stream ping.PingService_ReceiveMessagesServer, req *ping.Empty, cursor, head uint64) (uint64, error) {
+	replayed := cursor
+	err := s.messages.After(cursor, func(msg *store.Message) error {
+		if head > 0 && msg.ID > head {
+			return errReplayed
+		}
+		replayed = msg.ID
+		if msg.Room != "" && !s.inRoom(req.Client, req.Rooms, msg.Room) {
 			return nil
-		default:
-			time.Sleep(1 * time.Second) // Keep the loop running
 		}
+		if msg.Deleted {
+			return nil
+		}
+		sm := msg.ServerMessage()
+		if relayedBy(sm, req.BridgeId) {
+			return nil
+		}
+		return stream.Send(sm)
+	})
+	if errors.Is(err, errReplayed) {
+		err = nil
+	}
+	return replayed, err
+}
+
+// errReplayed stops a replay at its head.
+var errReplayed = errors.New("replayed up to head")
+
+// relayedBy reports whether bridge instance bridgeID relayed msg to Ping, so
+// it isn't sent back. Reactions are, the bridge shows the ones made elsewhere
+// on the message it relayed.
+func relayedBy(msg *ping.ServerMessage, bridgeID string) bool {
+	r := msg.GetMessageResponse()
+	switch r.GetEvent() {
+	case ping.Event_EVENT_REACTION_ADD, ping.Event_EVENT_REACTION_REMOVE:
+		return false
 	}
+	return bridgeID != "" && r.GetOrigin().GetBridgeId() == bridgeID
 }
 
-// Function to broadcast a message to all connected clients
-func (s *Server) broadcastMessage(msg *ping.ServerMessage) {
+// unsubscribe removes the client from the hub and the rooms it listened to,
+// unless a newer stream of the same client replaced it.
+func (s *Server) unsubscribe(sub *hub.Subscriber, roomNames []string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	for _, room := range roomNames {
+		if s.roomSubs[room][sub.ID] == sub {
+			delete(s.roomSubs[room], sub.ID)
+		}
+	}
+	s.mu.Unlock()
+	s.hub.Unsubscribe(sub)
+}
 
-	for clientID, stream := range s.clientStreams {
-		fmt.Printf("Sending message to client %s %s\n", clientID, msg.MessageResponse.Content)
-		if err := stream.Send(msg); err != nil {
-			fmt.Printf("Error sending message to client %s: %v\n", clientID, err)
-			delete(s.clientStreams, clientID) // Remove disconnected clients
+// isBridge reports whether the caller sent the bridge secret. Without one
+// configured, nobody is a bridge.
+func (s *Server) isBridge(ctx context.Context) bool {
+	if s.bridgeSecret == "" {
+		return false
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(bridgeSecretKey)
+	return len(values) > 0 && subtle.ConstantTimeCompare([]byte(values[0]), []byte(s.bridgeSecret)) == 1
+}
+
+// unaryInterceptor authenticates every call. Bridges send the bridge secret
+// and act as the platform they name in the request, anyone else needs a
+// session token of the chat server and acts as its user.
+func (s *Server) unaryInterceptor() grpc.UnaryServerInterceptor {
+	sessions := s.sessions.UnaryInterceptor()
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if s.isBridge(ctx) {
+			return handler(ctx, req)
+		}
+		return sessions(ctx, req, info, handler)
+	}
+}
+
+// streamInterceptor is the streaming counterpart of unaryInterceptor.
+func (s *Server) streamInterceptor() grpc.StreamServerInterceptor {
+	sessions := s.sessions.StreamInterceptor()
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if s.isBridge(ss.Context()) {
+			return handler(srv, ss)
 		}
+		return sessions(srv, ss, info, handler)
 	}
 }
 
+// inRoom reports whether the client may receive messages sent to room.
+// subscribed are the rooms a bridge listed.
+func (s *Server) inRoom(clientID string, subscribed []string, room string) bool {
+	if slices.Contains(subscribed, room) {
+		return true
+	}
+	r, err := s.rooms.Get(room)
+	return err == nil && r.IsMember(clientID)
+}
+
 func (s *Server) SendMessage(ctx context.Context, in *ping.MessageRequest) (*ping.ExitCode, error) {
 	fmt.Println("Szuruburu processing data beep boop beep boop")
 
-	s.broadcastMessage(&ping.ServerMessage{
-		MessageResponse: &ping.MessageResponse{
-			Type:    in.Client,
-			Content: in.Message,
-			Sender:  in.Author,
-		},
-	})
+	// A copy another bridge posted, e.g. an instance with its own account in
+	// the same chat, continues the hops of the message it is a copy of
+	if o := in.Origin; o != nil && o.MessageId != "" {
+		copied, err := s.messages.CopyOf(o.Platform, o.MessageId)
+		if err != nil {
+			fmt.Println(err)
+			return nil, err
+		}
+		if copied != nil && copied.Hops > in.Hops {
+			in.Hops = copied.Hops
+		}
+	}
+
+	// Drop messages going around in a loop, or relayed twice, e.g. by two
+	// bridge instances in the same chat. This is not an error for the sender.
+	if in.Hops >= uint32(MaxHops) {
+		fmt.Printf("Dropping message from %s after %d hops\n", in.Client, in.Hops)
+		return &ping.ExitCode{Status: 0, Message: "Dropped: relayed too many times"}, nil
+	}
+	if in.Message == "" && len(in.Attachments) == 0 {
+		return &ping.ExitCode{Status: 0, Message: "Empty message"}, nil
+	}
+	// Attachments have to be uploaded first, their size is what was uploaded
+	for _, a := range in.Attachments {
+		size, err := s.blobs.Size(a.BlobId)
+		if errors.Is(err, blobs.ErrNotFound) {
+			return &ping.ExitCode{Status: 0, Message: fmt.Sprintf("Unknown attachment %q", a.BlobId)}, nil
+		}
+		if err != nil {
+			fmt.Println(err)
+			return nil, err
+		}
+		a.Size = uint64(size)
+	}
+	// A recipient naming a room limits the message to that room, anything
+	// else goes to everyone.
+	room, err := s.rooms.Get(in.Recipient)
+	if err != nil && !errors.Is(err, rooms.ErrNotFound) {
+		fmt.Printf("Error looking up room %s: %v\n", in.Recipient, err)
+		return nil, err
+	}
+
+	// Persist first, so the message is not lost if nobody is listening right now.
+	// Publishing under the lock keeps clients receiving messages in ID order.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only stored messages count as relayed, so the sender can retry after
+	// an error. Checking under the lock keeps two copies from both passing.
+	var seenKey string
+	if o := in.Origin; o != nil && o.MessageId != "" {
+		seenKey = o.Platform + "/" + o.MessageId + "/" + in.Recipient
+		if s.seen.Has(seenKey) {
+			fmt.Printf("Dropping %s message %s to %s, already relayed\n", o.Platform, o.MessageId, in.Recipient)
+			return &ping.ExitCode{Status: 0, Message: "Dropped: already relayed"}, nil
+		}
+	}
+
+	roomName := ""
+	if room != nil {
+		roomName = room.Name
+	}
+	msg, err := s.messages.Append(in, roomName)
+	if err != nil {
+		fmt.Printf("Error storing message from %s: %v\n", in.Client, err)
+		return nil, err
+	}
+	if seenKey != "" {
+		s.seen.Add(seenKey)
+	}
+
+	s.publish(msg, room)
 
 	return &ping.ExitCode{
 		Status:  1,
@@ -77,16 +338,393 @@ func (s *Server) SendMessage(ctx context.Context, in *ping.MessageRequest) (*pin
 	}, nil
 }
 
+// publish delivers a stored message to everyone, or to the recipients of
+// room if it is not nil. The caller must hold s.mu.
+func (s *Server) publish(msg *store.Message, room *rooms.Room) {
+	if room == nil {
+		s.hub.Publish(msg.ServerMessage())
+		return
+	}
+	for clientID := range s.roomRecipients(room) {
+		s.hub.Send(clientID, msg.ServerMessage())
+	}
+}
+
+// EditMessage edits every message relayed from the origin's platform message
+// and sends the edit to the same recipients, so bridges update their copies.
+func (s *Server) EditMessage(ctx context.Context, in *ping.EditRequest) (*ping.ExitCode, error) {
+	o := in.GetOrigin()
+	if o.GetMessageId() == "" {
+		return &ping.ExitCode{Status: 0, Message: "Edits need the origin message ID"}, nil
+	}
+	// Two bridge instances in the same chat both see the edit. Without a
+	// version, applying it twice does no harm.
+	if in.Version != "" && !s.seen.Add("edit/"+o.Platform+"/"+o.MessageId+"/"+in.Version) {
+		return &ping.ExitCode{Status: 0, Message: "Dropped: already relayed"}, nil
+	}
+	n, err := s.updateByOrigin(in.Client, o, "edited", func(id uint64) (*store.Message, error) {
+		return s.messages.Edit(id, in.Message)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		// E.g. sent before the server kept origins, not relayed at all or
+		// deleted
+		return &ping.ExitCode{Status: 0, Message: "Dropped: unknown message"}, nil
+	}
+	return &ping.ExitCode{
+		Status:  1,
+		Message: fmt.Sprintf("Edited %d messages", n),
+	}, nil
+}
+
+// DeleteMessage deletes every message relayed from the origin's platform
+// message and sends the deletion to the same recipients, so bridges remove
+// their copies.
+func (s *Server) DeleteMessage(ctx context.Context, in *ping.DeleteRequest) (*ping.ExitCode, error) {
+	o := in.GetOrigin()
+	if o.GetMessageId() == "" {
+		return &ping.ExitCode{Status: 0, Message: "Deletions need the origin message ID"}, nil
+	}
+	n, err := s.updateByOrigin(in.Client, o, "deleted", s.messages.Delete)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		// Unknown, or already deleted through another bridge instance
+		return &ping.ExitCode{Status: 0, Message: "Dropped: unknown message"}, nil
+	}
+	return &ping.ExitCode{
+		Status:  1,
+		Message: fmt.Sprintf("Deleted %d messages", n),
+	}, nil
+}
+
+// updateByOrigin calls update for every message the client relayed from the
+// origin's platform message, and publishes the returned events to the
+// recipients of the message. Clients can only change their own messages.
+// It returns how many events were published.
+func (s *Server) updateByOrigin(clientID string, o *ping.Origin, verb string, update func(id uint64) (*store.Message, error)) (int, error) {
+	msgs, err := s.messages.ByOrigin(o.Platform, o.MessageId)
+	if err != nil {
+		fmt.Printf("Error looking up %s message %s: %v\n", o.Platform, o.MessageId, err)
+		return 0, err
+	}
+
+	n := 0
+	for _, msg := range msgs {
+		if msg.Client != clientID {
+			fmt.Printf("Client %s may not change message %d of %s\n", clientID, msg.ID, msg.Client)
+			continue
+		}
+		published, err := s.update(msg, update)
+		if err != nil {
+			return n, err
+		}
+		if published {
+			fmt.Printf("Client %s %s message %d\n", clientID, verb, msg.ID)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// update calls update for msg and publishes the returned event to the
+// recipients of msg. It reports whether there was an event, there is none
+// e.g. for deleted messages.
+func (s *Server) update(msg *store.Message, update func(id uint64) (*store.Message, error)) (bool, error) {
+	var room *rooms.Room
+	if msg.Room != "" {
+		var err error
+		if room, err = s.rooms.Get(msg.Room); err != nil {
+			fmt.Printf("Error looking up room %s: %v\n", msg.Room, err)
+			return false, nil
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event, err := update(msg.ID)
+	if err != nil {
+		fmt.Printf("Error changing message %d: %v\n", msg.ID, err)
+		return false, err
+	}
+	if event == nil {
+		return false, nil
+	}
+	s.publish(event, room)
+	return true, nil
+}
+
+// ReactMessage sets how many users reacted with an emoji to a platform
+// message, written there or a copy a bridge posted. If the count changed, the
+// reaction is sent to the recipients of the Ping message, so bridges mirror
+// it. Unlike edits, any client may react to any message.
+func (s *Server) ReactMessage(ctx context.Context, in *ping.ReactionRequest) (*ping.ExitCode, error) {
+	o := in.GetOrigin()
+	if o.GetMessageId() == "" || in.Emoji == "" {
+		return &ping.ExitCode{Status: 0, Message: "Reactions need the message ID and emoji"}, nil
+	}
+	msgs, err := s.messages.ByPlatformID(o.Platform, o.MessageId)
+	if err != nil {
+		fmt.Printf("Error looking up %s message %s: %v\n", o.Platform, o.MessageId, err)
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return &ping.ExitCode{Status: 0, Message: "Dropped: unknown message"}, nil
+	}
+
+	// Instances of a bridge in the same chat count the same reactions
+	from := store.Origin{Platform: o.Platform, MessageID: o.MessageId}
+	n := 0
+	for _, msg := range msgs {
+		published, err := s.update(msg, func(id uint64) (*store.Message, error) {
+			return s.messages.React(id, from, in.Emoji, in.Count)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if published {
+			fmt.Printf("Client %s set %s reactions to message %d to %d\n", in.Client, in.Emoji, msg.ID, in.Count)
+			n++
+		}
+	}
+	if n == 0 {
+		return &ping.ExitCode{Status: 0, Message: "Reactions unchanged"}, nil
+	}
+	return &ping.ExitCode{
+		Status:  1,
+		Message: fmt.Sprintf("Reacted to %d messages", n),
+	}, nil
+}
+
+// AddCopy records where a bridge posted a message, see EditMessage and
+// DeleteMessage. Only bridges post copies.
+func (s *Server) AddCopy(ctx context.Context, in *ping.CopyRequest) (*ping.ExitCode, error) {
+	if !s.isBridge(ctx) {
+		return nil, status.Error(codes.PermissionDenied, "only bridges may add copies")
+	}
+	c := in.GetCopy()
+	if c.GetMessageId() == "" {
+		return &ping.ExitCode{Status: 0, Message: "Copies need their message ID"}, nil
+	}
+	copy := store.Origin{Platform: c.Platform, BridgeID: c.BridgeId, MessageID: c.MessageId}
+	if err := s.messages.AddCopy(in.MessageId, copy); err != nil {
+		fmt.Printf("Error storing copy of message %d from %s: %v\n", in.MessageId, in.Client, err)
+		return &ping.ExitCode{Status: 0, Message: err.Error()}, nil
+	}
+	return &ping.ExitCode{Status: 1, Message: "Copy stored"}, nil
+}
+
+// UploadBlob stores an attachment sent in chunks and returns its ID, which
+// messages refer to it by.
+func (s *Server) UploadBlob(stream ping.PingService_UploadBlobServer) error {
+	r := &chunkReader{stream: stream}
+	id, size, err := s.blobs.Put(r)
+	if errors.Is(err, blobs.ErrTooLarge) {
+		return stream.SendAndClose(&ping.BlobInfo{
+			ExitCode: &ping.ExitCode{Status: 0, Message: fmt.Sprintf("Attachments may be at most %d MB", MaxBlobMB)},
+		})
+	}
+	if err != nil {
+		fmt.Printf("Error storing blob from %s: %v\n", r.client, err)
+		return err
+	}
+	fmt.Printf("Client %s uploaded blob %s (%d bytes)\n", r.client, id, size)
+	return stream.SendAndClose(&ping.BlobInfo{
+		BlobId:   id,
+		Size:     uint64(size),
+		ExitCode: &ping.ExitCode{Status: 1, Message: "Blob stored"},
+	})
+}
+
+// blobChunkSize is how much of a blob DownloadBlob sends at once, well below
+// the gRPC message size limit.
+const blobChunkSize = 64 * 1024
+
+// DownloadBlob sends an attachment in chunks.
+func (s *Server) DownloadBlob(in *ping.BlobRequest, stream ping.PingService_DownloadBlobServer) error {
+	f, err := s.blobs.Open(in.BlobId)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, blobChunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&ping.BlobChunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			fmt.Printf("Error reading blob %s: %v\n", in.BlobId, err)
+			return err
+		}
+	}
+}
+
+// chunkReader reads the chunks of an upload as one stream.
+type chunkReader struct {
+	stream ping.PingService_UploadBlobServer
+	client string // From the first chunk
+	buf    []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunk, err := r.stream.Recv()
+		if err != nil {
+			return 0, err // io.EOF at the end of the upload
+		}
+		if r.client == "" {
+			r.client = chunk.Client
+		}
+		r.buf = chunk.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// roomRecipients returns the members of room and the clients subscribed to it.
+// The caller must hold s.mu.
+func (s *Server) roomRecipients(room *rooms.Room) map[string]bool {
+	recipients := make(map[string]bool)
+	for _, member := range room.Members {
+		recipients[member] = true
+	}
+	for clientID := range s.roomSubs[room.Name] {
+		recipients[clientID] = true
+	}
+	return recipients
+}
+
+func (s *Server) CreateRoom(ctx context.Context, in *ping.RoomRequest) (*ping.ExitCode, error) {
+	if _, err := s.rooms.Create(in.Room, in.Client); err != nil {
+		fmt.Printf("Error creating room %s for %s: %v\n", in.Room, in.Client, err)
+		return &ping.ExitCode{Status: 0, Message: err.Error()}, nil
+	}
+	fmt.Printf("Client %s created room %s\n", in.Client, in.Room)
+	return &ping.ExitCode{Status: 1, Message: fmt.Sprintf("Created room %s", in.Room)}, nil
+}
+
+func (s *Server) JoinRoom(ctx context.Context, in *ping.RoomRequest) (*ping.ExitCode, error) {
+	if _, err := s.rooms.Join(in.Room, in.Client); err != nil {
+		fmt.Printf("Error joining room %s for %s: %v\n", in.Room, in.Client, err)
+		return &ping.ExitCode{Status: 0, Message: err.Error()}, nil
+	}
+	fmt.Printf("Client %s joined room %s\n", in.Client, in.Room)
+	return &ping.ExitCode{Status: 1, Message: fmt.Sprintf("Joined room %s", in.Room)}, nil
+}
+
+func (s *Server) LeaveRoom(ctx context.Context, in *ping.RoomRequest) (*ping.ExitCode, error) {
+	if _, err := s.rooms.Leave(in.Room, in.Client); err != nil {
+		fmt.Printf("Error leaving room %s for %s: %v\n", in.Room, in.Client, err)
+		return &ping.ExitCode{Status: 0, Message: err.Error()}, nil
+	}
+	fmt.Printf("Client %s left room %s\n", in.Client, in.Room)
+	return &ping.ExitCode{Status: 1, Message: fmt.Sprintf("Left room %s", in.Room)}, nil
+}
+
+func (s *Server) ListRooms(ctx context.Context, in *ping.RoomListRequest) (*ping.RoomList, error) {
+	all, err := s.rooms.List()
+	if err != nil {
+		fmt.Printf("Error listing rooms: %v\n", err)
+		return nil, err
+	}
+
+	list := &ping.RoomList{ExitCode: &ping.ExitCode{Status: 1}}
+	for _, room := range all {
+		joined := room.IsMember(in.Client)
+		if in.JoinedOnly && !joined {
+			continue
+		}
+		list.Rooms = append(list.Rooms, &ping.Room{
+			Name:    room.Name,
+			Owner:   room.Owner,
+			Members: room.Members,
+			Joined:  joined,
+		})
+	}
+	return list, nil
+}
+
 func main() {
+	flag.Parse()
+
 	lis, err := net.Listen("tcp", ":50051")
 	if err != nil {
 		log.Fatalf("Failed to listen on port 50051: %v", err)
 	}
 
-	s := grpc.NewServer()
+	db, err := bolt.Open(DBPath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		log.Fatalf("Failed to open database %s: %v", DBPath, err)
+	}
+	defer db.Close()
+
+	messages, err := store.NewMessageStore(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize message store: %v", err)
+	}
+
+	policy, err := hub.ParsePolicy(SlowPolicy)
+	if err != nil {
+		log.Fatalf("Invalid -slow-policy: %v", err)
+	}
+
+	roomStore, err := rooms.NewStore(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize room store: %v", err)
+	}
+
+	blobStore, err := blobs.NewStore(BlobDir, MaxBlobMB<<20)
+	if err != nil {
+		log.Fatalf("Failed to initialize blob store: %v", err)
+	}
+
+	bridgeSecret := os.Getenv("BRIDGE_SECRET")
+	if bridgeSecret == "" {
+		fmt.Println("BRIDGE_SECRET not set, bridges will be refused")
+	}
+
+	// Tokens Login issued on the chat server, signed with the same
+	// SESSION_SECRET. They are only verified here, so the TTL doesn't matter.
+	secret := os.Getenv("SESSION_SECRET")
+	if secret == "" {
+		fmt.Println("SESSION_SECRET not set, only bridges are accepted")
+	}
+	sessions, err := auth.NewSigner([]byte(secret), 0)
+	if err != nil {
+		log.Fatalf("Failed to initialize sessions: %v", err)
+	}
+
 	server := &Server{
-		clientStreams: make(map[string]ping.PingService_ReceiveMessagesServer), // Initialize the map
+		hub:      hub.New(BufferSize, policy),
+		messages: messages,
+		rooms:    roomStore,
+		roomSubs: make(map[string]map[string]*hub.Subscriber),
+		seen:     seen.New(SeenSize),
+		blobs:    blobStore,
+
+		bridgeSecret: bridgeSecret,
+		sessions:     sessions,
 	}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(server.unaryInterceptor()),
+		grpc.StreamInterceptor(server.streamInterceptor()),
+		// Bridges keep their connection open and ping it every 30 seconds
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             20 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
 	ping.RegisterPingServiceServer(s, server)
 	log.Printf("gRPC server listening at %s", lis.Addr().String())
 	if err := s.Serve(lis); err != nil {
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/blobs"
+	"github.com/kallazz/Ping/hub"
+	"github.com/kallazz/Ping/rooms"
+	"github.com/kallazz/Ping/seen"
+	"github.com/kallazz/Ping/store"
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const testBridgeSecret = "secret"
+
+// newTestServer creates a server on a temporary bolt database.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := bolt.Open(filepath.Join(dir, "ping.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	messages, err := store.NewMessageStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roomStore, err := rooms.NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobStore, err := blobs.NewStore(filepath.Join(dir, "blobs"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{
+		hub:      hub.New(1000, hub.DropOldest),
+		messages: messages,
+		rooms:    roomStore,
+		roomSubs: make(map[string]map[string]*hub.Subscriber),
+		seen:     seen.New(100),
+		blobs:    blobStore,
+
+		bridgeSecret: testBridgeSecret,
+	}
+}
+
+// testStream is the server side of a ReceiveMessages stream.
+type testStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *ping.ServerMessage
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testStream) Send(msg *ping.ServerMessage) error {
+	select {
+	case s.sent <- msg:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// connect calls ReceiveMessages, as a bridge if bridge is set. The stream is
+// closed when the test ends.
+func connect(t *testing.T, s *Server, req *ping.Empty, bridge bool) *testStream {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	if bridge {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(bridgeSecretKey, testBridgeSecret))
+	}
+	stream := &testStream{ctx: ctx, sent: make(chan *ping.ServerMessage, 100)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ReceiveMessages(req, stream)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	if req.Cursor == 0 {
+		// The cursor live messages start at, sent once subscribed
+		if msg := receive(t, stream); msg.MessageResponse != nil {
+			t.Fatalf("first message = %v, want the cursor", msg)
+		}
+	}
+	return stream
+}
+
+// receive returns the next message sent on stream.
+func receive(t *testing.T, stream *testStream) *ping.ServerMessage {
+	t.Helper()
+	select {
+	case msg := <-stream.sent:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+// expectNothing fails if a message is sent on stream shortly.
+func expectNothing(t *testing.T, stream *testStream) {
+	t.Helper()
+	select {
+	case msg := <-stream.sent:
+		t.Errorf("unexpected message %v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// send sends content from client to recipient.
+func send(t *testing.T, s *Server, client, recipient, content string) {
+	t.Helper()
+	code, err := s.SendMessage(context.Background(), &ping.MessageRequest{Client: client, Recipient: recipient, Message: content})
+	if err != nil || code.Status != 1 {
+		t.Fatalf("SendMessage() = %v, %v", code, err)
+	}
+}
+
+func TestRoomMembers(t *testing.T) {
+	s := newTestServer(t)
+	if _, err := s.rooms.Create("#dev", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.rooms.Join("#dev", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	send(t, s, "alice", "everyone", "before")
+
+	tests := []struct {
+		name   string
+		req    *ping.Empty
+		bridge bool
+		inRoom bool
+	}{
+		{"owner", &ping.Empty{Client: "alice"}, false, true},
+		{"member", &ping.Empty{Client: "bob"}, false, true},
+		{"not a member", &ping.Empty{Client: "carol"}, false, false},
+		{"bridge listing the room", &ping.Empty{Client: "Telegram", Rooms: []string{"#dev"}}, true, true},
+		{"bridge listing another room", &ping.Empty{Client: "Slack", Rooms: []string{"#ops"}}, true, false},
+		{"client listing the room", &ping.Empty{Client: "mallory", Rooms: []string{"#dev"}}, false, false},
+	}
+	live := make([]*testStream, len(tests))
+	for i, tt := range tests {
+		live[i] = connect(t, s, tt.req, tt.bridge)
+	}
+	send(t, s, "alice", "#dev", "in the room")
+	send(t, s, "alice", "everyone", "to everyone")
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Live, then replayed after the first message. The replay
+			// replaces the live subscription.
+			for _, how := range []string{"live", "replayed"} {
+				stream := live[i]
+				if how == "replayed" {
+					stream = connect(t, s, &ping.Empty{Client: tt.req.Client, Rooms: tt.req.Rooms, Cursor: 1}, tt.bridge)
+				}
+				if tt.inRoom {
+					if msg := receive(t, stream); msg.MessageResponse.Content != "in the room" {
+						t.Errorf("%s: got %q, want the room's message", how, msg.MessageResponse.Content)
+					}
+				}
+				if msg := receive(t, stream); msg.MessageResponse.Content != "to everyone" {
+					t.Errorf("%s: got %q, want the message to everyone", how, msg.MessageResponse.Content)
+				}
+				expectNothing(t, stream)
+			}
+		})
+	}
+}
+
+func TestReplayHandoff(t *testing.T) {
+	s := newTestServer(t)
+	for range 10 {
+		send(t, s, "alice", "everyone", "stored")
+	}
+
+	// Messages keep coming while the client catches up. Each is sent
+	// exactly once and in order, whether replayed or live.
+	const total = 200
+	sending := make(chan struct{})
+	go func() {
+		defer close(sending)
+		for i := 10; i < total; i++ {
+			req := &ping.MessageRequest{Client: "alice", Recipient: "everyone", Message: "live"}
+			if code, err := s.SendMessage(context.Background(), req); err != nil || code.Status != 1 {
+				t.Errorf("SendMessage() = %v, %v", code, err)
+				return
+			}
+		}
+	}()
+	stream := connect(t, s, &ping.Empty{Client: "bob", Cursor: 5}, false)
+	for want := uint64(6); want <= total; want++ {
+		if msg := receive(t, stream); msg.Cursor != want {
+			t.Fatalf("got message %d, want %d", msg.Cursor, want)
+		}
+	}
+	<-sending
+	expectNothing(t, stream)
+}
+
+func TestLiveCursor(t *testing.T) {
+	s := newTestServer(t)
+	send(t, s, "alice", "everyone", "old")
+	send(t, s, "alice", "everyone", "old")
+
+	// A new client starts at the newest message and gets only what follows
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &testStream{ctx: ctx, sent: make(chan *ping.ServerMessage, 10)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ReceiveMessages(&ping.Empty{Client: "bob"}, stream)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	if msg := receive(t, stream); msg.Cursor != 2 || msg.MessageResponse != nil {
+		t.Fatalf("first message = %v, want cursor 2", msg)
+	}
+	send(t, s, "alice", "everyone", "new")
+	if msg := receive(t, stream); msg.Cursor != 3 || msg.MessageResponse.Content != "new" {
+		t.Errorf("got %v, want message 3", msg)
+	}
+	expectNothing(t, stream)
+}
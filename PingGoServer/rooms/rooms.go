@@ -0,0 +1,167 @@
+package rooms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	roomsBucket = []byte("rooms") // room name -> Room
+
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_.#-]{1,64}$`)
+)
+
+var (
+	ErrInvalidName = errors.New("room name must be 1-64 letters, digits, '_', '.', '#' or '-'")
+	ErrExists      = errors.New("room already exists")
+	ErrNotFound    = errors.New("no such room")
+	ErrNotMember   = errors.New("not a member of the room")
+)
+
+// Room is a named group of clients. Messages sent to a room only reach its
+// members.
+type Room struct {
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	CreatedAt time.Time `json:"created_at"`
+	Members   []string  `json:"members"`
+}
+
+// IsMember reports whether client has joined the room.
+func (r *Room) IsMember(client string) bool {
+	return slices.Contains(r.Members, client)
+}
+
+// Store keeps rooms and their members in a bolt database.
+type Store struct {
+	db *bolt.DB
+}
+
+// NewStore creates the rooms bucket in db if needed.
+func NewStore(db *bolt.DB) (*Store, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(roomsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rooms bucket: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Create creates a room with owner as its first member.
+func (s *Store) Create(name, owner string) (*Room, error) {
+	if !namePattern.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+	room := &Room{
+		Name:      name,
+		Owner:     owner,
+		CreatedAt: time.Now().UTC(),
+		Members:   []string{owner},
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(roomsBucket).Get([]byte(name)) != nil {
+			return ErrExists
+		}
+		return put(tx, room)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// Join adds client to the room. Joining twice is not an error.
+func (s *Store) Join(name, client string) (*Room, error) {
+	return s.update(name, func(room *Room) error {
+		if !room.IsMember(client) {
+			room.Members = append(room.Members, client)
+		}
+		return nil
+	})
+}
+
+// Leave removes client from the room.
+func (s *Store) Leave(name, client string) (*Room, error) {
+	return s.update(name, func(room *Room) error {
+		i := slices.Index(room.Members, client)
+		if i < 0 {
+			return ErrNotMember
+		}
+		room.Members = slices.Delete(room.Members, i, i+1)
+		return nil
+	})
+}
+
+// Get looks up a room by name.
+func (s *Store) Get(name string) (*Room, error) {
+	var room *Room
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		room, err = get(tx, name)
+		return err
+	})
+	return room, err
+}
+
+// List returns all rooms, sorted by name.
+func (s *Store) List() ([]*Room, error) {
+	var list []*Room
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(roomsBucket).ForEach(func(k, v []byte) error {
+			var room Room
+			if err := json.Unmarshal(v, &room); err != nil {
+				return fmt.Errorf("failed to decode room %s: %v", k, err)
+			}
+			list = append(list, &room)
+			return nil
+		})
+	})
+	return list, err
+}
+
+func (s *Store) update(name string, fn func(*Room) error) (*Room, error) {
+	var room *Room
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		room, err = get(tx, name)
+		if err != nil {
+			return err
+		}
+		if err := fn(room); err != nil {
+			return err
+		}
+		return put(tx, room)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+func get(tx *bolt.Tx, name string) (*Room, error) {
+	data := tx.Bucket(roomsBucket).Get([]byte(name))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var room Room
+	if err := json.Unmarshal(data, &room); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+func put(tx *bolt.Tx, room *Room) error {
+	data, err := json.Marshal(room)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(roomsBucket).Put([]byte(room.Name), data)
+}
@@ -0,0 +1,119 @@
+package rooms
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// newTestStore opens a room store in a temporary bolt database.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "ping.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCreate(t *testing.T) {
+	s := newTestStore(t)
+	tests := []struct {
+		name string
+		room string
+		want error
+	}{
+		{"valid", "#general", nil},
+		{"all characters", "Team_1.dev-ops", nil},
+		{"exists", "#general", ErrExists},
+		{"empty", "", ErrInvalidName},
+		{"space", "two words", ErrInvalidName},
+		{"too long", strings.Repeat("r", 65), ErrInvalidName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room, err := s.Create(tt.room, "alice")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Create(%q) = %v, want %v", tt.room, err, tt.want)
+			}
+			if err == nil && (room.Owner != "alice" || !room.IsMember("alice")) {
+				t.Errorf("Create(%q) = %+v, want alice as owner and member", tt.room, room)
+			}
+		})
+	}
+}
+
+func TestMembers(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Create("#general", "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	type step struct {
+		op     string // "join" or "leave"
+		client string
+		room   string
+		want   error
+	}
+	tests := []struct {
+		step
+		members []string
+	}{
+		{step{"join", "bob", "#general", nil}, []string{"alice", "bob"}},
+		{step{"join", "bob", "#general", nil}, []string{"alice", "bob"}},
+		{step{"join", "bob", "#missing", ErrNotFound}, []string{"alice", "bob"}},
+		{step{"leave", "carol", "#general", ErrNotMember}, []string{"alice", "bob"}},
+		{step{"leave", "alice", "#general", nil}, []string{"bob"}},
+		{step{"leave", "bob", "#missing", ErrNotFound}, []string{"bob"}},
+	}
+	for _, tt := range tests {
+		var err error
+		if tt.op == "join" {
+			_, err = s.Join(tt.room, tt.client)
+		} else {
+			_, err = s.Leave(tt.room, tt.client)
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s %s %s = %v, want %v", tt.client, tt.op, tt.room, err, tt.want)
+		}
+		room, err := s.Get("#general")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(room.Members, tt.members) {
+			t.Errorf("after %s %s %s: members %v, want %v", tt.client, tt.op, tt.room, room.Members, tt.members)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	s := newTestStore(t)
+	for _, name := range []string{"#b", "#a", "#c"} {
+		if _, err := s.Create(name, "alice"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, room := range list {
+		names = append(names, room.Name)
+	}
+	if want := []string{"#a", "#b", "#c"}; !slices.Equal(names, want) {
+		t.Errorf("List() = %v, want %v", names, want)
+	}
+	if _, err := s.Get("#d"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of an unknown room = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,50 @@
+package seen
+
+import "sync"
+
+// Set remembers the last size keys it was given, oldest are forgotten first.
+// It is used to notice platform messages relayed more than once.
+type Set struct {
+	mu    sync.Mutex
+	keys  map[string]bool
+	order []string // Ring buffer of keys, next is overwritten first
+	next  int
+}
+
+// New creates a set holding up to size keys. A set of size 0, or less,
+// remembers nothing.
+func New(size int) *Set {
+	if size < 0 {
+		size = 0
+	}
+	return &Set{
+		keys:  make(map[string]bool, size),
+		order: make([]string, size),
+	}
+}
+
+// Has reports whether key is in the set.
+func (s *Set) Has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[key]
+}
+
+// Add adds key and reports whether it was new.
+func (s *Set) Add(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys[key] {
+		return false
+	}
+	if len(s.order) == 0 {
+		return true
+	}
+	if old := s.order[s.next]; old != "" {
+		delete(s.keys, old)
+	}
+	s.order[s.next] = key
+	s.next = (s.next + 1) % len(s.order)
+	s.keys[key] = true
+	return true
+}
@@ -0,0 +1,626 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	messagesBucket = []byte("messages")
+	originsBucket  = []byte("origins")  // Platform + message ID -> Ping message IDs
+	copiesBucket   = []byte("copies")   // Ping message ID -> copies posted by bridges
+	copyIDsBucket  = []byte("copy_ids") // Platform + copy ID -> Ping message ID
+	eventsBucket   = []byte("events")   // Ping message ID -> IDs of events about it
+)
+
+// How much of a message a reply quotes, in characters.
+const excerptLength = 100
+
+// Events about an earlier message, Target, see ping.Event.
+const (
+	EventEdit           = "edit"
+	EventDelete         = "delete"
+	EventReactionAdd    = "reaction_add"
+	EventReactionRemove = "reaction_remove"
+)
+
+// Message is a MessageRequest as it was persisted, together with the ID and
+// timestamp assigned to it by the store.
+type Message struct {
+	ID        uint64    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Client    string    `json:"client"`
+	Recipient string    `json:"recipient"`
+	Room      string    `json:"room,omitempty"` // Set if Recipient is a room
+	Author    string    `json:"author"`
+	Content   string    `json:"content"`
+	Origin    *Origin   `json:"origin,omitempty"`
+	Hops      uint32    `json:"hops,omitempty"`    // Including the relay through this server
+	Deleted   bool      `json:"deleted,omitempty"` // Content is cleared when deleted, events about it too
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+	ReplyTo     *Reply       `json:"reply_to,omitempty"`
+	Reactions   []Reaction   `json:"reactions,omitempty"`
+
+	// Set for events about an earlier message instead of new messages
+	Event  string   `json:"event,omitempty"`
+	Target uint64   `json:"target,omitempty"` // ID of the changed message
+	Copies []Origin `json:"copies,omitempty"` // Copies of Target when the event was stored
+	Emoji  string   `json:"emoji,omitempty"`  // Added or removed by reaction events
+}
+
+// Origin is where a bridged message was written, see ping.Origin.
+type Origin struct {
+	Platform  string `json:"platform"`
+	BridgeID  string `json:"bridge_id"`
+	MessageID string `json:"message_id,omitempty"`
+}
+
+// Attachment is a file sent with a message, see ping.Attachment. Its content
+// is in the blob store.
+type Attachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        uint64 `json:"size"`
+	BlobID      string `json:"blob_id"`
+}
+
+// Reply is the message a message replies to, see ping.ReplyTo.
+type Reply struct {
+	MessageID string   `json:"message_id"` // On the replying platform
+	Author    string   `json:"author,omitempty"`
+	Excerpt   string   `json:"excerpt,omitempty"`
+	ID        uint64   `json:"id,omitempty"` // 0 if the parent is unknown
+	Copies    []Origin `json:"copies,omitempty"`
+}
+
+// Reaction is how many users reacted with an emoji to a platform message,
+// see ping.Reaction.
+type Reaction struct {
+	Emoji string `json:"emoji"`
+	Count uint32 `json:"count"`
+	From  Origin `json:"from"`
+}
+
+func (r *Reply) proto() *ping.ReplyTo {
+	if r == nil {
+		return nil
+	}
+	var copies []*ping.Origin
+	for _, c := range r.Copies {
+		copies = append(copies, c.proto())
+	}
+	return &ping.ReplyTo{
+		MessageId: r.MessageID,
+		Author:    r.Author,
+		Excerpt:   r.Excerpt,
+		Id:        r.ID,
+		Copies:    copies,
+	}
+}
+
+// ServerMessage converts the stored message into what gets sent to clients.
+func (m *Message) ServerMessage() *ping.ServerMessage {
+	var copies []*ping.Origin
+	for _, c := range m.Copies {
+		copies = append(copies, c.proto())
+	}
+	var attachments []*ping.Attachment
+	for _, a := range m.Attachments {
+		attachments = append(attachments, &ping.Attachment{
+			Name:        a.Name,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+			BlobId:      a.BlobID,
+		})
+	}
+	var reactions []*ping.Reaction
+	for _, r := range m.Reactions {
+		reactions = append(reactions, &ping.Reaction{Emoji: r.Emoji, Count: r.Count, From: r.From.proto()})
+	}
+	event := ping.Event_EVENT_MESSAGE
+	switch m.Event {
+	case EventEdit:
+		event = ping.Event_EVENT_EDIT
+	case EventDelete:
+		event = ping.Event_EVENT_DELETE
+	case EventReactionAdd:
+		event = ping.Event_EVENT_REACTION_ADD
+	case EventReactionRemove:
+		event = ping.Event_EVENT_REACTION_REMOVE
+	}
+	return &ping.ServerMessage{
+		MessageResponse: &ping.MessageResponse{
+			Type:        m.Client,
+			Content:     m.Content,
+			Sender:      m.Author,
+			Id:          m.ID,
+			Timestamp:   m.Timestamp.UnixMilli(),
+			Room:        m.Room,
+			Origin:      m.Origin.proto(),
+			Hops:        m.Hops,
+			Event:       event,
+			TargetId:    m.Target,
+			Copies:      copies,
+			Attachments: attachments,
+			ReplyTo:     m.ReplyTo.proto(),
+			Emoji:       m.Emoji,
+			Reactions:   reactions,
+		},
+		Cursor: m.ID,
+	}
+}
+
+func (o *Origin) proto() *ping.Origin {
+	if o == nil {
+		return nil
+	}
+	return &ping.Origin{Platform: o.Platform, BridgeId: o.BridgeID, MessageId: o.MessageID}
+}
+
+// MessageStore keeps every message sent through the server in a bolt database,
+// so history survives restarts.
+type MessageStore struct {
+	db *bolt.DB
+}
+
+// NewMessageStore creates the buckets in db if needed.
+func NewMessageStore(db *bolt.DB) (*MessageStore, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{messagesBucket, originsBucket, copiesBucket, copyIDsBucket, eventsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create message buckets: %v", err)
+	}
+	return &MessageStore{db: db}, nil
+}
+
+// Append persists the request and returns it with its assigned ID and timestamp.
+// IDs are increasing and start at 1. room is empty unless the message was sent
+// to a room.
+func (s *MessageStore) Append(req *ping.MessageRequest, room string) (*Message, error) {
+	msg := &Message{
+		Timestamp: time.Now().UTC(),
+		Client:    req.Client,
+		Recipient: req.Recipient,
+		Room:      room,
+		Author:    req.Author,
+		Content:   req.Message,
+		Hops:      req.Hops + 1,
+	}
+	if o := req.Origin; o != nil {
+		msg.Origin = &Origin{Platform: o.Platform, BridgeID: o.BridgeId, MessageID: o.MessageId}
+	}
+	for _, a := range req.Attachments {
+		msg.Attachments = append(msg.Attachments, Attachment{
+			Name:        a.Name,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+			BlobID:      a.BlobId,
+		})
+	}
+
+	if r := req.ReplyTo; r != nil && r.MessageId != "" {
+		msg.ReplyTo = &Reply{MessageID: r.MessageId, Author: r.Author, Excerpt: r.Excerpt}
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if msg.ReplyTo != nil && msg.Origin != nil {
+			if err := resolveReply(tx, msg.Origin.Platform, room, msg.ReplyTo); err != nil {
+				return err
+			}
+		}
+		if err := put(tx, msg); err != nil {
+			return err
+		}
+		// Index the message by its origin, so edits and deletions can find it
+		if msg.Origin == nil || msg.Origin.MessageID == "" {
+			return nil
+		}
+		key := originKey(msg.Origin.Platform, msg.Origin.MessageID)
+		var ids []uint64
+		if err := get(tx.Bucket(originsBucket), key, &ids); err != nil {
+			return err
+		}
+		return set(tx.Bucket(originsBucket), key, append(ids, msg.ID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store message: %v", err)
+	}
+	return msg, nil
+}
+
+// ByOrigin returns the messages relayed from a platform message, one per
+// recipient it was sent to.
+func (s *MessageStore) ByOrigin(platform, messageID string) ([]*Message, error) {
+	var msgs []*Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var ids []uint64
+		if err := get(tx.Bucket(originsBucket), originKey(platform, messageID), &ids); err != nil {
+			return err
+		}
+		var err error
+		msgs, err = load(tx, ids)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s message %s: %v", platform, messageID, err)
+	}
+	return msgs, nil
+}
+
+// ByPlatformID returns the messages a platform message belongs to: the ones
+// relayed from it, or the one it is a copy of.
+func (s *MessageStore) ByPlatformID(platform, messageID string) ([]*Message, error) {
+	var msgs []*Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		ids, err := platformIDs(tx, platform, messageID)
+		if err != nil {
+			return err
+		}
+		msgs, err = load(tx, ids)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s message %s: %v", platform, messageID, err)
+	}
+	return msgs, nil
+}
+
+// CopyOf returns the message a bridge posted a platform message as a copy
+// of, or nil if it is no known copy.
+func (s *MessageStore) CopyOf(platform, messageID string) (*Message, error) {
+	var msg *Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(copyIDsBucket).Get(originKey(platform, messageID))
+		if id == nil {
+			return nil
+		}
+		msgs, err := load(tx, []uint64{binary.BigEndian.Uint64(id)})
+		if len(msgs) > 0 {
+			msg = msgs[0]
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s copy %s: %v", platform, messageID, err)
+	}
+	return msg, nil
+}
+
+// AddCopy records that a bridge posted message id, so edits and deletions
+// reach the copy.
+func (s *MessageStore) AddCopy(id uint64, copy Origin) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(messagesBucket).Get(itob(id)) == nil {
+			return fmt.Errorf("no message %d", id)
+		}
+		var copies []Origin
+		if err := get(tx.Bucket(copiesBucket), itob(id), &copies); err != nil {
+			return err
+		}
+		if err := set(tx.Bucket(copiesBucket), itob(id), append(copies, copy)); err != nil {
+			return err
+		}
+		// Replies to the copy refer to it by its ID
+		return tx.Bucket(copyIDsBucket).Put(originKey(copy.Platform, copy.MessageID), itob(id))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store copy of message %d: %v", id, err)
+	}
+	return nil
+}
+
+// Edit replaces the content of message id and stores the edit as an event,
+// which is returned for delivery. The event carries the copies of the message
+// known so far. Deleted messages are not edited, nil is returned for them.
+func (s *MessageStore) Edit(id uint64, content string) (*Message, error) {
+	event, err := s.addEvent(id, func(msg, event *Message) {
+		msg.Content = content
+		event.Event = EventEdit
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit message %d: %v", id, err)
+	}
+	return event, nil
+}
+
+// Delete clears the content of message id and of the events about it, marks
+// them deleted and stores the deletion as an event like Edit. The copies stay
+// known, so later events can still find them. nil is returned if the message
+// was deleted already.
+func (s *MessageStore) Delete(id uint64) (*Message, error) {
+	event, err := s.addEvent(id, func(msg, event *Message) {
+		msg.Content = ""
+		msg.Deleted = true
+		event.Event = EventDelete
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete message %d: %v", id, err)
+	}
+	return event, nil
+}
+
+// React sets how many users reacted with emoji to message id on the platform
+// message from, and stores an event like Edit if the count went up or down.
+// nil is returned if it didn't change or the message was deleted.
+func (s *MessageStore) React(id uint64, from Origin, emoji string, count uint32) (*Message, error) {
+	event, err := s.addEvent(id, func(msg, event *Message) {
+		old := msg.setReaction(from, emoji, count)
+		switch {
+		case count > old:
+			event.Event = EventReactionAdd
+		case count < old:
+			event.Event = EventReactionRemove
+		default:
+			return
+		}
+		event.Emoji = emoji
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to react to message %d: %v", id, err)
+	}
+	return event, nil
+}
+
+// setReaction replaces the count of emoji reactions on from and returns the
+// previous count.
+func (m *Message) setReaction(from Origin, emoji string, count uint32) uint32 {
+	for i, r := range m.Reactions {
+		if r.Emoji != emoji || r.From != from {
+			continue
+		}
+		if count == 0 {
+			m.Reactions = append(m.Reactions[:i], m.Reactions[i+1:]...)
+		} else {
+			m.Reactions[i].Count = count
+		}
+		return r.Count
+	}
+	if count > 0 {
+		m.Reactions = append(m.Reactions, Reaction{Emoji: emoji, Count: count, From: from})
+	}
+	return 0
+}
+
+// addEvent applies change to message id and stores the event it describes,
+// with the changed content. change sets the event's kind, and leaves it empty
+// if nothing changed. It returns nil then or if the message was deleted.
+func (s *MessageStore) addEvent(id uint64, change func(msg, event *Message)) (*Message, error) {
+	var event *Message
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var msg Message
+		if err := get(tx.Bucket(messagesBucket), itob(id), &msg); err != nil {
+			return err
+		}
+		if msg.ID == 0 {
+			return fmt.Errorf("no message %d", id)
+		}
+		if msg.Deleted {
+			return nil
+		}
+		var changed Message
+		change(&msg, &changed)
+		if changed.Event == "" {
+			return nil
+		}
+		if msg.Deleted {
+			// The blobs stay, other messages may share them
+			msg.Attachments = nil
+			msg.Reactions = nil
+			if err := deleteEvents(tx, id); err != nil {
+				return err
+			}
+		}
+		if err := set(tx.Bucket(messagesBucket), itob(id), &msg); err != nil {
+			return err
+		}
+
+		event = &Message{
+			Timestamp: time.Now().UTC(),
+			Client:    msg.Client,
+			Recipient: msg.Recipient,
+			Room:      msg.Room,
+			Author:    msg.Author,
+			Content:   msg.Content,
+			Origin:    msg.Origin,
+			Hops:      msg.Hops,
+			Event:     changed.Event,
+			Target:    id,
+			ReplyTo:   msg.ReplyTo,
+			Reactions: msg.Reactions,
+			Emoji:     changed.Emoji,
+		}
+		if err := get(tx.Bucket(copiesBucket), itob(id), &event.Copies); err != nil {
+			return err
+		}
+		if err := put(tx, event); err != nil {
+			return err
+		}
+		var events []uint64
+		if err := get(tx.Bucket(eventsBucket), itob(id), &events); err != nil {
+			return err
+		}
+		return set(tx.Bucket(eventsBucket), itob(id), append(events, event.ID))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Head returns the ID of the newest stored message, 0 if there is none.
+func (s *MessageStore) Head() (uint64, error) {
+	var head uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		head = tx.Bucket(messagesBucket).Sequence()
+		return nil
+	})
+	return head, err
+}
+
+// deleteEvents clears the content of the events about message id and marks
+// them deleted, so they are not replayed with the deleted text.
+func deleteEvents(tx *bolt.Tx, id uint64) error {
+	var ids []uint64
+	if err := get(tx.Bucket(eventsBucket), itob(id), &ids); err != nil {
+		return err
+	}
+	events, err := load(tx, ids)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		event.Content = ""
+		event.Deleted = true
+		event.Reactions = nil
+		if err := set(tx.Bucket(messagesBucket), itob(event.ID), event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// After calls fn for every stored message with an ID greater than id, in ID
+// order. Iteration stops at the first error returned by fn.
+func (s *MessageStore) After(id uint64, fn func(*Message) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(messagesBucket).Cursor()
+		for k, v := c.Seek(itob(id + 1)); k != nil; k, v = c.Next() {
+			var msg Message
+			if err := json.Unmarshal(v, &msg); err != nil {
+				return fmt.Errorf("failed to decode message %d: %v", binary.BigEndian.Uint64(k), err)
+			}
+			if err := fn(&msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// resolveReply fills in the parent of a reply sent from platform to room, if
+// it is known: a message written on the platform or a copy posted there.
+func resolveReply(tx *bolt.Tx, platform, room string, reply *Reply) error {
+	ids, err := platformIDs(tx, platform, reply.MessageID)
+	if err != nil {
+		return err
+	}
+	msgs, err := load(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	// A platform message sent to several rooms has a Ping message in each,
+	// prefer the one in the reply's room
+	var parent *Message
+	for _, msg := range msgs {
+		if parent == nil || msg.Room == room {
+			parent = msg
+		}
+	}
+	if parent == nil {
+		return nil
+	}
+
+	reply.ID = parent.ID
+	reply.Author = parent.Author
+	reply.Excerpt = excerpt(parent.Content)
+	reply.Copies = nil
+	if parent.Origin != nil && parent.Origin.MessageID != "" {
+		reply.Copies = append(reply.Copies, *parent.Origin)
+	}
+	var copies []Origin
+	if err := get(tx.Bucket(copiesBucket), itob(parent.ID), &copies); err != nil {
+		return err
+	}
+	reply.Copies = append(reply.Copies, copies...)
+	return nil
+}
+
+// platformIDs returns the IDs of the messages relayed from a platform message
+// and of the message it is a copy of.
+func platformIDs(tx *bolt.Tx, platform, messageID string) ([]uint64, error) {
+	var ids []uint64
+	if err := get(tx.Bucket(originsBucket), originKey(platform, messageID), &ids); err != nil {
+		return nil, err
+	}
+	if id := tx.Bucket(copyIDsBucket).Get(originKey(platform, messageID)); id != nil {
+		ids = append(ids, binary.BigEndian.Uint64(id))
+	}
+	return ids, nil
+}
+
+// load returns the messages with the given IDs, skipping unknown ones.
+func load(tx *bolt.Tx, ids []uint64) ([]*Message, error) {
+	var msgs []*Message
+	for _, id := range ids {
+		var msg Message
+		if err := get(tx.Bucket(messagesBucket), itob(id), &msg); err != nil {
+			return nil, err
+		}
+		if msg.ID != 0 {
+			msgs = append(msgs, &msg)
+		}
+	}
+	return msgs, nil
+}
+
+// excerpt shortens content to excerptLength characters.
+func excerpt(content string) string {
+	runes := []rune(content)
+	if len(runes) <= excerptLength {
+		return content
+	}
+	return string(runes[:excerptLength]) + "…"
+}
+
+// put stores msg under the next ID, which it assigns to it.
+func put(tx *bolt.Tx, msg *Message) error {
+	b := tx.Bucket(messagesBucket)
+	id, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	msg.ID = id
+	return set(b, itob(id), msg)
+}
+
+// get decodes the JSON value of key into v, leaving v alone if there is none.
+func get(b *bolt.Bucket, key []byte, v any) error {
+	data := b.Get(key)
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// set stores v as the JSON value of key.
+func set(b *bolt.Bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+func originKey(platform, messageID string) []byte {
+	return []byte(platform + "\x00" + messageID)
+}
+
+// itob encodes an ID as a big endian key, so bolt keeps messages in ID order.
+func itob(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
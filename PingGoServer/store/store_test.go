@@ -0,0 +1,376 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	bolt "go.etcd.io/bbolt"
+)
+
+// newTestStore opens a message store in a temporary bolt database.
+func newTestStore(t *testing.T) *MessageStore {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "ping.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := NewMessageStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// appendFrom stores a message written on a platform.
+func appendFrom(t *testing.T, s *MessageStore, platform, messageID, room, content string) *Message {
+	t.Helper()
+	msg, err := s.Append(&ping.MessageRequest{
+		Recipient: room,
+		Author:    "alice",
+		Message:   content,
+		Origin:    &ping.Origin{Platform: platform, BridgeId: platform + "-1", MessageId: messageID},
+	}, room)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// ids returns the IDs of msgs.
+func ids(msgs []*Message) []uint64 {
+	var ids []uint64
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func TestAppend(t *testing.T) {
+	s := newTestStore(t)
+	if head, err := s.Head(); err != nil || head != 0 {
+		t.Fatalf("Head() of an empty store = %d, %v, want 0", head, err)
+	}
+
+	msg, err := s.Append(&ping.MessageRequest{
+		Client:    "bob",
+		Recipient: "general",
+		Author:    "alice",
+		Message:   "hi",
+		Hops:      1,
+		Attachments: []*ping.Attachment{
+			{Name: "cat.png", ContentType: "image/png", Size: 3, BlobId: "abc"},
+		},
+	}, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != 1 || msg.Room != "general" || msg.Content != "hi" || msg.Hops != 2 {
+		t.Errorf("Append() = %+v, want ID 1 in room general with 2 hops", msg)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].BlobID != "abc" {
+		t.Errorf("attachments = %+v, want blob abc", msg.Attachments)
+	}
+	if msg.Timestamp.IsZero() {
+		t.Error("timestamp not set")
+	}
+
+	second := appendFrom(t, s, "Discord", "c:1", "", "there")
+	if second.ID != 2 {
+		t.Errorf("second ID = %d, want 2", second.ID)
+	}
+	if head, err := s.Head(); err != nil || head != 2 {
+		t.Errorf("Head() = %d, %v, want 2", head, err)
+	}
+}
+
+func TestByOrigin(t *testing.T) {
+	s := newTestStore(t)
+	// One platform message relayed to two rooms
+	general := appendFrom(t, s, "Discord", "c:1", "general", "hi")
+	random := appendFrom(t, s, "Discord", "c:1", "random", "hi")
+	appendFrom(t, s, "Telegram", "c:1", "general", "other platform")
+
+	msgs, err := s.ByOrigin("Discord", "c:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(msgs), []uint64{general.ID, random.ID}; !slices.Equal(got, want) {
+		t.Errorf("ByOrigin() = %v, want %v", got, want)
+	}
+
+	msgs, err = s.ByOrigin("Discord", "c:2")
+	if err != nil || len(msgs) != 0 {
+		t.Errorf("ByOrigin() of an unknown message = %v, %v, want none", ids(msgs), err)
+	}
+}
+
+func TestCopies(t *testing.T) {
+	s := newTestStore(t)
+	msg := appendFrom(t, s, "Discord", "c:1", "general", "hi")
+	copy := Origin{Platform: "Telegram", BridgeID: "Telegram-1", MessageID: "chat:5:10"}
+	if err := s.AddCopy(msg.ID, copy); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCopy(99, copy); err == nil {
+		t.Error("AddCopy() of an unknown message succeeded")
+	}
+
+	got, err := s.CopyOf("Telegram", "chat:5:10")
+	if err != nil || got == nil || got.ID != msg.ID {
+		t.Errorf("CopyOf() = %+v, %v, want message %d", got, err, msg.ID)
+	}
+	// The original isn't a copy
+	if got, err := s.CopyOf("Discord", "c:1"); err != nil || got != nil {
+		t.Errorf("CopyOf() of the original = %+v, %v, want nil", got, err)
+	}
+
+	msgs, err := s.ByPlatformID("Telegram", "chat:5:10")
+	if err != nil || !slices.Equal(ids(msgs), []uint64{msg.ID}) {
+		t.Errorf("ByPlatformID() of the copy = %v, %v, want [%d]", ids(msgs), err, msg.ID)
+	}
+	msgs, err = s.ByPlatformID("Discord", "c:1")
+	if err != nil || !slices.Equal(ids(msgs), []uint64{msg.ID}) {
+		t.Errorf("ByPlatformID() of the original = %v, %v, want [%d]", ids(msgs), err, msg.ID)
+	}
+}
+
+func TestReplies(t *testing.T) {
+	s := newTestStore(t)
+	general := appendFrom(t, s, "Discord", "c:1", "general", "hi")
+	random := appendFrom(t, s, "Discord", "c:1", "random", "hi")
+	copy := Origin{Platform: "Telegram", BridgeID: "Telegram-1", MessageID: "chat:5:10"}
+	if err := s.AddCopy(random.ID, copy); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		platform string
+		parent   string
+		room     string
+		want     uint64
+		copies   []Origin
+	}{
+		{"original in its room", "Discord", "c:1", "general", general.ID, []Origin{*general.Origin}},
+		{"original in the other room", "Discord", "c:1", "random", random.ID, []Origin{*random.Origin, copy}},
+		{"copy", "Telegram", "chat:5:10", "random", random.ID, []Origin{*random.Origin, copy}},
+		{"unknown", "Telegram", "chat:5:11", "random", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := s.Append(&ping.MessageRequest{
+				Recipient: tt.room,
+				Author:    "bob",
+				Message:   "reply",
+				Origin:    &ping.Origin{Platform: tt.platform, MessageId: "reply"},
+				ReplyTo:   &ping.ReplyTo{MessageId: tt.parent, Author: "someone", Excerpt: "quoted"},
+			}, tt.room)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reply := msg.ReplyTo
+			if reply == nil || reply.ID != tt.want || reply.MessageID != tt.parent {
+				t.Fatalf("ReplyTo = %+v, want parent %d", reply, tt.want)
+			}
+			if !slices.Equal(reply.Copies, tt.copies) {
+				t.Errorf("copies = %+v, want %+v", reply.Copies, tt.copies)
+			}
+			// Known parents are quoted from the store
+			if tt.want != 0 && (reply.Author != "alice" || reply.Excerpt != "hi") {
+				t.Errorf("quote = %s: %q, want alice: \"hi\"", reply.Author, reply.Excerpt)
+			}
+			if tt.want == 0 && (reply.Author != "someone" || reply.Excerpt != "quoted") {
+				t.Errorf("quote = %s: %q, want the bridge's", reply.Author, reply.Excerpt)
+			}
+		})
+	}
+}
+
+func TestReplyExcerpt(t *testing.T) {
+	s := newTestStore(t)
+	long := strings.Repeat("ż", excerptLength+10)
+	appendFrom(t, s, "Discord", "c:1", "general", long)
+
+	msg, err := s.Append(&ping.MessageRequest{
+		Recipient: "general",
+		Origin:    &ping.Origin{Platform: "Discord", MessageId: "c:2"},
+		ReplyTo:   &ping.ReplyTo{MessageId: "c:1"},
+	}, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Repeat("ż", excerptLength) + "…"; msg.ReplyTo.Excerpt != want {
+		t.Errorf("excerpt = %q, want %q", msg.ReplyTo.Excerpt, want)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	s := newTestStore(t)
+	msg := appendFrom(t, s, "Discord", "c:1", "general", "hi")
+	copy := Origin{Platform: "Telegram", BridgeID: "Telegram-1", MessageID: "chat:5:10"}
+	if err := s.AddCopy(msg.ID, copy); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := s.Edit(msg.ID, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != EventEdit || event.Target != msg.ID || event.Content != "hello" || event.Room != "general" {
+		t.Errorf("Edit() = %+v, want an edit of message %d to hello", event, msg.ID)
+	}
+	if !slices.Equal(event.Copies, []Origin{copy}) {
+		t.Errorf("copies = %+v, want %+v", event.Copies, copy)
+	}
+	if sm := event.ServerMessage().GetMessageResponse(); sm.GetEvent() != ping.Event_EVENT_EDIT || sm.GetTargetId() != msg.ID {
+		t.Errorf("ServerMessage() = %v, want an edit of message %d", sm, msg.ID)
+	}
+
+	edited, err := s.ByOrigin("Discord", "c:1")
+	if err != nil || len(edited) != 1 || edited[0].Content != "hello" {
+		t.Errorf("message after Edit() = %+v, %v, want content hello", edited, err)
+	}
+
+	if _, err := s.Edit(99, "hello"); err == nil {
+		t.Error("Edit() of an unknown message succeeded")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := newTestStore(t)
+	msg := appendFrom(t, s, "Discord", "c:1", "general", "hi")
+	from := Origin{Platform: "Discord", BridgeID: "Discord-1", MessageID: "c:1"}
+	edit, err := s.Edit(msg.ID, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reaction, err := s.React(msg.ID, from, "👍", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := s.Delete(msg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != EventDelete || event.Target != msg.ID || event.Content != "" || event.Reactions != nil {
+		t.Errorf("Delete() = %+v, want an empty deletion of message %d", event, msg.ID)
+	}
+
+	// Neither the message nor the events about it keep the text
+	var stored []*Message
+	if err := s.After(0, func(m *Message) error {
+		stored = append(stored, m)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range stored {
+		if m.ID == event.ID {
+			continue
+		}
+		if m.Content != "" || m.Reactions != nil || !m.Deleted {
+			t.Errorf("message %d after Delete() = %+v, want it cleared and deleted", m.ID, m)
+		}
+	}
+	if got, want := ids(stored), []uint64{msg.ID, edit.ID, reaction.ID, event.ID}; !slices.Equal(got, want) {
+		t.Errorf("stored = %v, want %v", got, want)
+	}
+
+	// Deleted messages don't change anymore
+	if event, err := s.Delete(msg.ID); err != nil || event != nil {
+		t.Errorf("second Delete() = %+v, %v, want nil", event, err)
+	}
+	if event, err := s.Edit(msg.ID, "again"); err != nil || event != nil {
+		t.Errorf("Edit() after Delete() = %+v, %v, want nil", event, err)
+	}
+	if event, err := s.React(msg.ID, from, "👍", 2); err != nil || event != nil {
+		t.Errorf("React() after Delete() = %+v, %v, want nil", event, err)
+	}
+}
+
+func TestReact(t *testing.T) {
+	s := newTestStore(t)
+	msg := appendFrom(t, s, "Discord", "c:1", "general", "hi")
+	discord := Origin{Platform: "Discord", BridgeID: "Discord-1", MessageID: "c:1"}
+	telegram := Origin{Platform: "Telegram", BridgeID: "Telegram-1", MessageID: "chat:5:10"}
+
+	steps := []struct {
+		from      Origin
+		emoji     string
+		count     uint32
+		event     string // Empty if no event is stored
+		reactions []Reaction
+	}{
+		{discord, "👍", 2, EventReactionAdd, []Reaction{{"👍", 2, discord}}},
+		{discord, "👍", 2, "", nil},
+		{telegram, "👍", 1, EventReactionAdd, []Reaction{{"👍", 2, discord}, {"👍", 1, telegram}}},
+		{discord, "❤", 1, EventReactionAdd, []Reaction{{"👍", 2, discord}, {"👍", 1, telegram}, {"❤", 1, discord}}},
+		{discord, "👍", 1, EventReactionRemove, []Reaction{{"👍", 1, discord}, {"👍", 1, telegram}, {"❤", 1, discord}}},
+		{telegram, "👍", 0, EventReactionRemove, []Reaction{{"👍", 1, discord}, {"❤", 1, discord}}},
+		{telegram, "🎉", 0, "", nil},
+	}
+	for i, step := range steps {
+		event, err := s.React(msg.ID, step.from, step.emoji, step.count)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if step.event == "" {
+			if event != nil {
+				t.Errorf("step %d: React() = %+v, want nil", i, event)
+			}
+			continue
+		}
+		if event == nil || event.Event != step.event || event.Emoji != step.emoji || event.Target != msg.ID {
+			t.Fatalf("step %d: React() = %+v, want %s %s", i, event, step.event, step.emoji)
+		}
+		if !slices.Equal(event.Reactions, step.reactions) {
+			t.Errorf("step %d: reactions = %+v, want %+v", i, event.Reactions, step.reactions)
+		}
+	}
+}
+
+func TestAfter(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 5; i++ {
+		appendFrom(t, s, "Discord", "", "general", "hi")
+	}
+
+	var got []uint64
+	if err := s.After(2, func(m *Message) error {
+		got = append(got, m.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("After(2) = %v, want %v", got, want)
+	}
+
+	// An error stops the iteration and is returned
+	stop := errors.New("stop")
+	got = nil
+	err := s.After(0, func(m *Message) error {
+		got = append(got, m.ID)
+		if m.ID == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || !slices.Equal(got, []uint64{1, 2}) {
+		t.Errorf("After(0) stopping at 2 = %v, %v, want [1 2], stop", got, err)
+	}
+
+	got = nil
+	if err := s.After(5, func(m *Message) error {
+		got = append(got, m.ID)
+		return nil
+	}); err != nil || got != nil {
+		t.Errorf("After(5) = %v, %v, want none", got, err)
+	}
+}
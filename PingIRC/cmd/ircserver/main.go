@@ -0,0 +1,24 @@
+// Command ircserver runs the fake IRC server, to try the bridge without a
+// real network. Any IRC client can connect to it to talk to the bridge.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/kallazz/Ping/PingIRC/ircserver"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:6667", "Address to listen on")
+	name := flag.String("name", "irc.localhost", "Server name used in replies")
+	flag.Parse()
+
+	srv := ircserver.New(*name)
+	if err := srv.Listen(*addr); err != nil {
+		log.Fatalf("Failed to listen on %s: %v", *addr, err)
+	}
+	fmt.Println("Listening on", srv.Addr())
+	select {}
+}
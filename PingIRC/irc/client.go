@@ -0,0 +1,295 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxTextLength is how many bytes of text one PRIVMSG carries. Servers cut
+// lines at 512 bytes including the prefix they add, so longer texts are
+// split.
+const MaxTextLength = 400
+
+// DefaultLineDelay is the pause between PRIVMSGs if Config has none, so the
+// server doesn't kick the client for flooding.
+const DefaultLineDelay = 300 * time.Millisecond
+
+// ErrClosed is returned by Privmsg once the connection is closed.
+var ErrClosed = errors.New("irc: connection closed")
+
+// Config says where and as whom to connect.
+type Config struct {
+	Addr     string // host:port
+	TLS      bool
+	Password string // Server password (PASS), optional
+	Nick     string
+	User     string // Defaults to Nick
+	RealName string // Defaults to Nick
+
+	// Pause between PRIVMSGs, DefaultLineDelay if 0
+	LineDelay time.Duration
+}
+
+// Client is a registered connection to an IRC server.
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	writeMu sync.Mutex
+	nick    string
+
+	// PRIVMSGs waiting for writeLoop, which sends them LineDelay apart
+	lineDelay time.Duration
+	queue     chan string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Dial connects to the server and registers, trying Nick with underscores
+// appended while it is taken.
+func Dial(ctx context.Context, config Config) (*Client, error) {
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if config.TLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", config.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", config.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("irc: failed to connect to %s: %v", config.Addr, err)
+	}
+
+	c := &Client{
+		conn:      conn,
+		reader:    bufio.NewReader(conn),
+		nick:      config.Nick,
+		lineDelay: config.LineDelay,
+		queue:     make(chan string, 100),
+		done:      make(chan struct{}),
+	}
+	if c.lineDelay == 0 {
+		c.lineDelay = DefaultLineDelay
+	}
+	if err := c.register(ctx, config); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go c.writeLoop()
+	return c, nil
+}
+
+func (c *Client) register(ctx context.Context, config Config) error {
+	user, realName := config.User, config.RealName
+	if user == "" {
+		user = config.Nick
+	}
+	if realName == "" {
+		realName = config.Nick
+	}
+
+	// Registration has to finish in time, ctx can only shorten that
+	deadline := time.Now().Add(30 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.conn.SetReadDeadline(deadline)
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	if config.Password != "" {
+		c.Send("PASS", config.Password)
+	}
+	c.Send("NICK", c.nick)
+	c.Send("USER", user, "0", "*", realName)
+
+	for {
+		m, err := c.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("irc: registration failed: %v", err)
+		}
+		switch m.Command {
+		case "PING":
+			c.Send("PONG", m.Params...)
+		case "001":
+			// The server may have shortened the nick
+			c.nick = m.Param(0)
+			return nil
+		case "432", "433", "436": // Erroneous nick, nick in use, nick collision
+			if m.Command == "432" || len(c.nick) > 30 {
+				return fmt.Errorf("irc: nick %s refused: %s", c.nick, m.Trailing())
+			}
+			c.nick += "_"
+			c.Send("NICK", c.nick)
+		case "ERROR", "464", "465": // Closing link, wrong password, banned
+			return fmt.Errorf("irc: registration refused: %s", m.Trailing())
+		}
+	}
+}
+
+// Nick returns the nick the client is registered as.
+func (c *Client) Nick() string {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.nick
+}
+
+// Send writes one message right away.
+func (c *Client) Send(command string, params ...string) error {
+	line, err := formatLine(command, params...)
+	if err != nil {
+		return err
+	}
+	return c.write(line)
+}
+
+func (c *Client) write(line string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// formatLine formats a message from the client. CR, LF and NUL would end the
+// line early and let the rest pass for another command, so they are refused.
+func formatLine(command string, params ...string) (string, error) {
+	line := (&Message{Command: command, Params: params}).String()
+	if strings.ContainsAny(line, "\r\n\x00") {
+		return "", fmt.Errorf("irc: line break or NUL in %s message", command)
+	}
+	return line, nil
+}
+
+// writeLoop sends the queued PRIVMSGs LineDelay apart until the connection
+// is closed. A failed write closes the connection, which ends Run.
+func (c *Client) writeLoop() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case line := <-c.queue:
+			if err := c.write(line); err != nil {
+				c.stop()
+				c.conn.Close()
+				return
+			}
+		}
+		select {
+		case <-c.done:
+			return
+		case <-time.After(c.lineDelay):
+		}
+	}
+}
+
+// stop ends writeLoop and makes Privmsg fail.
+func (c *Client) stop() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// Join joins a channel.
+func (c *Client) Join(channel string) error {
+	return c.Send("JOIN", channel)
+}
+
+// Privmsg queues text for a channel or nick. Every line of text is sent as
+// its own message, split further if it is too long, and the messages are
+// sent LineDelay apart without blocking the caller.
+func (c *Client) Privmsg(target, text string) error {
+	for _, part := range SplitText(text, MaxTextLength) {
+		line, err := formatLine("PRIVMSG", target, part)
+		if err != nil {
+			return err
+		}
+		select {
+		case c.queue <- line:
+		case <-c.done:
+			return ErrClosed
+		}
+	}
+	return nil
+}
+
+// ReadMessage reads the next message from the server.
+func (c *Client) ReadMessage() (*Message, error) {
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		return ParseMessage(line)
+	}
+}
+
+// Run passes every message from the server to handler, answering PINGs and
+// following nick changes, until the connection breaks.
+func (c *Client) Run(handler func(*Message)) error {
+	for {
+		m, err := c.ReadMessage()
+		if err != nil {
+			return err
+		}
+		switch m.Command {
+		case "PING":
+			c.Send("PONG", m.Params...)
+			continue
+		case "NICK":
+			c.writeMu.Lock()
+			if FoldName(m.Nick()) == FoldName(c.nick) {
+				c.nick = m.Param(0)
+			}
+			c.writeMu.Unlock()
+		case "ERROR":
+			handler(m)
+			return fmt.Errorf("irc: server closed the connection: %s", m.Trailing())
+		}
+		handler(m)
+	}
+}
+
+// Close quits and closes the connection, dropping PRIVMSGs not sent yet.
+func (c *Client) Close() error {
+	c.stop()
+	c.Send("QUIT", "Bye")
+	return c.conn.Close()
+}
+
+// SplitText splits text into its lines and those into parts of at most max
+// bytes, without cutting UTF-8 characters. CR, LF and NUL all end a line, so
+// no part has one, and empty lines are dropped.
+func SplitText(text string, max int) []string {
+	var parts []string
+	for _, line := range strings.FieldsFunc(text, isLineBreak) {
+		for len(line) > max {
+			cut := max
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			// Prefer cutting at a space
+			if i := strings.LastIndexByte(line[:cut], ' '); i > max/2 {
+				cut = i + 1
+			}
+			parts = append(parts, line[:cut])
+			line = line[cut:]
+		}
+		if line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return parts
+}
+
+// isLineBreak reports whether r ends an IRC line.
+func isLineBreak(r rune) bool {
+	return r == '\r' || r == '\n' || r == 0
+}
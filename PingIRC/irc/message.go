@@ -0,0 +1,131 @@
+// Package irc is a small IRC client: registering, joining channels, sending
+// and receiving PRIVMSGs and answering PINGs. It understands IRCv3 message
+// tags but does not negotiate capabilities.
+package irc
+
+import (
+	"errors"
+	"strings"
+)
+
+// Message is one IRC protocol line.
+type Message struct {
+	Tags    map[string]string // IRCv3 tags, e.g. "msgid"
+	Prefix  string            // e.g. "nick!user@host", empty for client messages
+	Command string            // e.g. "PRIVMSG" or "001"
+	Params  []string
+}
+
+// ParseMessage parses a line without its trailing CRLF.
+func ParseMessage(line string) (*Message, error) {
+	line = strings.TrimRight(line, "\r\n")
+	m := &Message{}
+
+	if strings.HasPrefix(line, "@") {
+		var tags string
+		tags, line, _ = strings.Cut(line[1:], " ")
+		m.Tags = make(map[string]string)
+		for _, tag := range strings.Split(tags, ";") {
+			key, value, _ := strings.Cut(tag, "=")
+			m.Tags[key] = unescapeTag(value)
+		}
+		line = strings.TrimLeft(line, " ")
+	}
+	if strings.HasPrefix(line, ":") {
+		m.Prefix, line, _ = strings.Cut(line[1:], " ")
+		line = strings.TrimLeft(line, " ")
+	}
+
+	m.Command, line, _ = strings.Cut(line, " ")
+	if m.Command == "" {
+		return nil, errors.New("irc: empty message")
+	}
+	m.Command = strings.ToUpper(m.Command)
+
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		if strings.HasPrefix(line, ":") {
+			m.Params = append(m.Params, line[1:])
+			break
+		}
+		var param string
+		param, line, _ = strings.Cut(line, " ")
+		if param != "" {
+			m.Params = append(m.Params, param)
+		}
+	}
+	return m, nil
+}
+
+// String formats the message as a protocol line, without the CRLF. Tags are
+// not included, and the parameters are not checked for line breaks, see
+// Client.Send.
+func (m *Message) String() string {
+	var b strings.Builder
+	if m.Prefix != "" {
+		b.WriteString(":" + m.Prefix + " ")
+	}
+	b.WriteString(m.Command)
+	for i, param := range m.Params {
+		b.WriteString(" ")
+		if i == len(m.Params)-1 && (param == "" || strings.HasPrefix(param, ":") || strings.Contains(param, " ")) {
+			b.WriteString(":")
+		}
+		b.WriteString(param)
+	}
+	return b.String()
+}
+
+// Nick returns the nick of the prefix, e.g. "alice" for "alice!a@example.org".
+func (m *Message) Nick() string {
+	nick, _, _ := strings.Cut(m.Prefix, "!")
+	return nick
+}
+
+// Param returns the i-th parameter, or "" if there are fewer.
+func (m *Message) Param(i int) string {
+	if i < len(m.Params) {
+		return m.Params[i]
+	}
+	return ""
+}
+
+// Trailing returns the last parameter, e.g. the text of a PRIVMSG.
+func (m *Message) Trailing() string {
+	if len(m.Params) == 0 {
+		return ""
+	}
+	return m.Params[len(m.Params)-1]
+}
+
+// IsChannel reports whether target is a channel name rather than a nick.
+func IsChannel(target string) bool {
+	return target != "" && strings.ContainsRune("#&+!", rune(target[0]))
+}
+
+// FoldName lower cases a nick or channel name the way IRC servers compare
+// them (rfc1459 casemapping).
+func FoldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '[':
+			return '{'
+		case ']':
+			return '}'
+		case '\\':
+			return '|'
+		case '~':
+			return '^'
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, name)
+}
+
+var tagUnescaper = strings.NewReplacer(`\:`, ";", `\s`, " ", `\\`, `\`, `\r`, "\r", `\n`, "\n")
+
+func unescapeTag(value string) string {
+	return tagUnescaper.Replace(value)
+}
@@ -0,0 +1,335 @@
+// Package ircserver is an in-process fake IRC server, implementing just what
+// the bridge uses: registration, PING, JOIN, PART, PRIVMSG and QUIT. It is
+// meant for integration testing the bridge without a real network:
+//
+//	srv := ircserver.New("irc.test")
+//	srv.Listen("127.0.0.1:0")
+//	defer srv.Close()
+//	// Point the bridge at srv.Addr(), then:
+//	srv.Post("#general", "alice", "hello")
+//	srv.Messages("#general") // What was said, including by the bridge
+package ircserver
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/kallazz/Ping/PingIRC/irc"
+)
+
+// Line is a PRIVMSG seen by the server.
+type Line struct {
+	Nick string
+	Text string
+}
+
+// Server keeps its connections and channels in memory.
+type Server struct {
+	name string
+
+	mu       sync.Mutex
+	listener net.Listener
+	users    map[string]*conn          // Folded nick -> connection
+	channels map[string]map[*conn]bool // Folded channel -> members
+	lines    map[string][]Line         // Folded channel -> PRIVMSGs
+	joined   map[string]chan struct{}  // Folded channel -> closed on any join
+}
+
+type conn struct {
+	net.Conn
+	server     *Server
+	writeMu    sync.Mutex
+	nick       string
+	user       string
+	registered bool
+}
+
+// New creates a server calling itself name in its replies.
+func New(name string) *Server {
+	return &Server{
+		name:     name,
+		users:    make(map[string]*conn),
+		channels: make(map[string]map[*conn]bool),
+		lines:    make(map[string][]Line),
+		joined:   make(map[string]chan struct{}),
+	}
+}
+
+// Listen starts accepting connections on addr, e.g. "127.0.0.1:0".
+func (s *Server) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+	go s.serve(listener)
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listener.Addr().String()
+}
+
+// Close stops listening and drops every connection.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.users {
+		c.Close()
+	}
+	return s.listener.Close()
+}
+
+// Post sends a PRIVMSG to a channel as nick, as if it came from a user that
+// is not connected.
+func (s *Server) Post(channel, nick, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.privmsg(nil, nick+"!"+nick+"@"+s.name, channel, text)
+}
+
+// Messages returns the PRIVMSGs sent to a channel, oldest first.
+func (s *Server) Messages(channel string) []Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Line(nil), s.lines[irc.FoldName(channel)]...)
+}
+
+// Members returns the nicks in a channel.
+func (s *Server) Members(channel string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var nicks []string
+	for c := range s.channels[irc.FoldName(channel)] {
+		nicks = append(nicks, c.nick)
+	}
+	return nicks
+}
+
+// Joined returns a channel that is closed the next time someone joins
+// channel, to wait for the bridge.
+func (s *Server) Joined(channel string) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := irc.FoldName(channel)
+	if s.joined[key] == nil {
+		s.joined[key] = make(chan struct{})
+	}
+	return s.joined[key]
+}
+
+func (s *Server) serve(listener net.Listener) {
+	for {
+		nc, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(&conn{Conn: nc, server: s})
+	}
+}
+
+func (s *Server) handle(c *conn) {
+	defer s.quit(c, "Connection closed")
+	scanner := bufio.NewScanner(c)
+	for scanner.Scan() {
+		m, err := irc.ParseMessage(scanner.Text())
+		if err != nil {
+			continue
+		}
+		if !s.command(c, m) {
+			return
+		}
+	}
+}
+
+// command handles one message from c and reports whether to keep reading.
+func (s *Server) command(c *conn, m *irc.Message) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch m.Command {
+	case "PING":
+		c.send(s.name, "PONG", s.name, m.Param(0))
+	case "PASS", "PONG", "CAP":
+	case "NICK":
+		nick := m.Param(0)
+		if nick == "" || irc.IsChannel(nick) || strings.ContainsAny(nick, " ,*?!@") {
+			c.send(s.name, "432", c.target(), nick, "Erroneous nickname")
+			break
+		}
+		if other := s.users[irc.FoldName(nick)]; other != nil && other != c {
+			c.send(s.name, "433", c.target(), nick, "Nickname is already in use")
+			break
+		}
+		if c.registered {
+			s.broadcast(c, true, c.prefix(), "NICK", nick)
+			delete(s.users, irc.FoldName(c.nick))
+		}
+		c.nick = nick
+		s.users[irc.FoldName(nick)] = c
+		s.welcome(c)
+	case "USER":
+		c.user = m.Param(0)
+		s.welcome(c)
+	case "QUIT":
+		return false
+	default:
+		if !c.registered {
+			c.send(s.name, "451", c.target(), "You have not registered")
+			break
+		}
+		s.registeredCommand(c, m)
+	}
+	return true
+}
+
+func (s *Server) registeredCommand(c *conn, m *irc.Message) {
+	switch m.Command {
+	case "JOIN":
+		for _, channel := range strings.Split(m.Param(0), ",") {
+			s.join(c, channel)
+		}
+	case "PART":
+		for _, channel := range strings.Split(m.Param(0), ",") {
+			key := irc.FoldName(channel)
+			if s.channels[key][c] {
+				s.send(key, nil, c.prefix(), "PART", channel)
+				delete(s.channels[key], c)
+			}
+		}
+	case "PRIVMSG", "NOTICE":
+		if m.Command == "PRIVMSG" {
+			s.privmsg(c, c.prefix(), m.Param(0), m.Param(1))
+		} else if other := s.users[irc.FoldName(m.Param(0))]; other != nil {
+			other.send(c.prefix(), "NOTICE", m.Param(0), m.Param(1))
+		}
+	default:
+		c.send(s.name, "421", c.nick, m.Command, "Unknown command")
+	}
+}
+
+// welcome finishes the registration once both NICK and USER are in.
+func (s *Server) welcome(c *conn) {
+	if c.registered || c.nick == "" || c.user == "" {
+		return
+	}
+	c.registered = true
+	c.send(s.name, "001", c.nick, "Welcome to the fake IRC server "+c.nick)
+	c.send(s.name, "376", c.nick, "End of /MOTD command")
+}
+
+func (s *Server) join(c *conn, channel string) {
+	if !irc.IsChannel(channel) {
+		c.send(s.name, "403", c.nick, channel, "No such channel")
+		return
+	}
+	key := irc.FoldName(channel)
+	if s.channels[key] == nil {
+		s.channels[key] = make(map[*conn]bool)
+	}
+	if s.channels[key][c] {
+		return
+	}
+	s.channels[key][c] = true
+	s.send(key, nil, c.prefix(), "JOIN", channel)
+
+	var nicks []string
+	for member := range s.channels[key] {
+		nicks = append(nicks, member.nick)
+	}
+	c.send(s.name, "353", c.nick, "=", channel, strings.Join(nicks, " "))
+	c.send(s.name, "366", c.nick, channel, "End of /NAMES list")
+
+	if joined := s.joined[key]; joined != nil {
+		close(joined)
+		delete(s.joined, key)
+	}
+}
+
+// privmsg delivers a PRIVMSG from prefix to target. c is the sender, which
+// doesn't get it back, or nil for Post. The caller must hold s.mu.
+func (s *Server) privmsg(c *conn, prefix, target, text string) {
+	if !irc.IsChannel(target) {
+		if other := s.users[irc.FoldName(target)]; other != nil {
+			other.send(prefix, "PRIVMSG", target, text)
+		} else if c != nil {
+			c.send(s.name, "401", c.nick, target, "No such nick")
+		}
+		return
+	}
+	key := irc.FoldName(target)
+	nick, _, _ := strings.Cut(prefix, "!")
+	s.lines[key] = append(s.lines[key], Line{Nick: nick, Text: text})
+	s.send(key, c, prefix, "PRIVMSG", target, text)
+}
+
+// send writes a message to the members of a channel except skip. The caller
+// must hold s.mu.
+func (s *Server) send(channel string, skip *conn, prefix, command string, params ...string) {
+	for member := range s.channels[channel] {
+		if member != skip {
+			member.send(prefix, command, params...)
+		}
+	}
+}
+
+// broadcast writes a message to everyone sharing a channel with c, and to c
+// itself if self is set. The caller must hold s.mu.
+func (s *Server) broadcast(c *conn, self bool, prefix, command string, params ...string) {
+	sent := map[*conn]bool{c: true}
+	if self {
+		c.send(prefix, command, params...)
+	}
+	for _, members := range s.channels {
+		if !members[c] {
+			continue
+		}
+		for member := range members {
+			if !sent[member] {
+				sent[member] = true
+				member.send(prefix, command, params...)
+			}
+		}
+	}
+}
+
+func (s *Server) quit(c *conn, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.registered {
+		s.broadcast(c, false, c.prefix(), "QUIT", reason)
+	}
+	for _, members := range s.channels {
+		delete(members, c)
+	}
+	if s.users[irc.FoldName(c.nick)] == c {
+		delete(s.users, irc.FoldName(c.nick))
+	}
+	c.Close()
+}
+
+func (c *conn) send(prefix, command string, params ...string) {
+	line := (&irc.Message{Prefix: prefix, Command: command, Params: params}).String()
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.Write([]byte(line + "\r\n"))
+}
+
+func (c *conn) prefix() string {
+	return c.nick + "!" + c.user + "@" + c.server.name
+}
+
+// target is the nick to address numeric replies to, "*" before there is one.
+func (c *conn) target() string {
+	if c.nick == "" {
+		return "*"
+	}
+	return c.nick
+}
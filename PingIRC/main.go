@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/PingIRC/irc"
+)
+
+var (
+	// Path of the JSON file mapping IRC channels to Ping rooms, see
+	// bridge.MappingConfig
+	ChannelMapPath string
+
+	// Address of the health endpoint, see pingclient.Subscription
+	HealthAddr string
+
+	// Where the Ping subscription's cursor is kept, see bridge.Runner
+	StateFile string
+)
+
+const (
+	// How long to wait before reconnecting to the IRC server
+	reconnectDelay = 10 * time.Second
+
+	// Longest author prefix, so there is always room for the text
+	maxPrefixLength = irc.MaxTextLength / 2
+)
+
+func init() {
+	flag.StringVar(&HealthAddr, "health", "", "Address to serve the health endpoint on, e.g. :8080")
+	flag.StringVar(&StateFile, "state", "irc.state", "File to keep the position in Ping's message history in across restarts")
+}
+
+// channelConfig is the current channel map, swapped atomically on reload.
+var channelConfig atomic.Pointer[bridge.MappingConfig]
+
+// ircChannels are the channel names of IRC mappings, which are compared
+// ignoring case.
+var ircChannels = bridge.ChannelNames{
+	Check: func(channel string) error {
+		if !irc.IsChannel(channel) {
+			return fmt.Errorf("channel must be a channel name like #general, not %q", channel)
+		}
+		return nil
+	},
+	Fold: irc.FoldName,
+}
+
+// loadChannelConfig reads the channel map.
+func loadChannelConfig() (*bridge.MappingConfig, error) {
+	return bridge.LoadMappings(ChannelMapPath, ircChannels)
+}
+
+// sameChannel compares channel names the way IRC servers do, ignoring case.
+func sameChannel(a, b string) bool {
+	return irc.FoldName(a) == irc.FoldName(b)
+}
+
+func main() {
+	flag.Parse()
+
+	// Load .env if needed
+	godotenv.Load()
+
+	ChannelMapPath = os.Getenv("IRC_CHANNELS")
+	if ChannelMapPath == "" {
+		fmt.Println("IRC_CHANNELS is required, it maps IRC channels to Ping rooms")
+		os.Exit(1)
+	}
+	config, err := loadChannelConfig()
+	if err != nil {
+		fmt.Println("error loading channel map:", err)
+		os.Exit(1)
+	}
+	channelConfig.Store(config)
+
+	ircBridge := newIRCBridge(irc.Config{
+		Addr:     os.Getenv("IRC_SERVER"),
+		TLS:      os.Getenv("IRC_TLS") == "true",
+		Password: os.Getenv("IRC_PASSWORD"),
+		Nick:     os.Getenv("IRC_NICK"),
+	})
+	if ircBridge.config.Nick == "" {
+		ircBridge.config.Nick = "Ping"
+	}
+
+	runner, err := bridge.NewRunner("IRC", ircBridge)
+	if err != nil {
+		fmt.Println("error connecting to the Ping server,", err)
+		return
+	}
+	runner.HealthAddr = HealthAddr
+	runner.StateFile = StateFile
+
+	// Run until CTRL-C or other term signal is received.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	go runner.ReloadMappings(ctx, &channelConfig, loadChannelConfig, ircBridge.updateChannels)
+
+	fmt.Println("Bot is now running.  Press CTRL-C to exit.")
+	if err := runner.Run(ctx); err != nil && ctx.Err() == nil {
+		fmt.Println(err)
+	}
+}
+
+// ircBridge is the IRC side of the bridge.
+type ircBridge struct {
+	config  irc.Config
+	inbound func(bridge.Message)
+
+	mu     sync.Mutex
+	client *irc.Client // nil while reconnecting
+
+	// IRC messages have no IDs unless the server sets the msgid tag, so
+	// the others get one made up of when the bridge started and a counter.
+	session int64
+	count   atomic.Uint64
+}
+
+func newIRCBridge(config irc.Config) *ircBridge {
+	return &ircBridge{config: config, session: time.Now().UnixNano()}
+}
+
+// Start connects to the IRC server and joins the mapped channels. The
+// connection is reopened whenever it breaks, until ctx is done.
+func (b *ircBridge) Start(ctx context.Context) error {
+	client, err := irc.Dial(ctx, b.config)
+	if err != nil {
+		return err
+	}
+	b.connected(client)
+
+	go func() {
+		<-ctx.Done()
+		if client := b.current(); client != nil {
+			client.Close()
+		}
+	}()
+	go b.run(ctx, client)
+	return nil
+}
+
+func (b *ircBridge) OnInbound(handler func(bridge.Message)) {
+	b.inbound = handler
+}
+
+// run reads from client and reconnects when the connection breaks.
+func (b *ircBridge) run(ctx context.Context, client *irc.Client) {
+	for {
+		err := client.Run(b.handle)
+		b.mu.Lock()
+		b.client = nil
+		b.mu.Unlock()
+		client.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("IRC connection lost, reconnecting in %s: %v\n", reconnectDelay, err)
+
+		for {
+			select {
+			case <-time.After(reconnectDelay):
+			case <-ctx.Done():
+				return
+			}
+			client, err = irc.Dial(ctx, b.config)
+			if err == nil {
+				break
+			}
+			fmt.Println("error reconnecting to IRC:", err)
+		}
+		b.connected(client)
+	}
+}
+
+// connected starts using client and joins the mapped channels.
+func (b *ircBridge) connected(client *irc.Client) {
+	fmt.Println("Connected to IRC as", client.Nick())
+	for _, channel := range channelConfig.Load().Channels() {
+		if err := client.Join(channel); err != nil {
+			fmt.Println("error joining IRC channel:", channel, err)
+		}
+	}
+	b.mu.Lock()
+	b.client = client
+	b.mu.Unlock()
+}
+
+// updateChannels joins the channels added to the map and leaves the removed
+// ones.
+func (b *ircBridge) updateChannels(old, config *bridge.MappingConfig) {
+	client := b.current()
+	if client == nil {
+		return // Joined on reconnect
+	}
+	oldChannels, channels := old.Channels(), config.Channels()
+	for _, channel := range channels {
+		if !slices.ContainsFunc(oldChannels, func(c string) bool { return sameChannel(c, channel) }) {
+			client.Join(channel)
+		}
+	}
+	for _, channel := range oldChannels {
+		if !slices.ContainsFunc(channels, func(c string) bool { return sameChannel(c, channel) }) {
+			client.Send("PART", channel)
+		}
+	}
+}
+
+func (b *ircBridge) current() *irc.Client {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.client
+}
+
+// handle forwards channel PRIVMSGs to Ping, with the nick as the author.
+func (b *ircBridge) handle(m *irc.Message) {
+	if m.Command != "PRIVMSG" || !irc.IsChannel(m.Param(0)) {
+		return
+	}
+	if client := b.current(); client != nil && sameChannel(m.Nick(), client.Nick()) {
+		return
+	}
+
+	content, ok := messageText(m.Trailing())
+	if !ok || content == "" {
+		return
+	}
+	id := m.Tags["msgid"]
+	if id == "" {
+		id = fmt.Sprintf("%d:%d", b.session, b.count.Add(1))
+	}
+
+	channel := m.Param(0)
+	for _, mapping := range channelConfig.Load().InboundMappings(channel) {
+		recipient := mapping.Room
+		if recipient == "" {
+			recipient = channel
+		}
+		b.inbound(bridge.Message{
+			ID:        id,
+			Author:    m.Nick(),
+			Recipient: recipient,
+			Content:   content,
+		})
+	}
+}
+
+// messageText returns the text of a PRIVMSG without IRC formatting. /me
+// actions are put in underscores, other CTCP requests are not text.
+func messageText(text string) (string, bool) {
+	if strings.HasPrefix(text, "\x01") {
+		action, ok := strings.CutPrefix(strings.Trim(text, "\x01"), "ACTION ")
+		if !ok {
+			return "", false
+		}
+		return "_" + stripFormatting(action) + "_", true
+	}
+	return stripFormatting(text), true
+}
+
+// stripFormatting removes bold, italics, colors and the other mIRC control
+// codes.
+func stripFormatting(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\x02', '\x0f', '\x11', '\x16', '\x1d', '\x1e', '\x1f':
+		case '\x03':
+			// Up to two digits of foreground, optionally a comma and two
+			// digits of background
+			i += colorCodeLength(text[i+1:])
+		default:
+			b.WriteByte(text[i])
+		}
+	}
+	return b.String()
+}
+
+func colorCodeLength(s string) int {
+	digits := func(s string) int {
+		n := 0
+		for n < 2 && n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		return n
+	}
+	n := digits(s)
+	if n > 0 && n < len(s) && s[n] == ',' {
+		if m := digits(s[n+1:]); m > 0 {
+			n += 1 + m
+		}
+	}
+	return n
+}
+
+// Rooms returns the rooms to receive messages from.
+func (b *ircBridge) Rooms() []string {
+	return channelConfig.Load().Rooms()
+}
+
+// Send posts a message from the Ping server to the mapped IRC channels, one
+// line of the message at a time, each prefixed with its platform and author.
+// IRC messages have no IDs to edit them by, so no copies are returned.
+func (b *ircBridge) Send(msg *ping.MessageResponse) ([]string, error) {
+	client := b.current()
+	if client == nil {
+		return nil, errors.New("not connected to IRC")
+	}
+	// Every line has the prefix, split so it fits too
+	prefix := authorPrefix(msg.Type, msg.Sender)
+	lines := irc.SplitText(bridge.Quote(msg)+msg.Content, irc.MaxTextLength-len(prefix))
+	var errs []error
+	for _, channel := range channelConfig.Load().OutboundChannels(msg.Room, msg.Type) {
+		fmt.Println("Sending message to IRC channel:", channel)
+		for _, line := range lines {
+			if err := client.Privmsg(channel, prefix+line); err != nil {
+				errs = append(errs, fmt.Errorf("error sending message to IRC channel %s: %v", channel, err))
+				break
+			}
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// authorPrefix returns the "[platform] author: " prefix of relayed lines,
+// without line breaks and cut to maxPrefixLength.
+func authorPrefix(platform, author string) string {
+	name := fmt.Sprintf("[%s] %s", platform, author)
+	name = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == 0 {
+			return ' '
+		}
+		return r
+	}, name)
+	if len(name) > maxPrefixLength-2 {
+		name = irc.SplitText(name, maxPrefixLength-2)[0]
+	}
+	return name + ": "
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	bridge "github.com/kallazz/Ping/PingBridge"
+	"github.com/kallazz/Ping/PingBridge/bridgetest"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/PingIRC/irc"
+	"github.com/kallazz/Ping/PingIRC/ircserver"
+)
+
+const testMappings = `{"mappings": [
+	{"channel": "#general", "room": "general"},
+	{"channel": "#feed", "source": "Discord", "direction": "both"},
+	{"channel": "#in", "room": "general", "direction": "in"}
+]}`
+
+// startBridge runs an IRC bridge as Ping against a fake IRC server with
+// testMappings, and returns the server and the messages the bridge sends to
+// Ping once it joined its channels.
+func startBridge(t *testing.T) (*ircserver.Server, *ircBridge, <-chan bridge.Message) {
+	t.Helper()
+	srv := ircserver.New("irc.test")
+	if err := srv.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	ChannelMapPath = bridgetest.WriteMappings(t, testMappings)
+	config, err := loadChannelConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	channelConfig.Store(config)
+
+	var joined []<-chan struct{}
+	for _, channel := range config.Channels() {
+		joined = append(joined, srv.Joined(channel))
+	}
+	b := newIRCBridge(irc.Config{Addr: srv.Addr(), Nick: "Ping"})
+	inbound := bridgetest.Start(t, b)
+	for _, j := range joined {
+		select {
+		case <-j:
+		case <-time.After(bridgetest.Timeout):
+			t.Fatal("the bridge didn't join its channels")
+		}
+	}
+	return srv, b, inbound
+}
+
+// waitLines returns the PRIVMSGs of a channel once there are n.
+func waitLines(t *testing.T, srv *ircserver.Server, channel string, n int) []ircserver.Line {
+	t.Helper()
+	deadline := time.Now().Add(bridgetest.Timeout)
+	for {
+		lines := srv.Messages(channel)
+		if len(lines) >= n || time.Now().After(deadline) {
+			return lines
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRelayToPing(t *testing.T) {
+	srv, _, inbound := startBridge(t)
+
+	tests := []struct {
+		channel   string
+		text      string
+		recipient string
+		content   string
+	}{
+		{"#general", "hello", "general", "hello"},
+		{"#GENERAL", "\x02bold\x02 and \x0304,01red", "general", "bold and red"},
+		{"#in", "\x01ACTION waves\x01", "general", "_waves_"},
+		{"#feed", "hi", "#feed", "hi"},
+	}
+	for _, tt := range tests {
+		srv.Post(tt.channel, "alice", tt.text)
+		msg := bridgetest.Receive(t, inbound)
+		if msg.Author != "alice" || msg.Recipient != tt.recipient || msg.Content != tt.content {
+			t.Errorf("%s in %s relayed as %+v, want alice: %q to %s", tt.text, tt.channel, msg, tt.content, tt.recipient)
+		}
+		if msg.ID == "" {
+			t.Errorf("%s relayed without an ID", tt.text)
+		}
+	}
+
+	// Other CTCP requests aren't text, and unmapped channels aren't relayed
+	srv.Post("#general", "alice", "\x01VERSION\x01")
+	srv.Post("#other", "alice", "not relayed")
+	bridgetest.ExpectNothing(t, inbound)
+}
+
+func TestRelayFromPing(t *testing.T) {
+	srv, b, inbound := startBridge(t)
+	if rooms := b.Rooms(); !slices.Equal(rooms, []string{"general"}) {
+		t.Errorf("Rooms() = %v, want [general]", rooms)
+	}
+
+	if _, err := b.Send(&ping.MessageResponse{Type: "Discord", Sender: "bob", Content: "hi", Room: "general"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []ircserver.Line{{Nick: "Ping", Text: "[Discord] bob: hi"}}
+	for _, channel := range []string{"#general", "#feed"} {
+		if lines := waitLines(t, srv, channel, 1); !slices.Equal(lines, want) {
+			t.Errorf("%s got %+v, want %+v", channel, lines, want)
+		}
+	}
+	if lines := srv.Messages("#in"); len(lines) != 0 {
+		t.Errorf("inbound only channel got %+v", lines)
+	}
+
+	// The bridge's own lines aren't relayed back
+	bridgetest.ExpectNothing(t, inbound)
+}
+
+func TestSplitLines(t *testing.T) {
+	srv, b, _ := startBridge(t)
+
+	long := strings.Repeat("a", irc.MaxTextLength) + strings.Repeat("b", 10)
+	msg := &ping.MessageResponse{Type: "Telegram", Sender: "bob", Content: "one\r\ntwo\n" + long, Room: "general"}
+	if _, err := b.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every line has the prefix and fits with it
+	prefix := "[Telegram] bob: "
+	width := irc.MaxTextLength - len(prefix)
+	var want []ircserver.Line
+	for _, text := range []string{"one", "two", long[:width], long[width:]} {
+		want = append(want, ircserver.Line{Nick: "Ping", Text: prefix + text})
+	}
+	if lines := waitLines(t, srv, "#general", len(want)); !slices.Equal(lines, want) {
+		t.Errorf("#general got %+v, want %+v", lines, want)
+	}
+	// Only #general takes Telegram messages
+	if lines := srv.Messages("#feed"); len(lines) != 0 {
+		t.Errorf("#feed got %+v", lines)
+	}
+}
+
+func TestLineBreaks(t *testing.T) {
+	srv, b, _ := startBridge(t)
+
+	// A bare CR, LF or NUL ends an IRC line, so they can't smuggle in commands
+	msg := &ping.MessageResponse{Type: "Telegram", Sender: "bob\r\nQUIT", Content: "hi\rQUIT :bye\x00JOIN #other", Room: "general"}
+	if _, err := b.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	var want []ircserver.Line
+	for _, text := range []string{"hi", "QUIT :bye", "JOIN #other"} {
+		want = append(want, ircserver.Line{Nick: "Ping", Text: "[Telegram] bob  QUIT: " + text})
+	}
+	if lines := waitLines(t, srv, "#general", len(want)); !slices.Equal(lines, want) {
+		t.Errorf("#general got %+v, want %+v", lines, want)
+	}
+	if members := srv.Members("#other"); len(members) != 0 {
+		t.Errorf("#other has %v", members)
+	}
+}
+
+func TestLongAuthor(t *testing.T) {
+	srv, b, _ := startBridge(t)
+
+	// The prefix is cut so every line fits
+	msg := &ping.MessageResponse{Type: "Telegram", Sender: strings.Repeat("b", irc.MaxTextLength), Content: strings.Repeat("a ", irc.MaxTextLength), Room: "general"}
+	if _, err := b.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	lines := waitLines(t, srv, "#general", 3)
+	if len(lines) < 3 {
+		t.Fatalf("#general got %d lines, want at least 3", len(lines))
+	}
+	for _, line := range lines {
+		if len(line.Text) > irc.MaxTextLength || !strings.HasPrefix(line.Text, "[Telegram] bbb") {
+			t.Errorf("line of %d bytes %.20q..., want at most %d with the author", len(line.Text), line.Text, irc.MaxTextLength)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Command homeserver runs the in-memory Matrix homeserver stand-in, to try
+// the bridge without a real homeserver. It creates the bridge user, a test
+// user and the given rooms, and prints their access tokens.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/kallazz/Ping/PingMatrix/homeserver"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8008", "Address to listen on")
+	serverName := flag.String("name", "localhost", "Server name used in user IDs and aliases")
+	rooms := flag.String("rooms", "general", "Comma separated room aliases to create, without the # and server name")
+	flag.Parse()
+
+	hs := homeserver.New(*serverName)
+	bridgeToken := hs.AddUser("@ping:"+*serverName, "ping", "Ping")
+	userToken := hs.AddUser("@alice:"+*serverName, "alice", "Alice")
+	for _, name := range strings.Split(*rooms, ",") {
+		alias := "#" + name + ":" + *serverName
+		fmt.Printf("Created room %s as %s\n", alias, hs.CreateRoom(alias))
+	}
+	fmt.Println("Bridge user @ping:" + *serverName + ", password ping, token " + bridgeToken)
+	fmt.Println("Test user @alice:" + *serverName + ", password alice, token " + userToken)
+
+	fmt.Println("Listening on", *addr)
+	log.Fatal(http.ListenAndServe(*addr, hs))
+}
@@ -0,0 +1,354 @@
+// Package homeserver is an in-memory stand-in for a Matrix homeserver,
+// implementing just the client-server API the bridge uses. It is meant for
+// integration testing the bridge without a real homeserver:
+//
+//	hs := homeserver.New("localhost")
+//	token := hs.AddUser("@ping:localhost", "secret", "Ping")
+//	room := hs.CreateRoom("#general:localhost")
+//	srv := httptest.NewServer(hs)
+//	// Point the bridge at srv.URL with token, then:
+//	hs.Post(room, "@alice:localhost", "hello")
+//	hs.Messages(room) // What the bridge posted
+package homeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kallazz/Ping/PingMatrix/matrix"
+)
+
+const apiPrefix = "/_matrix/client/v3"
+
+// Homeserver keeps users, rooms and events in memory. It implements
+// http.Handler.
+type Homeserver struct {
+	serverName string
+
+	mu      sync.Mutex
+	users   map[string]*user  // User ID -> user
+	tokens  map[string]string // Access token -> user ID
+	rooms   map[string]*room  // Room ID -> room
+	aliases map[string]string // Alias -> room ID
+	txns    map[string]string // Access token + transaction ID -> event ID
+	events  []roomEvent       // All events, the sync token is an index
+	updated chan struct{}     // Closed and replaced when an event is added
+	nextID  int
+}
+
+type user struct {
+	id          string
+	password    string
+	displayName string
+}
+
+type room struct {
+	id      string
+	members map[string]bool
+}
+
+type roomEvent struct {
+	roomID string
+	event  matrix.Event
+}
+
+// New creates an empty homeserver for serverName, e.g. "localhost".
+func New(serverName string) *Homeserver {
+	return &Homeserver{
+		serverName: serverName,
+		users:      make(map[string]*user),
+		tokens:     make(map[string]string),
+		rooms:      make(map[string]*room),
+		aliases:    make(map[string]string),
+		txns:       make(map[string]string),
+		updated:    make(chan struct{}),
+	}
+}
+
+// AddUser registers a user and returns an access token for it.
+func (hs *Homeserver) AddUser(userID, password, displayName string) string {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.users[userID] = &user{id: userID, password: password, displayName: displayName}
+	return hs.newToken(userID)
+}
+
+// CreateRoom creates an empty room with an optional alias and returns its ID.
+func (hs *Homeserver) CreateRoom(alias string) string {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.nextID++
+	id := fmt.Sprintf("!room%d:%s", hs.nextID, hs.serverName)
+	hs.rooms[id] = &room{id: id, members: make(map[string]bool)}
+	if alias != "" {
+		hs.aliases[alias] = id
+	}
+	return id
+}
+
+// Post adds a text message by userID to a room, joining it first, as if it
+// was sent by another client. It returns the event ID.
+func (hs *Homeserver) Post(roomID, userID, body string) string {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if r := hs.rooms[roomID]; r != nil {
+		r.members[userID] = true
+	}
+	return hs.addMessage(roomID, userID, matrix.MessageContent{MsgType: "m.text", Body: body})
+}
+
+// PostContent is Post with the whole content of the message, e.g. for edits
+// and replies.
+func (hs *Homeserver) PostContent(roomID, userID string, content matrix.MessageContent) string {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if r := hs.rooms[roomID]; r != nil {
+		r.members[userID] = true
+	}
+	return hs.addMessage(roomID, userID, content)
+}
+
+// Messages returns the m.room.message events of a room, oldest first.
+func (hs *Homeserver) Messages(roomID string) []matrix.Event {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	var events []matrix.Event
+	for _, e := range hs.events {
+		if e.roomID == roomID && e.event.Type == "m.room.message" {
+			events = append(events, e.event)
+		}
+	}
+	return events
+}
+
+// ServeHTTP serves the client-server API.
+func (hs *Homeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.EscapedPath(), apiPrefix)
+	if !ok {
+		writeError(w, http.StatusNotFound, "M_UNRECOGNIZED", "unknown endpoint")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, p := range parts {
+		parts[i], _ = url.PathUnescape(p)
+	}
+
+	if r.Method == http.MethodPost && path == "/login" {
+		hs.login(w, r)
+		return
+	}
+	if r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "profile" && parts[2] == "displayname" {
+		hs.displayName(w, parts[1])
+		return
+	}
+
+	userID, ok := hs.authenticate(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "M_UNKNOWN_TOKEN", "unknown access token")
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && path == "/account/whoami":
+		writeJSON(w, map[string]string{"user_id": userID})
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[0] == "join":
+		hs.join(w, userID, parts[1])
+	case r.Method == http.MethodPut && len(parts) == 5 && parts[0] == "rooms" && parts[2] == "send":
+		hs.send(w, r, userID, parts[1], parts[3], parts[4])
+	case r.Method == http.MethodGet && path == "/sync":
+		hs.sync(w, r, userID)
+	default:
+		writeError(w, http.StatusNotFound, "M_UNRECOGNIZED", "unknown endpoint")
+	}
+}
+
+func (hs *Homeserver) login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Type       string `json:"type"`
+		Identifier struct {
+			User string `json:"user"`
+		} `json:"identifier"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Type != "m.login.password" {
+		writeError(w, http.StatusBadRequest, "M_BAD_JSON", "expected a password login")
+		return
+	}
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	userID := req.Identifier.User
+	if !strings.HasPrefix(userID, "@") {
+		userID = "@" + userID + ":" + hs.serverName
+	}
+	u := hs.users[userID]
+	if u == nil || u.password != req.Password {
+		writeError(w, http.StatusForbidden, "M_FORBIDDEN", "invalid username or password")
+		return
+	}
+	writeJSON(w, map[string]string{"access_token": hs.newToken(userID), "user_id": userID})
+}
+
+func (hs *Homeserver) displayName(w http.ResponseWriter, userID string) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	u := hs.users[userID]
+	if u == nil {
+		writeError(w, http.StatusNotFound, "M_NOT_FOUND", "no such user")
+		return
+	}
+	writeJSON(w, map[string]string{"displayname": u.displayName})
+}
+
+func (hs *Homeserver) join(w http.ResponseWriter, userID, roomIDOrAlias string) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	roomID := roomIDOrAlias
+	if id, ok := hs.aliases[roomIDOrAlias]; ok {
+		roomID = id
+	}
+	r := hs.rooms[roomID]
+	if r == nil {
+		writeError(w, http.StatusNotFound, "M_NOT_FOUND", "no such room")
+		return
+	}
+	r.members[userID] = true
+	writeJSON(w, map[string]string{"room_id": roomID})
+}
+
+func (hs *Homeserver) send(w http.ResponseWriter, r *http.Request, userID, roomID, eventType, txnID string) {
+	var content matrix.MessageContent
+	if err := json.NewDecoder(r.Body).Decode(&content); err != nil || eventType != "m.room.message" {
+		writeError(w, http.StatusBadRequest, "M_BAD_JSON", "expected an m.room.message")
+		return
+	}
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	rm := hs.rooms[roomID]
+	if rm == nil || !rm.members[userID] {
+		writeError(w, http.StatusForbidden, "M_FORBIDDEN", "not in the room")
+		return
+	}
+	// Retried transactions return the first event
+	txnKey := bearerToken(r) + "/" + txnID
+	eventID, ok := hs.txns[txnKey]
+	if !ok {
+		eventID = hs.addMessage(roomID, userID, content)
+		hs.txns[txnKey] = eventID
+	}
+	writeJSON(w, map[string]string{"event_id": eventID})
+}
+
+func (hs *Homeserver) sync(w http.ResponseWriter, r *http.Request, userID string) {
+	since := -1
+	if s := r.URL.Query().Get("since"); s != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(s, "s"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "M_INVALID_PARAM", "bad since token")
+			return
+		}
+		since = n
+	}
+	timeout, _ := strconv.Atoi(r.URL.Query().Get("timeout"))
+	deadline := time.After(time.Duration(timeout) * time.Millisecond)
+
+	for {
+		hs.mu.Lock()
+		resp, n := hs.timeline(userID, since)
+		updated := hs.updated
+		hs.mu.Unlock()
+
+		// An initial sync only returns the token, no history
+		if since < 0 || n > 0 {
+			writeJSON(w, resp)
+			return
+		}
+		select {
+		case <-updated:
+		case <-deadline:
+			writeJSON(w, resp)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// timeline returns the events after since in the rooms userID is in, and
+// how many there are. The caller must hold hs.mu.
+func (hs *Homeserver) timeline(userID string, since int) (*matrix.SyncResponse, int) {
+	resp := &matrix.SyncResponse{NextBatch: fmt.Sprintf("s%d", len(hs.events))}
+	resp.Rooms.Join = make(map[string]matrix.JoinedRoom)
+	if since < 0 {
+		return resp, 0
+	}
+	n := 0
+	for _, e := range hs.events[min(since, len(hs.events)):] {
+		if !hs.rooms[e.roomID].members[userID] {
+			continue
+		}
+		joined := resp.Rooms.Join[e.roomID]
+		joined.Timeline.Events = append(joined.Timeline.Events, e.event)
+		resp.Rooms.Join[e.roomID] = joined
+		n++
+	}
+	return resp, n
+}
+
+// addMessage appends an event and wakes up waiting syncs. The caller must
+// hold hs.mu.
+func (hs *Homeserver) addMessage(roomID, userID string, message matrix.MessageContent) string {
+	hs.nextID++
+	eventID := fmt.Sprintf("$event%d", hs.nextID)
+	content, _ := json.Marshal(message)
+	hs.events = append(hs.events, roomEvent{
+		roomID: roomID,
+		event: matrix.Event{
+			Type:      "m.room.message",
+			EventID:   eventID,
+			Sender:    userID,
+			Timestamp: time.Now().UnixMilli(),
+			Content:   content,
+		},
+	})
+	close(hs.updated)
+	hs.updated = make(chan struct{})
+	return eventID
+}
+
+// newToken issues an access token. The caller must hold hs.mu.
+func (hs *Homeserver) newToken(userID string) string {
+	hs.nextID++
+	token := fmt.Sprintf("token%d", hs.nextID)
+	hs.tokens[token] = userID
+	return token
+}
+
+func (hs *Homeserver) authenticate(r *http.Request) (string, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	userID, ok := hs.tokens[bearerToken(r)]
+	return userID, ok
+}
+
+func bearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(matrix.Error{Code: code, Message: message})
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/PingMatrix/matrix"
+)
+
+var (
+	// Path of the JSON file mapping Matrix rooms to Ping rooms, see
+	// bridge.MappingConfig and matrixRooms
+	RoomMapPath string
+
+	// Address of the health endpoint, see pingclient.Subscription
+	HealthAddr string
+
+	// Where the Ping subscription's cursor is kept, see bridge.Runner
+	StateFile string
+)
+
+// How long a /sync waits for new events, and how long to wait before
+// retrying a failed one.
+const (
+	syncTimeout    = 30 * time.Second
+	syncRetryDelay = 5 * time.Second
+)
+
+func init() {
+	flag.StringVar(&HealthAddr, "health", "", "Address to serve the health endpoint on, e.g. :8080")
+	flag.StringVar(&StateFile, "state", "matrix.state", "File to keep the position in Ping's message history in across restarts")
+}
+
+// matrixRooms are the rooms of Matrix mappings, e.g.
+//
+//	{"mappings": [
+//	  {"matrix_room": "#general:example.org", "room": "general", "direction": "both"},
+//	  {"matrix_room": "!abcdef:example.org", "source": "Telegram", "direction": "out"}
+//	]}
+//
+// They may be room IDs or aliases, aliases are resolved to IDs when the bridge
+// joins the room.
+var matrixRooms = bridge.ChannelNames{Key: "matrix_room"}
+
+// roomConfig is the current room map with resolved room IDs, swapped
+// atomically on reload.
+var roomConfig atomic.Pointer[bridge.MappingConfig]
+
+func main() {
+	flag.Parse()
+
+	// Load .env if needed
+	godotenv.Load()
+
+	RoomMapPath = os.Getenv("MATRIX_ROOMS")
+	if RoomMapPath == "" {
+		fmt.Println("MATRIX_ROOMS is required, it maps Matrix rooms to Ping rooms")
+		os.Exit(1)
+	}
+
+	matrixBridge := newMatrixBridge(matrix.NewClient(os.Getenv("MATRIX_HOMESERVER"), os.Getenv("MATRIX_TOKEN")))
+
+	runner, err := bridge.NewRunner("Matrix", matrixBridge)
+	if err != nil {
+		fmt.Println("error connecting to the Ping server,", err)
+		return
+	}
+	runner.HealthAddr = HealthAddr
+	runner.StateFile = StateFile
+
+	// Run until CTRL-C or other term signal is received.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	go runner.ReloadMappings(ctx, &roomConfig, func() (*bridge.MappingConfig, error) {
+		return matrixBridge.loadRoomConfig(ctx)
+	}, nil)
+
+	fmt.Println("Bot is now running.  Press CTRL-C to exit.")
+	if err := runner.Run(ctx); err != nil && ctx.Err() == nil {
+		fmt.Println(err)
+	}
+}
+
+// matrixBridge is the Matrix side of the bridge.
+type matrixBridge struct {
+	client  *matrix.Client
+	inbound func(bridge.Message)
+
+	namesMu sync.Mutex
+	names   map[string]string // User ID -> display name
+}
+
+func newMatrixBridge(client *matrix.Client) *matrixBridge {
+	return &matrixBridge{client: client, names: make(map[string]string)}
+}
+
+// Start logs in, joins the mapped rooms and starts syncing. Only messages
+// sent after Start are relayed, not the room history.
+func (b *matrixBridge) Start(ctx context.Context) error {
+	if b.client.AccessToken == "" {
+		if err := b.client.Login(ctx, os.Getenv("MATRIX_USER"), os.Getenv("MATRIX_PASSWORD")); err != nil {
+			return fmt.Errorf("error logging in to Matrix: %v", err)
+		}
+	} else if _, err := b.client.Whoami(ctx); err != nil {
+		return fmt.Errorf("error checking the Matrix access token: %v", err)
+	}
+	fmt.Println("Logged in to Matrix as", b.client.UserID)
+
+	config, err := b.loadRoomConfig(ctx)
+	if err != nil {
+		return err
+	}
+	roomConfig.Store(config)
+
+	// The initial sync only gives the token to sync from
+	resp, err := b.client.Sync(ctx, "", 0)
+	if err != nil {
+		return fmt.Errorf("error syncing with Matrix: %v", err)
+	}
+	go b.sync(ctx, resp.NextBatch)
+	return nil
+}
+
+// loadRoomConfig reads the room map and joins its rooms, replacing aliases
+// with room IDs.
+func (b *matrixBridge) loadRoomConfig(ctx context.Context) (*bridge.MappingConfig, error) {
+	config, err := bridge.LoadMappings(RoomMapPath, matrixRooms)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]string)
+	for _, room := range config.Channels() {
+		id, err := b.client.JoinRoom(ctx, room)
+		if err != nil {
+			return nil, fmt.Errorf("error joining Matrix room %s: %v", room, err)
+		}
+		ids[room] = id
+	}
+	return config.Resolve(ids), nil
+}
+
+func (b *matrixBridge) OnInbound(handler func(bridge.Message)) {
+	b.inbound = handler
+}
+
+// sync long-polls the homeserver for new events until ctx is done.
+func (b *matrixBridge) sync(ctx context.Context, since string) {
+	for ctx.Err() == nil {
+		resp, err := b.client.Sync(ctx, since, syncTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Printf("error syncing with Matrix, retrying in %s: %v\n", syncRetryDelay, err)
+			select {
+			case <-time.After(syncRetryDelay):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		for roomID, room := range resp.Rooms.Join {
+			for _, event := range room.Timeline.Events {
+				b.roomMessage(ctx, roomID, event)
+			}
+		}
+		since = resp.NextBatch
+	}
+}
+
+func (b *matrixBridge) roomMessage(ctx context.Context, roomID string, event matrix.Event) {
+	if event.Type != "m.room.message" || event.Sender == b.client.UserID {
+		return
+	}
+	content, err := event.Message()
+	if err != nil {
+		fmt.Printf("failed to decode Matrix message %s: %v\n", event.EventID, err)
+		return
+	}
+	switch content.MsgType {
+	case "m.text", "m.notice", "m.emote":
+	default:
+		return
+	}
+
+	var recipients []string
+	if config := roomConfig.Load(); config != nil {
+		for _, mapping := range config.InboundMappings(roomID) {
+			if mapping.Room != "" {
+				recipients = append(recipients, mapping.Room)
+			} else {
+				recipients = append(recipients, roomID)
+			}
+		}
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	// Edits are new events replacing the original one, their body is only a
+	// fallback like "* new text"
+	if original, ok := content.Edits(); ok {
+		body := strings.TrimPrefix(content.Body, "* ")
+		if content.NewContent != nil {
+			body = content.NewContent.Body
+		}
+		b.inbound(bridge.Message{
+			Event:   bridge.EventEdit,
+			ID:      original,
+			Content: body,
+			Version: event.EventID,
+		})
+		return
+	}
+
+	body := content.Body
+	var reply *bridge.Reply
+	if parent, ok := content.RepliesTo(); ok {
+		sender, quote, text := matrix.ReplyFallback(body)
+		body = text
+		reply = &bridge.Reply{ID: parent, Excerpt: quote}
+		if sender != "" {
+			reply.Author = b.displayName(ctx, sender)
+		}
+	}
+
+	author := b.displayName(ctx, event.Sender)
+	for _, recipient := range recipients {
+		b.inbound(bridge.Message{
+			ID:        event.EventID,
+			Author:    author,
+			Recipient: recipient,
+			Content:   body,
+			ReplyTo:   reply,
+		})
+	}
+}
+
+// displayName returns the display name of userID, falling back to the
+// localpart of the ID, e.g. "alice" for "@alice:example.org".
+func (b *matrixBridge) displayName(ctx context.Context, userID string) string {
+	b.namesMu.Lock()
+	name, ok := b.names[userID]
+	b.namesMu.Unlock()
+	if ok {
+		return name
+	}
+
+	name, err := b.client.DisplayName(ctx, userID)
+	if err != nil {
+		fmt.Printf("failed to fetch the display name of %s: %v\n", userID, err)
+	}
+	if name == "" {
+		name, _, _ = strings.Cut(strings.TrimPrefix(userID, "@"), ":")
+	}
+	b.namesMu.Lock()
+	b.names[userID] = name
+	b.namesMu.Unlock()
+	return name
+}
+
+// Rooms returns the rooms to receive messages from.
+func (b *matrixBridge) Rooms() []string {
+	if config := roomConfig.Load(); config != nil {
+		return config.Rooms()
+	}
+	return nil
+}
+
+// Send posts a message from the Ping server to the mapped Matrix rooms.
+func (b *matrixBridge) Send(msg *ping.MessageResponse) ([]string, error) {
+	config := roomConfig.Load()
+	if config == nil {
+		return nil, nil
+	}
+	text := bridge.Quote(msg) + fmt.Sprintf("[%s] %s: %s", msg.Type, msg.Sender, msg.Content)
+	var copies []string
+	for _, roomID := range config.OutboundChannels(msg.Room, msg.Type) {
+		fmt.Println("Sending message to Matrix room:", roomID)
+		eventID, err := b.client.SendText(context.Background(), roomID, text)
+		if err != nil {
+			fmt.Println("error sending message to Matrix room:", roomID, err)
+			continue
+		}
+		copies = append(copies, eventID)
+	}
+	return copies, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	bridge "github.com/kallazz/Ping/PingBridge"
+	"github.com/kallazz/Ping/PingBridge/bridgetest"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/PingMatrix/homeserver"
+	"github.com/kallazz/Ping/PingMatrix/matrix"
+)
+
+// startBridge runs a Matrix bridge against a homeserver stand-in with the
+// room map in mappings, and returns it with the messages it sends to Ping.
+func startBridge(t *testing.T, hs *homeserver.Homeserver, token, mappings string) (*matrixBridge, <-chan bridge.Message) {
+	t.Helper()
+	srv := httptest.NewServer(hs)
+	t.Cleanup(srv.Close)
+
+	RoomMapPath = bridgetest.WriteMappings(t, mappings)
+	t.Cleanup(func() { roomConfig.Store(nil) })
+
+	b := newMatrixBridge(matrix.NewClient(srv.URL, token))
+	return b, bridgetest.Start(t, b)
+}
+
+func TestRelayToPing(t *testing.T) {
+	hs := homeserver.New("localhost")
+	token := hs.AddUser("@ping:localhost", "ping", "Ping")
+	hs.AddUser("@alice:localhost", "alice", "Alice")
+	general := hs.CreateRoom("#general:localhost")
+	feed := hs.CreateRoom("")
+	unmapped := hs.CreateRoom("#unmapped:localhost")
+
+	_, inbound := startBridge(t, hs, token, `{"mappings": [
+		{"matrix_room": "#general:localhost", "room": "general"},
+		{"matrix_room": "`+feed+`", "source": "Discord", "direction": "both"}
+	]}`)
+
+	// Aliases are resolved, the message goes to the mapped Ping room
+	eventID := hs.Post(general, "@alice:localhost", "hello")
+	want := bridge.Message{ID: eventID, Author: "Alice", Recipient: "general", Content: "hello"}
+	if got := bridgetest.Receive(t, inbound); got.ID != want.ID || got.Author != want.Author || got.Recipient != want.Recipient || got.Content != want.Content {
+		t.Errorf("relayed %+v, want %+v", got, want)
+	}
+
+	// Without a Ping room the Matrix room is the recipient, and users without
+	// a display name go by their localpart
+	hs.Post(unmapped, "@bob:localhost", "not relayed")
+	hs.Post(feed, "@bob:localhost", "hi")
+	if got := bridgetest.Receive(t, inbound); got.Recipient != feed || got.Author != "bob" || got.Content != "hi" {
+		t.Errorf("relayed %+v, want bob: hi to %s", got, feed)
+	}
+	bridgetest.ExpectNothing(t, inbound)
+}
+
+func TestRelayFromPing(t *testing.T) {
+	hs := homeserver.New("localhost")
+	token := hs.AddUser("@ping:localhost", "ping", "Ping")
+	general := hs.CreateRoom("#general:localhost")
+	feed := hs.CreateRoom("#feed:localhost")
+	inOnly := hs.CreateRoom("#in:localhost")
+
+	b, inbound := startBridge(t, hs, token, `{"mappings": [
+		{"matrix_room": "#general:localhost", "room": "general"},
+		{"matrix_room": "#feed:localhost", "source": "Discord", "direction": "out"},
+		{"matrix_room": "#in:localhost", "room": "general", "direction": "in"}
+	]}`)
+	if rooms := b.Rooms(); !slices.Equal(rooms, []string{"general"}) {
+		t.Errorf("Rooms() = %v, want [general]", rooms)
+	}
+
+	copies, err := b.Send(&ping.MessageResponse{Type: "Discord", Sender: "bob", Content: "hi", Room: "general"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copies) != 2 {
+		t.Fatalf("Send() posted %d copies, want 2", len(copies))
+	}
+	for _, room := range []string{general, feed} {
+		messages := hs.Messages(room)
+		if len(messages) != 1 {
+			t.Fatalf("%s has %d messages, want 1", room, len(messages))
+		}
+		content, err := messages[0].Message()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content.Body != "[Discord] bob: hi" || messages[0].Sender != "@ping:localhost" {
+			t.Errorf("%s got %s: %q, want the bridge's [Discord] bob: hi", room, messages[0].Sender, content.Body)
+		}
+		if !slices.Contains(copies, messages[0].EventID) {
+			t.Errorf("copies %v don't have %s", copies, messages[0].EventID)
+		}
+	}
+	if messages := hs.Messages(inOnly); len(messages) != 0 {
+		t.Errorf("inbound only room got %d messages", len(messages))
+	}
+
+	// Other sources only reach mapped Ping rooms
+	copies, err = b.Send(&ping.MessageResponse{Type: "Telegram", Sender: "carol", Content: "hey", Room: "random"})
+	if err != nil || len(copies) != 0 {
+		t.Errorf("Send() of an unmapped message = %v, %v, want no copies", copies, err)
+	}
+
+	// The bridge's own messages aren't relayed back
+	bridgetest.ExpectNothing(t, inbound)
+}
+
+func TestEditsAndReplies(t *testing.T) {
+	hs := homeserver.New("localhost")
+	token := hs.AddUser("@ping:localhost", "ping", "Ping")
+	hs.AddUser("@alice:localhost", "alice", "Alice")
+	general := hs.CreateRoom("#general:localhost")
+
+	_, inbound := startBridge(t, hs, token, `{"mappings": [
+		{"matrix_room": "#general:localhost", "room": "general"}
+	]}`)
+
+	original := hs.Post(general, "@alice:localhost", "helo")
+	bridgetest.Receive(t, inbound)
+
+	// An edit replaces the original message instead of being a new one
+	edit := hs.PostContent(general, "@alice:localhost", matrix.MessageContent{
+		MsgType:    "m.text",
+		Body:       "* hello",
+		RelatesTo:  &matrix.RelatesTo{RelType: "m.replace", EventID: original},
+		NewContent: &matrix.MessageContent{MsgType: "m.text", Body: "hello"},
+	})
+	got := bridgetest.Receive(t, inbound)
+	if got.Event != bridge.EventEdit || got.ID != original || got.Content != "hello" || got.Version != edit {
+		t.Errorf("edit relayed as %+v, want an edit of %s to hello", got, original)
+	}
+
+	// Replies lose the quote of their parent, which goes in ReplyTo instead
+	reply := hs.PostContent(general, "@bob:localhost", matrix.MessageContent{
+		MsgType:   "m.text",
+		Body:      "> <@alice:localhost> hello\n> there\n\nhi Alice",
+		RelatesTo: &matrix.RelatesTo{InReplyTo: &matrix.InReplyTo{EventID: original}},
+	})
+	got = bridgetest.Receive(t, inbound)
+	if got.Event != bridge.EventMessage || got.ID != reply || got.Content != "hi Alice" {
+		t.Errorf("reply relayed as %+v, want hi Alice", got)
+	}
+	want := bridge.Reply{ID: original, Author: "Alice", Excerpt: "hello\nthere"}
+	if got.ReplyTo == nil || *got.ReplyTo != want {
+		t.Errorf("reply relayed with ReplyTo %+v, want %+v", got.ReplyTo, want)
+	}
+}
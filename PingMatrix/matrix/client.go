@@ -0,0 +1,291 @@
+// Package matrix is the small part of the Matrix client-server API the bridge
+// needs: logging in, joining rooms, syncing and sending text messages.
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const apiPrefix = "/_matrix/client/v3"
+
+// Error is an error response from the homeserver.
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"errcode"`
+	Message string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("matrix: %s (%d): %s", e.Code, e.Status, e.Message)
+}
+
+// Client talks to one homeserver as one user.
+type Client struct {
+	Homeserver  string // e.g. "https://matrix.example.org"
+	AccessToken string
+	UserID      string // Filled in by Login or Whoami
+
+	HTTP *http.Client
+	txn  atomic.Int64
+}
+
+// NewClient creates a client for homeserver using accessToken, which may be
+// empty until Login is called.
+func NewClient(homeserver, accessToken string) *Client {
+	c := &Client{
+		Homeserver:  strings.TrimRight(homeserver, "/"),
+		AccessToken: accessToken,
+		HTTP:        &http.Client{Timeout: 60 * time.Second},
+	}
+	c.txn.Store(time.Now().UnixNano())
+	return c
+}
+
+// Login logs in with a password and keeps the access token.
+func (c *Client) Login(ctx context.Context, user, password string) error {
+	req := map[string]any{
+		"type":       "m.login.password",
+		"identifier": map[string]string{"type": "m.id.user", "user": user},
+		"password":   password,
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		UserID      string `json:"user_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/login", nil, req, &resp); err != nil {
+		return err
+	}
+	c.AccessToken = resp.AccessToken
+	c.UserID = resp.UserID
+	return nil
+}
+
+// Whoami looks up the user the access token belongs to.
+func (c *Client) Whoami(ctx context.Context) (string, error) {
+	var resp struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/account/whoami", nil, nil, &resp); err != nil {
+		return "", err
+	}
+	c.UserID = resp.UserID
+	return resp.UserID, nil
+}
+
+// JoinRoom joins a room by ID or alias and returns its ID.
+func (c *Client) JoinRoom(ctx context.Context, roomIDOrAlias string) (string, error) {
+	var resp struct {
+		RoomID string `json:"room_id"`
+	}
+	path := "/join/" + url.PathEscape(roomIDOrAlias)
+	if err := c.do(ctx, http.MethodPost, path, nil, struct{}{}, &resp); err != nil {
+		return "", err
+	}
+	return resp.RoomID, nil
+}
+
+// DisplayName returns the display name of a user, or "" if it has none.
+func (c *Client) DisplayName(ctx context.Context, userID string) (string, error) {
+	var resp struct {
+		DisplayName string `json:"displayname"`
+	}
+	path := "/profile/" + url.PathEscape(userID) + "/displayname"
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.DisplayName, nil
+}
+
+// SendText posts a plain text message to a room and returns its event ID.
+func (c *Client) SendText(ctx context.Context, roomID, body string) (string, error) {
+	content := MessageContent{MsgType: "m.text", Body: body}
+	var resp struct {
+		EventID string `json:"event_id"`
+	}
+	path := fmt.Sprintf("/rooms/%s/send/m.room.message/%d", url.PathEscape(roomID), c.txn.Add(1))
+	if err := c.do(ctx, http.MethodPut, path, nil, content, &resp); err != nil {
+		return "", err
+	}
+	return resp.EventID, nil
+}
+
+// Event is a room event, only the fields the bridge uses.
+type Event struct {
+	Type      string          `json:"type"`
+	EventID   string          `json:"event_id"`
+	Sender    string          `json:"sender"`
+	Timestamp int64           `json:"origin_server_ts"`
+	Content   json.RawMessage `json:"content"`
+}
+
+// MessageContent is the content of an m.room.message event.
+type MessageContent struct {
+	MsgType string `json:"msgtype"`
+	Body    string `json:"body"`
+
+	// Set for edits and replies
+	RelatesTo *RelatesTo `json:"m.relates_to,omitempty"`
+
+	// For edits, the new content. Body is only a fallback, e.g. "* new text".
+	NewContent *MessageContent `json:"m.new_content,omitempty"`
+}
+
+// RelatesTo is the event a message edits or replies to.
+type RelatesTo struct {
+	RelType string `json:"rel_type,omitempty"` // "m.replace" for edits
+	EventID string `json:"event_id,omitempty"` // The edited event
+
+	InReplyTo *InReplyTo `json:"m.in_reply_to,omitempty"`
+}
+
+// InReplyTo is the event a reply replies to.
+type InReplyTo struct {
+	EventID string `json:"event_id"`
+}
+
+// Edits returns the ID of the event the message edits, if it is an edit.
+func (m *MessageContent) Edits() (string, bool) {
+	if m.RelatesTo == nil || m.RelatesTo.RelType != "m.replace" || m.RelatesTo.EventID == "" {
+		return "", false
+	}
+	return m.RelatesTo.EventID, true
+}
+
+// RepliesTo returns the ID of the event the message replies to, if it is a
+// reply.
+func (m *MessageContent) RepliesTo() (string, bool) {
+	if m.RelatesTo == nil || m.RelatesTo.InReplyTo == nil || m.RelatesTo.InReplyTo.EventID == "" {
+		return "", false
+	}
+	return m.RelatesTo.InReplyTo.EventID, true
+}
+
+// ReplyFallback splits the body of a reply into the quote of the parent
+// clients put in front for those that don't understand replies, e.g.
+//
+//	> <@alice:example.org> original text
+//
+//	reply text
+//
+// and the reply itself. The quote is returned without the "> " and the
+// sender, which is returned on its own if known.
+func ReplyFallback(body string) (sender, quote, reply string) {
+	lines := strings.Split(body, "\n")
+	n := 0
+	for n < len(lines) && strings.HasPrefix(lines[n], ">") {
+		n++
+	}
+	if n == 0 {
+		return "", "", body
+	}
+	quoted := make([]string, n)
+	for i, line := range lines[:n] {
+		quoted[i] = strings.TrimPrefix(strings.TrimPrefix(line, ">"), " ")
+	}
+	if s, rest, ok := strings.Cut(quoted[0], "> "); ok && strings.HasPrefix(s, "<") {
+		sender, quoted[0] = strings.TrimPrefix(s, "<"), rest
+	}
+	// The quote ends with an empty line
+	if n < len(lines) && lines[n] == "" {
+		n++
+	}
+	return sender, strings.Join(quoted, "\n"), strings.Join(lines[n:], "\n")
+}
+
+// Message decodes the content of an m.room.message event.
+func (e *Event) Message() (*MessageContent, error) {
+	var content MessageContent
+	if err := json.Unmarshal(e.Content, &content); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// SyncResponse is a /sync response, only the timelines of joined rooms.
+type SyncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]JoinedRoom `json:"join"`
+	} `json:"rooms"`
+}
+
+// JoinedRoom is what happened in a joined room since the last sync.
+type JoinedRoom struct {
+	Timeline struct {
+		Events []Event `json:"events"`
+	} `json:"timeline"`
+}
+
+// Sync returns what happened since the since token, waiting up to timeout
+// for something to happen. An empty since returns the current state.
+func (c *Client) Sync(ctx context.Context, since string, timeout time.Duration) (*SyncResponse, error) {
+	query := url.Values{"timeout": {strconv.FormatInt(timeout.Milliseconds(), 10)}}
+	if since != "" {
+		query.Set("since", since)
+	}
+	var resp SyncResponse
+	if err := c.do(ctx, http.MethodGet, "/sync", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	u := c.Homeserver + apiPrefix + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &Error{Status: resp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Code == "" {
+			apiErr.Code = "M_UNKNOWN"
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return apiErr
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("matrix: failed to decode %s response: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/PingSlack/slack"
+)
+
+var (
+	// Path of the JSON file mapping Slack channels to Ping rooms, see
+	// bridge.MappingConfig
+	ChannelMapPath string
+
+	// Address of the health endpoint, see pingclient.Subscription
+	HealthAddr string
+
+	// Where the Ping subscription's cursor is kept, see bridge.Runner
+	StateFile string
+
+	// Picture of bridged authors, with {author} and {platform} replaced. The
+	// default emoji is used without it.
+	IconURL string
+)
+
+const (
+	// How long to wait before reopening a Socket Mode connection that failed
+	reconnectDelay = 5 * time.Second
+
+	// Picture of bridged authors when SLACK_ICON_URL is not set
+	defaultIconEmoji = ":speech_balloon:"
+)
+
+func init() {
+	flag.StringVar(&HealthAddr, "health", "", "Address to serve the health endpoint on, e.g. :8080")
+	flag.StringVar(&StateFile, "state", "slack.state", "File to keep the position in Ping's message history in across restarts")
+}
+
+// channelConfig is the current channel map, swapped atomically on reload.
+var channelConfig atomic.Pointer[bridge.MappingConfig]
+
+// loadChannelConfig reads the channel map.
+func loadChannelConfig() (*bridge.MappingConfig, error) {
+	return bridge.LoadMappings(ChannelMapPath, bridge.ChannelNames{})
+}
+
+func main() {
+	flag.Parse()
+
+	// Load .env if needed
+	godotenv.Load()
+
+	IconURL = os.Getenv("SLACK_ICON_URL")
+	ChannelMapPath = os.Getenv("SLACK_CHANNELS")
+	if ChannelMapPath == "" {
+		fmt.Println("SLACK_CHANNELS is required, it maps Slack channels to Ping rooms")
+		os.Exit(1)
+	}
+	config, err := loadChannelConfig()
+	if err != nil {
+		fmt.Println("error loading channel map:", err)
+		os.Exit(1)
+	}
+	channelConfig.Store(config)
+
+	client := slack.NewClient(os.Getenv("SLACK_BOT_TOKEN"), os.Getenv("SLACK_APP_TOKEN"))
+	if apiURL := os.Getenv("SLACK_API_URL"); apiURL != "" {
+		client.APIURL = apiURL
+	}
+	slackBridge := newSlackBridge(client)
+
+	runner, err := bridge.NewRunner("Slack", slackBridge)
+	if err != nil {
+		fmt.Println("error connecting to the Ping server,", err)
+		return
+	}
+	runner.HealthAddr = HealthAddr
+	runner.StateFile = StateFile
+
+	// Run until CTRL-C or other term signal is received.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	go runner.ReloadMappings(ctx, &channelConfig, loadChannelConfig, nil)
+
+	fmt.Println("Bot is now running.  Press CTRL-C to exit.")
+	if err := runner.Run(ctx); err != nil && ctx.Err() == nil {
+		fmt.Println(err)
+	}
+}
+
+// slackBridge is the Slack side of the bridge.
+type slackBridge struct {
+	client   *slack.Client
+	identity *slack.Identity
+	inbound  func(bridge.Message)
+
+	namesMu sync.Mutex
+	names   map[string]string // User ID -> display name
+}
+
+func newSlackBridge(client *slack.Client) *slackBridge {
+	return &slackBridge{client: client, names: make(map[string]string)}
+}
+
+// Start checks the tokens and opens a Socket Mode connection, which is
+// reopened whenever Slack closes it, until ctx is done.
+func (b *slackBridge) Start(ctx context.Context) error {
+	identity, err := b.client.AuthTest(ctx)
+	if err != nil {
+		return fmt.Errorf("error checking the Slack bot token: %v", err)
+	}
+	b.identity = identity
+	fmt.Printf("Logged in to Slack team %s as %s\n", identity.Team, identity.UserID)
+
+	socket, err := b.client.ConnectSocketMode(ctx)
+	if err != nil {
+		return fmt.Errorf("error opening Slack Socket Mode connection: %v", err)
+	}
+	go b.run(ctx, socket)
+	return nil
+}
+
+func (b *slackBridge) OnInbound(handler func(bridge.Message)) {
+	b.inbound = handler
+}
+
+// run handles events from socket and reconnects when it closes.
+func (b *slackBridge) run(ctx context.Context, socket *slack.SocketMode) {
+	for {
+		stop := context.AfterFunc(ctx, func() { socket.Close() })
+		err := socket.Run(func(event *slack.MessageEvent) {
+			b.messageEvent(ctx, event)
+		})
+		stop()
+		socket.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Println("Slack Socket Mode connection closed, reconnecting:", err)
+
+		for {
+			socket, err = b.client.ConnectSocketMode(ctx)
+			if err == nil {
+				break
+			}
+			fmt.Printf("error reopening Slack Socket Mode connection, retrying in %s: %v\n", reconnectDelay, err)
+			select {
+			case <-time.After(reconnectDelay):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (b *slackBridge) messageEvent(ctx context.Context, event *slack.MessageEvent) {
+	// What the bridge posted comes back with its bot ID
+	if event.BotID == b.identity.BotID || event.User == b.identity.UserID || event.User == "" {
+		return
+	}
+	// Edits, joins and other bots' messages have a subtype
+	content := slack.PlainText(event.Text, func(id string) string { return b.displayName(ctx, id) })
+	switch event.Subtype {
+	case "", "thread_broadcast":
+	case "me_message":
+		content = "_" + content + "_"
+	default:
+		return
+	}
+
+	author := b.displayName(ctx, event.User)
+	for _, mapping := range channelConfig.Load().InboundMappings(event.Channel) {
+		recipient := mapping.Room
+		if recipient == "" {
+			recipient = event.Channel
+		}
+		b.inbound(bridge.Message{
+			ID:        event.Channel + ":" + event.TS,
+			Author:    author,
+			Recipient: recipient,
+			Content:   content,
+		})
+	}
+}
+
+// displayName returns the display name of userID, falling back to the ID.
+func (b *slackBridge) displayName(ctx context.Context, userID string) string {
+	b.namesMu.Lock()
+	name, ok := b.names[userID]
+	b.namesMu.Unlock()
+	if ok {
+		return name
+	}
+
+	user, err := b.client.UserInfo(ctx, userID)
+	if err != nil {
+		fmt.Printf("failed to fetch Slack user %s: %v\n", userID, err)
+		return userID
+	}
+	name = user.DisplayName()
+	b.namesMu.Lock()
+	b.names[userID] = name
+	b.namesMu.Unlock()
+	return name
+}
+
+// Rooms returns the rooms to receive messages from.
+func (b *slackBridge) Rooms() []string {
+	return channelConfig.Load().Rooms()
+}
+
+// Send posts a message from the Ping server to the mapped Slack channels. It
+// is posted under the author's name rather than the bot's, so bridged authors
+// look like Slack users.
+func (b *slackBridge) Send(msg *ping.MessageResponse) ([]string, error) {
+	post := slack.PostMessage{
+		Text:     slack.Escape(bridge.Quote(msg) + msg.Content),
+		Username: fmt.Sprintf("%s (%s)", msg.Sender, msg.Type),
+	}
+	if IconURL != "" {
+		post.IconURL = strings.NewReplacer(
+			"{author}", url.QueryEscape(msg.Sender),
+			"{platform}", url.QueryEscape(msg.Type),
+		).Replace(IconURL)
+	} else {
+		post.IconEmoji = defaultIconEmoji
+	}
+
+	var copies []string
+	for _, channel := range channelConfig.Load().OutboundChannels(msg.Room, msg.Type) {
+		fmt.Println("Sending message to Slack channel:", channel)
+		post.Channel = channel
+		ts, err := b.client.PostMessage(context.Background(), post)
+		if err != nil {
+			fmt.Println("error sending message to Slack channel:", channel, err)
+			continue
+		}
+		copies = append(copies, channel+":"+ts)
+	}
+	return copies, nil
+}
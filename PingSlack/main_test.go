@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	bridge "github.com/kallazz/Ping/PingBridge"
+	"github.com/kallazz/Ping/PingBridge/bridgetest"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"github.com/kallazz/Ping/PingSlack/mockslack"
+	"github.com/kallazz/Ping/PingSlack/slack"
+)
+
+const testMappings = `{"mappings": [
+	{"channel": "C1", "room": "general"},
+	{"channel": "C2", "source": "Discord", "direction": "both"},
+	{"channel": "C3", "room": "general", "direction": "in"}
+]}`
+
+// startBridge runs a Slack bridge against a mock of Slack with testMappings,
+// and returns the mock and the messages the bridge sends to Ping once its
+// Socket Mode connection is open.
+func startBridge(t *testing.T) (*mockslack.Server, *slackBridge, <-chan bridge.Message) {
+	t.Helper()
+	mock := mockslack.New("xoxb-test", "xapp-test")
+	mock.AddUser("U1", "alice", "Alice")
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	ChannelMapPath = bridgetest.WriteMappings(t, testMappings)
+	config, err := loadChannelConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	channelConfig.Store(config)
+
+	client := slack.NewClient("xoxb-test", "xapp-test")
+	client.APIURL = srv.URL + "/api/"
+	b := newSlackBridge(client)
+	connected := mock.Connected()
+	inbound := bridgetest.Start(t, b)
+	waitConnected(t, connected)
+	return mock, b, inbound
+}
+
+// waitConnected waits for a Socket Mode connection, see mockslack.Connected.
+func waitConnected(t *testing.T, connected <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-connected:
+	case <-time.After(bridgetest.Timeout):
+		t.Fatal("no Socket Mode connection")
+	}
+}
+
+// waitAcked waits until every Socket Mode envelope was acknowledged.
+func waitAcked(t *testing.T, mock *mockslack.Server) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for mock.Unacked() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d envelopes not acknowledged", mock.Unacked())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRelayToPing(t *testing.T) {
+	mock, _, inbound := startBridge(t)
+
+	tests := []struct {
+		channel   string
+		user      string
+		text      string
+		author    string
+		recipient string
+		content   string
+	}{
+		{"C1", "U1", "hello", "Alice", "general", "hello"},
+		{"C3", "U1", "<@U1> see <https://example.org|this> &amp; that", "Alice", "general", "@Alice see this (https://example.org) & that"},
+		{"C2", "U2", "hi", "U2", "C2", "hi"}, // Unknown users go by their ID
+	}
+	for _, tt := range tests {
+		ts := mock.Post(tt.channel, tt.user, tt.text)
+		msg := bridgetest.Receive(t, inbound)
+		want := bridge.Message{ID: tt.channel + ":" + ts, Author: tt.author, Recipient: tt.recipient, Content: tt.content}
+		if msg.ID != want.ID || msg.Author != want.Author || msg.Recipient != want.Recipient || msg.Content != want.Content {
+			t.Errorf("%q relayed as %+v, want %+v", tt.text, msg, want)
+		}
+	}
+
+	mock.Post("C4", "U1", "unmapped")
+	bridgetest.ExpectNothing(t, inbound)
+	waitAcked(t, mock)
+}
+
+func TestReconnect(t *testing.T) {
+	mock, _, inbound := startBridge(t)
+
+	// Slack asks for a new connection every few hours
+	connected := mock.Connected()
+	mock.Disconnect()
+	waitConnected(t, connected)
+
+	ts := mock.Post("C1", "U1", "still here")
+	if msg := bridgetest.Receive(t, inbound); msg.ID != "C1:"+ts || msg.Content != "still here" {
+		t.Errorf("relayed %+v after reconnecting, want still here", msg)
+	}
+	waitAcked(t, mock)
+}
+
+func TestRelayFromPing(t *testing.T) {
+	mock, b, inbound := startBridge(t)
+	if rooms := b.Rooms(); !slices.Equal(rooms, []string{"general"}) {
+		t.Errorf("Rooms() = %v, want [general]", rooms)
+	}
+
+	copies, err := b.Send(&ping.MessageResponse{Type: "Discord", Sender: "bob", Content: "a < b & c", Room: "general"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, channel := range []string{"C1", "C2"} {
+		messages := mock.Messages(channel)
+		if len(messages) != 1 {
+			t.Fatalf("%s has %d messages, want 1", channel, len(messages))
+		}
+		m := messages[0]
+		if m.Text != "a &lt; b &amp; c" || m.Username != "bob (Discord)" || m.IconEmoji != defaultIconEmoji || m.IconURL != "" {
+			t.Errorf("%s got %+v, want bob (Discord) with the default icon", channel, m)
+		}
+		want = append(want, channel+":"+m.TS)
+	}
+	if !slices.Equal(copies, want) {
+		t.Errorf("copies = %v, want %v", copies, want)
+	}
+	if messages := mock.Messages("C3"); len(messages) != 0 {
+		t.Errorf("inbound only channel got %+v", messages)
+	}
+
+	// The bot_message events of the bridge's posts aren't relayed back
+	bridgetest.ExpectNothing(t, inbound)
+	waitAcked(t, mock)
+}
+
+func TestIconURL(t *testing.T) {
+	mock, b, _ := startBridge(t)
+	IconURL = "https://avatars.example.org/{platform}/{author}.png"
+	t.Cleanup(func() { IconURL = "" })
+
+	if _, err := b.Send(&ping.MessageResponse{Type: "Telegram", Sender: "bob smith", Content: "hi", Room: "general"}); err != nil {
+		t.Fatal(err)
+	}
+	messages := mock.Messages("C1")
+	if len(messages) != 1 {
+		t.Fatalf("C1 has %d messages, want 1", len(messages))
+	}
+	m := messages[0]
+	if m.Username != "bob smith (Telegram)" || m.IconURL != "https://avatars.example.org/Telegram/bob+smith.png" || m.IconEmoji != "" {
+		t.Errorf("C1 got %+v, want bob smith (Telegram) with their icon", m)
+	}
+	// Only Discord messages go to C2
+	if messages := mock.Messages("C2"); len(messages) != 0 {
+		t.Errorf("C2 got %+v", messages)
+	}
+}
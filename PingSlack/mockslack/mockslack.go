@@ -0,0 +1,263 @@
+// Package mockslack is a mock of the Slack Web API and Socket Mode, just what
+// the bridge uses. It is meant for integration testing the bridge without
+// Slack:
+//
+//	mock := mockslack.New("xoxb-test", "xapp-test")
+//	mock.AddUser("U1", "alice", "Alice")
+//	srv := httptest.NewServer(mock)
+//	// Point the bridge at srv.URL+"/api/", wait for mock.Connected(), then:
+//	mock.Post("C1", "U1", "hello")
+//	mock.Messages("C1") // What the bridge posted, with its username and icon
+package mockslack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/kallazz/Ping/PingSlack/slack"
+)
+
+// The bot the tokens belong to.
+const (
+	BotUserID = "UBOT"
+	BotID     = "BBOT"
+)
+
+// Message is a message posted with chat.postMessage.
+type Message struct {
+	slack.PostMessage
+	TS string
+}
+
+// Server keeps users, posted messages and Socket Mode connections in memory.
+// It implements http.Handler, serving the Web API under /api/.
+type Server struct {
+	botToken string
+	appToken string
+
+	mu        sync.Mutex
+	users     map[string]*slack.User
+	messages  map[string][]Message // Channel -> messages
+	sockets   map[*websocket.Conn]bool
+	unacked   map[string]bool // Envelope IDs sent but not acknowledged
+	connected chan struct{}   // Closed on the next Socket Mode hello
+	nextID    int
+}
+
+// New creates a mock accepting botToken for the Web API and appToken for
+// Socket Mode.
+func New(botToken, appToken string) *Server {
+	return &Server{
+		botToken:  botToken,
+		appToken:  appToken,
+		users:     make(map[string]*slack.User),
+		messages:  make(map[string][]Message),
+		sockets:   make(map[*websocket.Conn]bool),
+		unacked:   make(map[string]bool),
+		connected: make(chan struct{}),
+	}
+}
+
+// AddUser adds a workspace member.
+func (s *Server) AddUser(id, name, displayName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := &slack.User{ID: id, Name: name}
+	user.Profile.DisplayName = displayName
+	s.users[id] = user
+}
+
+// Post sends a message event from userID to every Socket Mode connection, as
+// if the user wrote in channel. It returns the message timestamp.
+func (s *Server) Post(channel, userID, text string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := s.newTS()
+	s.sendEvent(slack.MessageEvent{Type: "message", Channel: channel, User: userID, Text: text, TS: ts})
+	return ts
+}
+
+// Messages returns what was posted to channel with chat.postMessage, oldest
+// first.
+func (s *Server) Messages(channel string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages[channel]...)
+}
+
+// Connected returns a channel that is closed when the next Socket Mode
+// connection is established.
+func (s *Server) Connected() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
+// Unacked returns how many envelopes have not been acknowledged.
+func (s *Server) Unacked() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.unacked)
+}
+
+// Disconnect asks every Socket Mode connection to reconnect, like Slack does
+// every few hours.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.sockets {
+		conn.WriteJSON(slack.Envelope{Type: "disconnect", Reason: "refresh_requested"})
+	}
+}
+
+// ServeHTTP serves the Web API under /api/ and Socket Mode on /socket.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/socket" {
+		s.socket(w, r)
+		return
+	}
+	method, ok := strings.CutPrefix(r.URL.Path, "/api/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	wantToken := s.botToken
+	if method == "apps.connections.open" {
+		wantToken = s.appToken
+	}
+	if token != wantToken {
+		writeResult(w, "invalid_auth", nil)
+		return
+	}
+
+	switch method {
+	case "auth.test":
+		writeResult(w, "", map[string]any{"user_id": BotUserID, "bot_id": BotID, "team": "Mock"})
+	case "apps.connections.open":
+		s.mu.Lock()
+		s.nextID++
+		ticket := s.nextID
+		s.mu.Unlock()
+		writeResult(w, "", map[string]any{"url": fmt.Sprintf("ws://%s/socket?ticket=%d", r.Host, ticket)})
+	case "users.info":
+		r.ParseForm()
+		s.mu.Lock()
+		user := s.users[r.Form.Get("user")]
+		s.mu.Unlock()
+		if user == nil {
+			writeResult(w, "user_not_found", nil)
+			return
+		}
+		writeResult(w, "", map[string]any{"user": user})
+	case "chat.postMessage":
+		s.postMessage(w, r)
+	default:
+		writeResult(w, "unknown_method", nil)
+	}
+}
+
+func (s *Server) postMessage(w http.ResponseWriter, r *http.Request) {
+	var msg slack.PostMessage
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		writeResult(w, "invalid_form_data", nil)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		writeResult(w, "invalid_json", nil)
+		return
+	}
+	if msg.Channel == "" {
+		writeResult(w, "channel_not_found", nil)
+		return
+	}
+	if msg.Text == "" {
+		writeResult(w, "no_text", nil)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := s.newTS()
+	s.messages[msg.Channel] = append(s.messages[msg.Channel], Message{PostMessage: msg, TS: ts})
+	// Like Slack, the bot's own messages come back as events
+	s.sendEvent(slack.MessageEvent{
+		Type:    "message",
+		Subtype: "bot_message",
+		Channel: msg.Channel,
+		BotID:   BotID,
+		Text:    msg.Text,
+		TS:      ts,
+	})
+	writeResult(w, "", map[string]any{"channel": msg.Channel, "ts": ts})
+}
+
+var upgrader = websocket.Upgrader{}
+
+func (s *Server) socket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	s.mu.Lock()
+	conn.WriteJSON(slack.Envelope{Type: "hello"})
+	s.sockets[conn] = true
+	close(s.connected)
+	s.connected = make(chan struct{})
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.sockets, conn)
+		s.mu.Unlock()
+	}()
+	for {
+		var ack struct {
+			EnvelopeID string `json:"envelope_id"`
+		}
+		if err := conn.ReadJSON(&ack); err != nil {
+			return
+		}
+		s.mu.Lock()
+		delete(s.unacked, ack.EnvelopeID)
+		s.mu.Unlock()
+	}
+}
+
+// sendEvent wraps an event in an events_api envelope and sends it to every
+// connection. The caller must hold s.mu.
+func (s *Server) sendEvent(event slack.MessageEvent) {
+	eventJSON, _ := json.Marshal(event)
+	payload, _ := json.Marshal(slack.EventCallback{Type: "event_callback", Event: eventJSON})
+	for conn := range s.sockets {
+		s.nextID++
+		id := fmt.Sprintf("envelope-%d", s.nextID)
+		s.unacked[id] = true
+		conn.WriteJSON(slack.Envelope{EnvelopeID: id, Type: "events_api", Payload: payload})
+	}
+}
+
+// newTS returns a unique message timestamp. The caller must hold s.mu.
+func (s *Server) newTS() string {
+	s.nextID++
+	return fmt.Sprintf("1700000000.%06d", s.nextID)
+}
+
+func writeResult(w http.ResponseWriter, errorCode string, fields map[string]any) {
+	result := map[string]any{"ok": errorCode == ""}
+	if errorCode != "" {
+		result["error"] = errorCode
+	}
+	for key, value := range fields {
+		result[key] = value
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
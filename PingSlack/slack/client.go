@@ -0,0 +1,195 @@
+// Package slack is the small part of the Slack API the bridge needs: posting
+// messages as other users, looking up users and receiving events over Socket
+// Mode.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultAPIURL is where the Web API lives.
+const DefaultAPIURL = "https://slack.com/api/"
+
+// Error is an error response from the Web API, e.g. "channel_not_found".
+type Error struct {
+	Method string
+	Code   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("slack: %s failed: %s", e.Method, e.Code)
+}
+
+// Client calls the Web API with a bot token, and opens Socket Mode
+// connections with an app-level token.
+type Client struct {
+	APIURL   string // Defaults to DefaultAPIURL
+	BotToken string // xoxb-...
+	AppToken string // xapp-..., only needed for Socket Mode
+
+	HTTP *http.Client
+}
+
+// NewClient creates a client for the real Slack API.
+func NewClient(botToken, appToken string) *Client {
+	return &Client{
+		APIURL:   DefaultAPIURL,
+		BotToken: botToken,
+		AppToken: appToken,
+		HTTP:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Identity is who the bot token belongs to.
+type Identity struct {
+	UserID string `json:"user_id"`
+	BotID  string `json:"bot_id"`
+	Team   string `json:"team"`
+}
+
+// AuthTest checks the bot token and returns who it belongs to.
+func (c *Client) AuthTest(ctx context.Context) (*Identity, error) {
+	var resp Identity
+	if err := c.call(ctx, "auth.test", c.BotToken, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// User is a workspace member, only the fields the bridge uses.
+type User struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Profile struct {
+		DisplayName string `json:"display_name"`
+		RealName    string `json:"real_name"`
+	} `json:"profile"`
+}
+
+// DisplayName returns the name Slack shows for the user.
+func (u *User) DisplayName() string {
+	if u.Profile.DisplayName != "" {
+		return u.Profile.DisplayName
+	}
+	if u.Profile.RealName != "" {
+		return u.Profile.RealName
+	}
+	return u.Name
+}
+
+// UserInfo looks up a user by ID.
+func (c *Client) UserInfo(ctx context.Context, userID string) (*User, error) {
+	var resp struct {
+		User User `json:"user"`
+	}
+	if err := c.call(ctx, "users.info", c.BotToken, url.Values{"user": {userID}}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+// PostMessage is a chat.postMessage request. Username and the icon override
+// the bot's own name and picture, which needs the chat:write.customize scope.
+type PostMessage struct {
+	Channel   string `json:"channel"`
+	Text      string `json:"text"`
+	Username  string `json:"username,omitempty"`
+	IconURL   string `json:"icon_url,omitempty"`
+	IconEmoji string `json:"icon_emoji,omitempty"`
+}
+
+// PostMessage posts a message and returns its timestamp, which is its ID in
+// the channel.
+func (c *Client) PostMessage(ctx context.Context, msg PostMessage) (string, error) {
+	var resp struct {
+		TS string `json:"ts"`
+	}
+	if err := c.call(ctx, "chat.postMessage", c.BotToken, msg, &resp); err != nil {
+		return "", err
+	}
+	return resp.TS, nil
+}
+
+// OpenConnection asks for a Socket Mode WebSocket URL, see SocketMode.
+func (c *Client) OpenConnection(ctx context.Context) (string, error) {
+	var resp struct {
+		URL string `json:"url"`
+	}
+	if err := c.call(ctx, "apps.connections.open", c.AppToken, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.URL, nil
+}
+
+// call posts body to a Web API method and decodes the response into result.
+// Read methods like users.info only take form arguments, so url.Values and
+// nil bodies are sent as a form, anything else as JSON. Slack reports errors
+// with "ok": false and a 200 status.
+func (c *Client) call(ctx context.Context, method, token string, body, result any) error {
+	var data []byte
+	contentType := "application/x-www-form-urlencoded"
+	switch body := body.(type) {
+	case nil:
+	case url.Values:
+		data = []byte(body.Encode())
+	default:
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+		contentType = "application/json; charset=utf-8"
+	}
+	apiURL := c.APIURL
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	u, err := url.JoinPath(apiURL, method)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack: %s failed: %s", method, resp.Status)
+	}
+
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("slack: failed to decode %s response: %v", method, err)
+	}
+	if !status.OK {
+		return &Error{Method: method, Code: status.Error}
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("slack: failed to decode %s response: %v", method, err)
+	}
+	return nil
+}
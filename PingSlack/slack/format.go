@@ -0,0 +1,56 @@
+package slack
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Slack escapes &, < and > in message text, and uses <...> for mentions and
+// links: <@U123>, <#C123|general>, <!here>, <https://example.org|label>.
+var (
+	entityPattern = regexp.MustCompile(`<([^<>]*)>`)
+	unescaper     = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+	escaper       = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// PlainText turns message text into what a reader sees, e.g. "<@U123> see
+// <https://example.org|this>" into "@alice see this (https://example.org)".
+// userName looks up mentioned users, it may return "" for unknown ones.
+func PlainText(text string, userName func(id string) string) string {
+	text = entityPattern.ReplaceAllStringFunc(text, func(entity string) string {
+		inner := entity[1 : len(entity)-1]
+		target, label, hasLabel := strings.Cut(inner, "|")
+		switch {
+		case strings.HasPrefix(target, "@"):
+			if hasLabel {
+				return "@" + label
+			}
+			if name := userName(target[1:]); name != "" {
+				return "@" + name
+			}
+			return target
+		case strings.HasPrefix(target, "#"):
+			if hasLabel {
+				return "#" + label
+			}
+			return target
+		case strings.HasPrefix(target, "!"):
+			// Special mentions like <!here> or <!subteam^S123|@team>
+			if hasLabel {
+				return label
+			}
+			command, _, _ := strings.Cut(target[1:], "^")
+			return "@" + command
+		case hasLabel && label != target:
+			return label + " (" + target + ")"
+		default:
+			return target
+		}
+	})
+	return unescaper.Replace(text)
+}
+
+// Escape escapes text so Slack shows it as it is.
+func Escape(text string) string {
+	return escaper.Replace(text)
+}
@@ -0,0 +1,102 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// Envelope is a Socket Mode frame. Every frame with an EnvelopeID has to be
+// acknowledged within 3 seconds or Slack sends it again.
+type Envelope struct {
+	EnvelopeID string          `json:"envelope_id,omitempty"`
+	Type       string          `json:"type"` // "hello", "events_api", "disconnect", ...
+	Reason     string          `json:"reason,omitempty"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+// EventCallback is the payload of an events_api envelope.
+type EventCallback struct {
+	Type  string          `json:"type"` // "event_callback"
+	Event json.RawMessage `json:"event"`
+}
+
+// MessageEvent is a message event, only the fields the bridge uses.
+type MessageEvent struct {
+	Type     string `json:"type"`              // "message"
+	Subtype  string `json:"subtype,omitempty"` // "", "bot_message", "message_changed", ...
+	Channel  string `json:"channel"`
+	User     string `json:"user,omitempty"`
+	BotID    string `json:"bot_id,omitempty"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+}
+
+// SocketMode is one Socket Mode connection. Slack closes them every few hours
+// after a "disconnect" envelope, so callers open a new one when Run returns.
+type SocketMode struct {
+	conn *websocket.Conn
+}
+
+// ConnectSocketMode opens a Socket Mode connection and waits for its hello.
+func (c *Client) ConnectSocketMode(ctx context.Context) (*SocketMode, error) {
+	wsURL, err := c.OpenConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("slack: failed to open Socket Mode connection: %v", err)
+	}
+
+	var hello Envelope
+	if err := conn.ReadJSON(&hello); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("slack: failed to read Socket Mode hello: %v", err)
+	}
+	if hello.Type != "hello" {
+		conn.Close()
+		return nil, fmt.Errorf("slack: expected a Socket Mode hello, got %q", hello.Type)
+	}
+	return &SocketMode{conn: conn}, nil
+}
+
+// Run acknowledges every envelope and passes message events to handler, until
+// Slack asks to reconnect or the connection breaks.
+func (s *SocketMode) Run(handler func(*MessageEvent)) error {
+	for {
+		var envelope Envelope
+		if err := s.conn.ReadJSON(&envelope); err != nil {
+			return err
+		}
+		if envelope.EnvelopeID != "" {
+			if err := s.conn.WriteJSON(map[string]string{"envelope_id": envelope.EnvelopeID}); err != nil {
+				return err
+			}
+		}
+
+		switch envelope.Type {
+		case "disconnect":
+			return fmt.Errorf("slack: Socket Mode connection closed by Slack: %s", envelope.Reason)
+		case "events_api":
+			var callback EventCallback
+			if err := json.Unmarshal(envelope.Payload, &callback); err != nil {
+				fmt.Println("failed to decode Slack event:", err)
+				continue
+			}
+			var event MessageEvent
+			if err := json.Unmarshal(callback.Event, &event); err != nil || event.Type != "message" {
+				continue
+			}
+			handler(&event)
+		}
+	}
+}
+
+// Close closes the connection, making Run return.
+func (s *SocketMode) Close() error {
+	return s.conn.Close()
+}
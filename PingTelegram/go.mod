@@ -1,13 +1,14 @@
-module github.com/kallazz/ping
+module github.com/kallazz/Ping/PingTelegram
 
 go 1.23.0
 
 require (
 	github.com/celestix/gotgproto v1.0.0-beta18
+	github.com/glebarez/sqlite v1.10.0
 	github.com/gotd/td v0.102.0
 	github.com/joho/godotenv v1.5.1
-	google.golang.org/grpc v1.69.2
-	google.golang.org/protobuf v1.35.1
+	github.com/kallazz/Ping/PingBridge v0.0.0
+	gorm.io/gorm v1.25.7-0.20240204074919-46816ad31dde
 )
 
 require (
@@ -15,7 +16,6 @@ require (
 	github.com/cenkalti/backoff/v4 v4.3.0 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/glebarez/go-sqlite v1.21.2 // indirect
-	github.com/glebarez/sqlite v1.10.0 // indirect
 	github.com/go-faster/errors v0.7.1 // indirect
 	github.com/go-faster/jx v1.1.0 // indirect
 	github.com/go-faster/xor v1.0.0 // indirect
@@ -40,7 +40,8 @@ require (
 	golang.org/x/sys v0.26.0 // indirect
 	golang.org/x/text v0.19.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20241015192408-796eee8c2d53 // indirect
-	gorm.io/gorm v1.25.7-0.20240204074919-46816ad31dde // indirect
+	google.golang.org/grpc v1.69.2 // indirect
+	google.golang.org/protobuf v1.36.1 // indirect
 	modernc.org/libc v1.22.5 // indirect
 	modernc.org/mathutil v1.5.0 // indirect
 	modernc.org/memory v1.5.0 // indirect
@@ -48,3 +49,5 @@ require (
 	nhooyr.io/websocket v1.8.11 // indirect
 	rsc.io/qr v0.2.0 // indirect
 )
+
+replace github.com/kallazz/Ping/PingBridge => ../PingBridge
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -8,34 +9,44 @@ import (
 	"syscall"
 
 	"github.com/joho/godotenv"
-	"github.com/kallazz/ping/telegram"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	"github.com/kallazz/Ping/PingTelegram/telegram"
 )
 
 func main() {
 	// Load .env if needed
 	godotenv.Load()
 
+	// "login" signs in once, saves the session and exits
+	if len(os.Args) > 1 && os.Args[1] == "login" {
+		session, err := telegram.Login()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		fmt.Println("Logged in, the session is saved.")
+		fmt.Println("To use it without the session file, set TELEGRAM_STRING_SESSION to:")
+		fmt.Println(session)
+		return
+	}
+
 	// Create the Telegram client
 	client, err := telegram.NewClient()
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 	fmt.Println("Telegram client initialized!")
-	// Start receiving messages from the Ping gRPC server in a separate goroutine
-	go func() {
-		err := telegram.ReceiveMessagesFromPingGRPCServer(client.C)
-		if err != nil {
-			log.Printf("Error in receiving messages from gRPC server: %v\n", err)
-		}
-	}()
 
-	// Now block until the Telegram client stops (Idle()) or user interrupts
-	fmt.Println("Bot is now running. Press CTRL-C to exit.")
-	sigC := make(chan os.Signal, 1)
-	signal.Notify(sigC, syscall.SIGINT, syscall.SIGTERM)
+	// Relay messages to and from the Ping gRPC server
+	runner, err := bridge.NewRunner("Telegram", client)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	runner.HealthAddr = os.Getenv("HEALTH_ADDR")
+	runner.StateFile = os.Getenv("STATE_FILE")
+	if runner.StateFile == "" {
+		runner.StateFile = "telegram.state"
+	}
 
-	// You could either block on signals,
-	// or you can rely on client.Idle() to block:
 	go func() {
 		// Wait for Telegram's Idle to finish
 		if err := client.C.Idle(); err != nil {
@@ -43,8 +54,14 @@ func main() {
 		}
 	}()
 
-	// Wait for termination signal
-	<-sigC
+	// Run until the user interrupts
+	fmt.Println("Bot is now running. Press CTRL-C to exit.")
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go telegram.ReloadRoutes(ctx, runner)
+	if err := runner.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("Error in relaying messages with the gRPC server: %v\n", err)
+	}
 	fmt.Println("Shutting down...")
 
 	// Stop the Telegram client
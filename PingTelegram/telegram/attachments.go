@@ -0,0 +1,233 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+const (
+	// Largest file relayed to Ping, larger ones are only mentioned. It
+	// matches the server's default limit.
+	maxAttachmentSize = 50 << 20
+
+	// Telegram cuts captions after this many characters, longer texts are
+	// sent as a message of their own before the files.
+	maxCaptionLength = 1024
+
+	// Uploading and downloading files takes longer than sending text
+	attachmentTimeout = time.Minute
+)
+
+// SendWithAttachments is Send for messages with files, which are uploaded to
+// Telegram, the first one with the message as its caption.
+func (c *Client) SendWithAttachments(msg *ping.MessageResponse, attachments []bridge.Attachment) ([]string, error) {
+	log.Printf("Received message with %d attachments from PING server: %v\n", len(attachments), msg)
+	return broadcastMessageToTelegram(c.C, msg, attachments)
+}
+
+// fetchAttachment downloads the photo or file of a message. ok is false if the
+// message has none, or one that can't be relayed, like a poll.
+func fetchAttachment(client *gotgproto.Client, msg *tg.Message) (attachment bridge.Attachment, ok bool, err error) {
+	var location tg.InputFileLocationClass
+	switch media := msg.Media.(type) {
+	case *tg.MessageMediaPhoto:
+		photo, isPhoto := media.Photo.(*tg.Photo)
+		if !isPhoto {
+			return attachment, false, nil
+		}
+		location = &tg.InputPhotoFileLocation{
+			ID:            photo.ID,
+			AccessHash:    photo.AccessHash,
+			FileReference: photo.FileReference,
+			ThumbSize:     largestPhotoSize(photo),
+		}
+		attachment.Name = fmt.Sprintf("photo_%d.jpg", photo.ID)
+		attachment.ContentType = "image/jpeg"
+	case *tg.MessageMediaDocument:
+		doc, isDoc := media.Document.(*tg.Document)
+		if !isDoc {
+			return attachment, false, nil
+		}
+		if doc.Size > maxAttachmentSize {
+			return attachment, true, fmt.Errorf("%d bytes is too large", doc.Size)
+		}
+		location = &tg.InputDocumentFileLocation{
+			ID:            doc.ID,
+			AccessHash:    doc.AccessHash,
+			FileReference: doc.FileReference,
+		}
+		attachment.Name = documentName(doc)
+		attachment.ContentType = doc.MimeType
+	default:
+		return attachment, false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), attachmentTimeout)
+	defer cancel()
+	var buf bytes.Buffer
+	if _, err := downloader.NewDownloader().Download(client.API(), location).Stream(ctx, &buf); err != nil {
+		return attachment, true, err
+	}
+	attachment.Data = buf.Bytes()
+	return attachment, true, nil
+}
+
+// largestPhotoSize returns the type of the largest size of photo.
+func largestPhotoSize(photo *tg.Photo) string {
+	best, bestArea := "", -1
+	for _, size := range photo.Sizes {
+		var area int
+		switch s := size.(type) {
+		case *tg.PhotoSize:
+			area = s.W * s.H
+		case *tg.PhotoSizeProgressive:
+			area = s.W * s.H
+		default:
+			continue
+		}
+		if area > bestArea {
+			best, bestArea = size.GetType(), area
+		}
+	}
+	return best
+}
+
+// documentName returns the file name of a document. Voice notes and some
+// other documents have none.
+func documentName(doc *tg.Document) string {
+	for _, attr := range doc.Attributes {
+		if name, ok := attr.(*tg.DocumentAttributeFilename); ok && name.FileName != "" {
+			return name.FileName
+		}
+	}
+	ext := "bin"
+	if _, sub, ok := strings.Cut(doc.MimeType, "/"); ok && sub != "" {
+		ext = sub
+	}
+	for _, attr := range doc.Attributes {
+		if audio, ok := attr.(*tg.DocumentAttributeAudio); ok && audio.Voice {
+			return fmt.Sprintf("voice_%d.%s", doc.ID, ext)
+		}
+	}
+	return fmt.Sprintf("file_%d.%s", doc.ID, ext)
+}
+
+// inboundAttachments downloads the file of an update. Files that can't be
+// downloaded are mentioned in the returned content instead.
+func inboundAttachments(client *gotgproto.Client, update *ext.Update, content string) (string, []bridge.Attachment) {
+	if update.EffectiveMessage.Message == nil || update.EffectiveMessage.IsService {
+		return content, nil
+	}
+	attachment, ok, err := fetchAttachment(client, update.EffectiveMessage.Message)
+	if !ok {
+		return content, nil
+	}
+	if err != nil {
+		fmt.Printf("failed to download Telegram attachment %s: %v\n", attachment.Name, err)
+		return bridge.WithNote(content, attachment.Name), nil
+	}
+	return content, []bridge.Attachment{attachment}
+}
+
+// sendToPeer sends msg with the attachments to a peer and returns the
+// message keys of what was sent. Only the first message sent is a reply and
+// has the text, as a caption if it is short enough. Files that fail to upload
+// are mentioned instead.
+func sendToPeer(client *gotgproto.Client, peer tg.InputPeerClass, msg *ping.MessageResponse, attachments []bridge.Attachment) ([]string, error) {
+	text, replyTo := outboundText(msg, peer)
+	var copies []string
+	if len(attachments) == 0 || len([]rune(text)) > maxCaptionLength {
+		copyID, err := sendTextToPeer(client, peer, text, replyTo)
+		if err != nil {
+			return nil, err
+		}
+		if copyID != "" {
+			copies = append(copies, copyID)
+		}
+		text, replyTo = "", nil
+	}
+	for _, a := range attachments {
+		copyID, err := sendAttachmentToPeer(client, peer, text, replyTo, a)
+		if err != nil {
+			// Don't lose the text with the file
+			fmt.Println(err)
+			copyID, err = sendTextToPeer(client, peer, bridge.WithNote(text, a.Name), replyTo)
+		}
+		if err != nil {
+			return copies, err
+		}
+		if copyID != "" {
+			copies = append(copies, copyID)
+		}
+		text, replyTo = "", nil
+	}
+	return copies, nil
+}
+
+// sendAttachmentToPeer uploads a file and sends it with caption to a peer,
+// like sendTextToPeer. Images are sent as photos, Ogg audio as voice notes
+// and anything else as a file.
+func sendAttachmentToPeer(client *gotgproto.Client, peer tg.InputPeerClass, caption string, replyTo tg.InputReplyToClass, a bridge.Attachment) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), attachmentTimeout)
+	defer cancel()
+
+	file, err := uploader.NewUploader(client.API()).FromBytes(ctx, a.Name, a.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s to Telegram: %v", a.Name, err)
+	}
+
+	var media tg.InputMediaClass
+	switch {
+	case a.ContentType == "image/jpeg" || a.ContentType == "image/png":
+		media = &tg.InputMediaUploadedPhoto{File: file}
+	default:
+		attributes := []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: a.Name}}
+		if a.ContentType == "audio/ogg" {
+			attributes = append(attributes, &tg.DocumentAttributeAudio{Voice: true})
+		}
+		mimeType := a.ContentType
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		media = &tg.InputMediaUploadedDocument{
+			File:       file,
+			MimeType:   mimeType,
+			Attributes: attributes,
+		}
+	}
+
+	log.Printf("Sending %s to %T: %s\n", a.Name, peer, caption)
+	beginSend(peer)
+	updates, err := client.API().MessagesSendMedia(ctx, &tg.MessagesSendMediaRequest{
+		Peer:     peer,
+		Media:    media,
+		Message:  caption,
+		RandomID: rand.Int63(),
+		ReplyTo:  replyTo,
+	})
+	if err != nil {
+		endSend(peer, 0)
+		return "", fmt.Errorf("failed to send %s to Telegram: %v", a.Name, err)
+	}
+
+	msgID, ok := sentMessageID(updates)
+	endSend(peer, msgID)
+	if !ok {
+		return "", nil
+	}
+	return inputPeerKey(peer) + ":" + strconv.Itoa(msgID), nil
+}
@@ -0,0 +1,165 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+	"gorm.io/gorm"
+)
+
+// Telegram reports deleted messages of private chats and basic groups by ID
+// only, those IDs are unique per account. forwarded remembers the message
+// keys of the last maxForwarded such messages sent to Ping, so deletions can
+// be passed on. Channel deletions name their channel.
+const maxForwarded = 10000
+
+var (
+	forwardedMu    sync.Mutex
+	forwarded      = make(map[int]string) // Message ID -> message key
+	forwardedOrder []int
+
+	// The session database, which keeps forwarded across restarts. nil for
+	// string sessions, which keep everything in memory.
+	forwardedDB *gorm.DB
+)
+
+// forwardedMessage is an entry of forwarded in the session database.
+type forwardedMessage struct {
+	MsgID int `gorm:"primaryKey;autoIncrement:false"`
+	Key   string
+}
+
+// loadForwarded keeps forwarded in the session database db and loads what
+// earlier runs saved there. db may be nil.
+func loadForwarded(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	if err := db.AutoMigrate(&forwardedMessage{}); err != nil {
+		return fmt.Errorf("failed to create the forwarded messages table: %v", err)
+	}
+	var saved []forwardedMessage
+	if err := db.Order("msg_id desc").Limit(maxForwarded).Find(&saved).Error; err != nil {
+		return fmt.Errorf("failed to load forwarded messages: %v", err)
+	}
+
+	forwardedMu.Lock()
+	defer forwardedMu.Unlock()
+	for i := len(saved) - 1; i >= 0; i-- {
+		forwarded[saved[i].MsgID] = saved[i].Key
+		forwardedOrder = append(forwardedOrder, saved[i].MsgID)
+	}
+	forwardedDB = db
+	return nil
+}
+
+// rememberForwarded records a message sent to Ping.
+func rememberForwarded(peerType PeerType, peerID int64, msgID int) {
+	if peerType == PeerChannel {
+		return
+	}
+	forwardedMu.Lock()
+	defer forwardedMu.Unlock()
+	if _, ok := forwarded[msgID]; ok {
+		return
+	}
+	key := messageKey(peerType, peerID, msgID)
+	forwarded[msgID] = key
+	forwardedOrder = append(forwardedOrder, msgID)
+	var evicted []int
+	if len(forwardedOrder) > maxForwarded {
+		evicted = append(evicted, forwardedOrder[0])
+		delete(forwarded, forwardedOrder[0])
+		forwardedOrder = forwardedOrder[1:]
+	}
+
+	if forwardedDB == nil {
+		return
+	}
+	if err := forwardedDB.Save(&forwardedMessage{MsgID: msgID, Key: key}).Error; err != nil {
+		fmt.Printf("failed to save forwarded message %s: %v\n", key, err)
+	}
+	if len(evicted) > 0 {
+		if err := forwardedDB.Delete(&forwardedMessage{}, evicted).Error; err != nil {
+			fmt.Printf("failed to forget forwarded messages: %v\n", err)
+		}
+	}
+}
+
+func forwardedKey(msgID int) (string, bool) {
+	forwardedMu.Lock()
+	defer forwardedMu.Unlock()
+	key, ok := forwarded[msgID]
+	return key, ok
+}
+
+// deleteMessages passes deletions of forwarded messages on to Ping. It gets
+// every update, others are ignored.
+func (c *Client) deleteMessages(ctx *ext.Context, update *ext.Update) error {
+	var keys []string
+	switch u := update.UpdateClass.(type) {
+	case *tg.UpdateDeleteChannelMessages:
+		if config := routeConfig.Load(); config != nil && len(config.InboundMappings(peerKey(PeerChannel, u.ChannelID))) == 0 {
+			return nil
+		}
+		for _, msgID := range u.Messages {
+			keys = append(keys, messageKey(PeerChannel, u.ChannelID, msgID))
+		}
+	case *tg.UpdateDeleteMessages:
+		for _, msgID := range u.Messages {
+			if key, ok := forwardedKey(msgID); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		c.inbound(bridge.Message{
+			Event: bridge.EventDelete,
+			ID:    key,
+		})
+	}
+	return nil
+}
+
+// Delete removes a message the bridge posted, copyID is its message key. In
+// channels only admins may delete messages, in groups the bridge may delete
+// its own.
+func (c *Client) Delete(copyID string, msg *ping.MessageResponse) error {
+	key, msgID, err := parseMessageKey(copyID)
+	if err != nil {
+		return err
+	}
+	peer, err := resolvePeer(c.C, key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if channel, ok := peer.(*tg.InputPeerChannel); ok {
+		_, err = c.C.API().ChannelsDeleteMessages(ctx, &tg.ChannelsDeleteMessagesRequest{
+			Channel: &tg.InputChannel{ChannelID: channel.ChannelID, AccessHash: channel.AccessHash},
+			ID:      []int{msgID},
+		})
+	} else {
+		_, err = c.C.API().MessagesDeleteMessages(ctx, &tg.MessagesDeleteMessagesRequest{
+			Revoke: true,
+			ID:     []int{msgID},
+		})
+	}
+	if tgerr.Is(err, "MESSAGE_DELETE_FORBIDDEN", "CHAT_ADMIN_REQUIRED") {
+		return fmt.Errorf("no permission to delete messages in %s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete Telegram message: %v", err)
+	}
+	return nil
+}
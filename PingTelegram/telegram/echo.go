@@ -0,0 +1,106 @@
+package telegram
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+)
+
+// Telegram reports messages a user account sends or edits as outgoing
+// messages, so the bridge would see what it posted itself as new messages.
+// posted counts the echoes still to come of each message key, and sending the
+// sends to each peer whose message ID is not known yet, as their echo may
+// arrive before the send returns. Bots don't get their own messages.
+var (
+	postedMu   sync.Mutex
+	postedCond = sync.NewCond(&postedMu)
+	posted     = make(map[string]int) // Message key -> number of pending echoes
+	sending    = make(map[string]int) // Peer key -> sends without an ID yet
+)
+
+// beginSend records that the bridge is sending a message to peer, see
+// endSend.
+func beginSend(peer tg.InputPeerClass) {
+	if botMode {
+		return
+	}
+	postedMu.Lock()
+	defer postedMu.Unlock()
+	sending[inputPeerKey(peer)]++
+}
+
+// endSend records the ID of the message a send created, 0 if it failed or
+// Telegram didn't say, and wakes up the echoes waiting for it.
+func endSend(peer tg.InputPeerClass, msgID int) {
+	if botMode {
+		return
+	}
+	postedMu.Lock()
+	defer postedMu.Unlock()
+	key := inputPeerKey(peer)
+	if sending[key]--; sending[key] <= 0 {
+		delete(sending, key)
+	}
+	if msgID != 0 {
+		posted[key+":"+strconv.Itoa(msgID)]++
+	}
+	postedCond.Broadcast()
+}
+
+// markEdited records that the bridge is editing message msgID of peer.
+func markEdited(peer tg.InputPeerClass, msgID int) {
+	if botMode {
+		return
+	}
+	postedMu.Lock()
+	defer postedMu.Unlock()
+	posted[inputPeerKey(peer)+":"+strconv.Itoa(msgID)]++
+}
+
+// unmarkEdited forgets an edit that failed.
+func unmarkEdited(peer tg.InputPeerClass, msgID int) {
+	if botMode {
+		return
+	}
+	postedMu.Lock()
+	defer postedMu.Unlock()
+	consumePosted(inputPeerKey(peer) + ":" + strconv.Itoa(msgID))
+}
+
+// isEcho reports whether an outgoing message or edit is one the bridge
+// posted, and forgets it. While the bridge is sending to the same peer it
+// waits for the sends to return, which they do within their timeout.
+func isEcho(update *ext.Update) bool {
+	if botMode || update.EffectiveMessage == nil || !update.EffectiveMessage.Out {
+		return false
+	}
+	peerType, peerID := GetPeer(update)
+	peer := peerKey(peerType, peerID)
+	key := messageKey(peerType, peerID, update.EffectiveMessage.ID)
+
+	postedMu.Lock()
+	defer postedMu.Unlock()
+	for {
+		if consumePosted(key) {
+			return true
+		}
+		if sending[peer] == 0 {
+			return false
+		}
+		postedCond.Wait()
+	}
+}
+
+// consumePosted takes one pending echo of key, if there is one. The caller
+// must hold postedMu.
+func consumePosted(key string) bool {
+	if posted[key] == 0 {
+		return false
+	}
+	if posted[key]--; posted[key] == 0 {
+		delete(posted, key)
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// Messages are identified across the bridge as "type:peer:message", e.g.
+// "channel:1234567890:42", since message IDs are only unique per peer.
+func messageKey(peerType PeerType, peerID int64, msgID int) string {
+	return fmt.Sprintf("%s:%d", peerKey(peerType, peerID), msgID)
+}
+
+// parseMessageKey splits a message key into the key of its peer and the
+// message ID.
+func parseMessageKey(key string) (string, int, error) {
+	i := strings.LastIndexByte(key, ':')
+	if i < 0 {
+		return "", 0, fmt.Errorf("invalid Telegram message ID %q", key)
+	}
+	if _, _, err := parsePeer(key[:i]); err != nil {
+		return "", 0, fmt.Errorf("invalid Telegram message ID %q: %v", key, err)
+	}
+	msgID, err := strconv.Atoi(key[i+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid Telegram message ID %q: %v", key, err)
+	}
+	return key[:i], msgID, nil
+}
+
+// isEdit reports whether the update is an edited message rather than a new
+// one. The message handler gets both.
+func isEdit(update *ext.Update) bool {
+	switch update.UpdateClass.(type) {
+	case *tg.UpdateEditMessage, *tg.UpdateEditChannelMessage:
+		return true
+	}
+	return false
+}
+
+// editMessage passes an edit of a forwarded message on to Ping.
+func (c *Client) editMessage(update *ext.Update) error {
+	peerType, peerID := GetPeer(update)
+	if config := routeConfig.Load(); config != nil && len(config.InboundMappings(peerKey(peerType, peerID))) == 0 {
+		return nil
+	}
+	c.inbound(bridge.Message{
+		Event:   bridge.EventEdit,
+		ID:      messageKey(peerType, peerID, update.EffectiveMessage.ID),
+		Content: update.EffectiveMessage.GetMessage(),
+		Version: strconv.Itoa(update.EffectiveMessage.EditDate),
+	})
+	return nil
+}
+
+// Edit updates a message the bridge posted, copyID is its message key. Of
+// the messages a Ping message with files was posted as, only the one with the
+// text is edited, see sendToPeer.
+func (c *Client) Edit(copyID string, msg *ping.MessageResponse) error {
+	key, msgID, err := parseMessageKey(copyID)
+	if err != nil {
+		return err
+	}
+	peer, err := resolvePeer(c.C, key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	posted, err := postedMessage(ctx, c.C.API(), peer, msgID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Telegram message: %v", err)
+	}
+	if posted == nil {
+		return nil // Deleted on Telegram
+	}
+	text, _ := outboundText(msg, peer)
+	if posted.Media != nil {
+		if posted.Message == "" {
+			return nil // One of the files after the text
+		}
+		if runes := []rune(text); len(runes) > maxCaptionLength {
+			text = string(runes[:maxCaptionLength-1]) + "…"
+		}
+	}
+
+	// A user account gets its own edits back like its own messages
+	markEdited(peer, msgID)
+	_, err = c.C.API().MessagesEditMessage(ctx, &tg.MessagesEditMessageRequest{
+		Peer:    peer,
+		ID:      msgID,
+		Message: text,
+	})
+	if err != nil {
+		unmarkEdited(peer, msgID)
+		return fmt.Errorf("failed to edit Telegram message: %v", err)
+	}
+	return nil
+}
+
+// postedMessage fetches message msgID of peer, or returns nil if there is
+// none.
+func postedMessage(ctx context.Context, api *tg.Client, peer tg.InputPeerClass, msgID int) (*tg.Message, error) {
+	ids := []tg.InputMessageClass{&tg.InputMessageID{ID: msgID}}
+	var result tg.MessagesMessagesClass
+	var err error
+	if channel, ok := peer.(*tg.InputPeerChannel); ok {
+		result, err = api.ChannelsGetMessages(ctx, &tg.ChannelsGetMessagesRequest{
+			Channel: &tg.InputChannel{ChannelID: channel.ChannelID, AccessHash: channel.AccessHash},
+			ID:      ids,
+		})
+	} else {
+		result, err = api.MessagesGetMessages(ctx, ids)
+	}
+	if err != nil {
+		return nil, err
+	}
+	messages, ok := result.AsModified()
+	if !ok {
+		return nil, nil
+	}
+	for _, m := range messages.GetMessages() {
+		if message, ok := m.(*tg.Message); ok && message.ID == msgID {
+			return message, nil
+		}
+	}
+	return nil, nil
+}
+
+// sentMessageID finds the ID of the message a send request created in its
+// result.
+func sentMessageID(updates tg.UpdatesClass) (int, bool) {
+	switch u := updates.(type) {
+	case *tg.UpdateShortSentMessage:
+		return u.ID, true
+	case *tg.Updates:
+		return sentMessageIDIn(u.Updates)
+	case *tg.UpdatesCombined:
+		return sentMessageIDIn(u.Updates)
+	}
+	return 0, false
+}
+
+func sentMessageIDIn(updates []tg.UpdateClass) (int, bool) {
+	for _, update := range updates {
+		switch u := update.(type) {
+		case *tg.UpdateMessageID:
+			return u.ID, true
+		case *tg.UpdateNewMessage:
+			return u.Message.GetID(), true
+		case *tg.UpdateNewChannelMessage:
+			return u.Message.GetID(), true
+		}
+	}
+	return 0, false
+}
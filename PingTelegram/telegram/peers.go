@@ -0,0 +1,174 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/celestix/gotgproto"
+	"github.com/gotd/td/tg"
+	bridge "github.com/kallazz/Ping/PingBridge"
+)
+
+// PeerType is the kind of Telegram peer a mapping points at.
+type PeerType string
+
+const (
+	PeerUser    PeerType = "user"    // Private chat with a user
+	PeerChat    PeerType = "chat"    // Basic group
+	PeerChannel PeerType = "channel" // Channel or supergroup
+)
+
+// telegramPeers are the peers of Telegram mappings, "type:id", e.g.
+//
+//	{"mappings": [
+//	  {"peer": "channel:1234567890", "room": "general", "direction": "both"},
+//	  {"peer": "chat:4567890", "room": "general", "direction": "in"},
+//	  {"peer": "user:7654321", "source": "Discord", "direction": "out"}
+//	]}
+//
+// IDs are the plain MTProto IDs, without the -100 prefix the Bot API puts in
+// front of channel IDs.
+var telegramPeers = bridge.ChannelNames{
+	Key: "peer",
+	Check: func(peer string) error {
+		_, _, err := parsePeer(peer)
+		return err
+	},
+}
+
+// parsePeer splits a peer key, see peerKey, into its type and ID.
+func parsePeer(key string) (PeerType, int64, error) {
+	peerType, id, _ := strings.Cut(key, ":")
+	switch PeerType(peerType) {
+	case PeerUser, PeerChat, PeerChannel:
+	default:
+		return "", 0, fmt.Errorf("peer must be user:, chat: or channel: and an ID, not %q", key)
+	}
+	peerID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || peerID == 0 {
+		return "", 0, fmt.Errorf("invalid ID in peer %q", key)
+	}
+	return PeerType(peerType), peerID, nil
+}
+
+// peerKey identifies a peer in mappings and message keys, e.g.
+// "channel:1234567890".
+func peerKey(peerType PeerType, id int64) string {
+	return fmt.Sprintf("%s:%d", peerType, id)
+}
+
+// inputPeerKey is peerKey for an input peer.
+func inputPeerKey(peer tg.InputPeerClass) string {
+	switch p := peer.(type) {
+	case *tg.InputPeerUser:
+		return peerKey(PeerUser, p.UserID)
+	case *tg.InputPeerChat:
+		return peerKey(PeerChat, p.ChatID)
+	case *tg.InputPeerChannel:
+		return peerKey(PeerChannel, p.ChannelID)
+	}
+	return fmt.Sprintf("%T", peer)
+}
+
+// routeConfig is the mapping loaded from TELEGRAM_ROUTES, swapped atomically
+// on reload. If it is nil, everything goes to the single
+// TELEGRAM_BROADCAST_CHAT_ID channel instead.
+var routeConfig atomic.Pointer[bridge.MappingConfig]
+
+func loadRoutes() (*bridge.MappingConfig, error) {
+	return bridge.LoadMappings(os.Getenv("TELEGRAM_ROUTES"), telegramPeers)
+}
+
+// ReloadRoutes loads TELEGRAM_ROUTES again on every SIGHUP until ctx is done,
+// see bridge.Runner.ReloadMappings. Without TELEGRAM_ROUTES there is nothing
+// to reload.
+func ReloadRoutes(ctx context.Context, runner *bridge.Runner) {
+	if routeConfig.Load() == nil {
+		return
+	}
+	runner.ReloadMappings(ctx, &routeConfig, loadRoutes, nil)
+}
+
+// resolvedPeers caches input peers, users and channels need an access hash.
+var resolvedPeers sync.Map // Peer key -> tg.InputPeerClass
+
+// resolvePeer returns the input peer for a peer key. Peers the client has
+// seen come from its peer storage, the rest are looked up once through the
+// API.
+func resolvePeer(client *gotgproto.Client, key string) (tg.InputPeerClass, error) {
+	if peer, ok := resolvedPeers.Load(key); ok {
+		return peer.(tg.InputPeerClass), nil
+	}
+	peerType, peerID, err := parsePeer(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var peer tg.InputPeerClass
+	switch stored := client.PeerStorage.GetInputPeerById(peerID).(type) {
+	case *tg.InputPeerUser:
+		if peerType == PeerUser {
+			peer = stored
+		}
+	case *tg.InputPeerChat:
+		if peerType == PeerChat {
+			peer = stored
+		}
+	case *tg.InputPeerChannel:
+		if peerType == PeerChannel {
+			peer = stored
+		}
+	}
+
+	if peer == nil {
+		switch peerType {
+		case PeerChat:
+			peer = &tg.InputPeerChat{ChatID: peerID} // Basic groups need no access hash
+		case PeerChannel:
+			var accessHash int64
+			accessHash, err = GetChannelAccessHash(client, peerID)
+			peer = &tg.InputPeerChannel{ChannelID: peerID, AccessHash: accessHash}
+		case PeerUser:
+			var accessHash int64
+			accessHash, err = GetUserAccessHash(client, peerID)
+			peer = &tg.InputPeerUser{UserID: peerID, AccessHash: accessHash}
+		}
+		if err != nil && botMode {
+			// Bots only learn access hashes from updates
+			return nil, fmt.Errorf("failed to resolve %s %d, the bot has to receive a message from it first: %v", peerType, peerID, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s %d: %v", peerType, peerID, err)
+		}
+	}
+
+	resolvedPeers.Store(key, peer)
+	return peer, nil
+}
+
+// GetUserAccessHash looks up a user's access hash. This only works for users
+// the account shares a chat with or has in its contacts.
+func GetUserAccessHash(client *gotgproto.Client, userID int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	users, err := client.API().UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUser{UserID: userID}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get user details: %v", err)
+	}
+	if len(users) == 0 {
+		return 0, errors.New("no user found with the given ID")
+	}
+	user, ok := users[0].(*tg.User)
+	if !ok {
+		return 0, errors.New("user is not available")
+	}
+	return user.AccessHash, nil
+}
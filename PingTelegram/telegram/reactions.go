@@ -0,0 +1,260 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// User accounts get the reaction counts of a message, bots the reactions of
+// single users, after which they fetch the counts. reactionCounts keeps the
+// counts last passed on to Ping for the last maxForwarded messages, so only
+// changes are passed on.
+var (
+	reactionsMu    sync.Mutex
+	reactionCounts = make(map[string]map[string]int) // Message key -> emoji -> count
+	reactionOrder  []string
+
+	// Reactions Telegram offers, see availableReactions
+	availableMu sync.Mutex
+	available   map[string]string
+)
+
+// reactMessages passes reactions to routed messages on to Ping. It gets every
+// update, others are ignored.
+func (c *Client) reactMessages(ctx *ext.Context, update *ext.Update) error {
+	switch u := update.UpdateClass.(type) {
+	case *tg.UpdateMessageReactions:
+		counts := reactionResults(u.Reactions)
+		c.countReactions(u.Peer, u.MsgID, func(map[string]int) map[string]int {
+			return counts
+		})
+	case *tg.UpdateBotMessageReaction:
+		if _, _, ok := routedPeer(u.Peer); !ok {
+			return nil
+		}
+		counts, err := c.fetchReactions(ctx, u.Peer, u.MsgID)
+		if err == nil {
+			c.countReactions(u.Peer, u.MsgID, func(map[string]int) map[string]int {
+				return counts
+			})
+			return nil
+		}
+		// Count the change instead, which is off if the bridge missed some
+		fmt.Printf("failed to fetch reactions of Telegram message %d: %v\n", u.MsgID, err)
+		c.countReactions(u.Peer, u.MsgID, func(counts map[string]int) map[string]int {
+			for _, r := range u.OldReactions {
+				if emoji, ok := reactionEmoji(r); ok && counts[emoji] > 0 {
+					counts[emoji]--
+				}
+			}
+			for _, r := range u.NewReactions {
+				if emoji, ok := reactionEmoji(r); ok {
+					counts[emoji]++
+				}
+			}
+			return counts
+		})
+	}
+	return nil
+}
+
+// reactionResults counts the emoji reactions of a message by their normalized
+// emoji, without the account's own.
+func reactionResults(reactions tg.MessageReactions) map[string]int {
+	counts := make(map[string]int)
+	for _, r := range reactions.Results {
+		emoji, ok := reactionEmoji(r.Reaction)
+		if !ok {
+			continue
+		}
+		counts[emoji] += r.Count
+		// Set for the account's own reaction, which mirrors others
+		if _, ok := r.GetChosenOrder(); ok {
+			counts[emoji]--
+		}
+	}
+	return counts
+}
+
+// fetchReactions asks Telegram for the reaction counts of a message.
+func (c *Client) fetchReactions(ctx context.Context, peer tg.PeerClass, msgID int) (map[string]int, error) {
+	peerType, peerID := peerOf(peer)
+	inputPeer, err := resolvePeer(c.C, peerKey(peerType, peerID))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := c.C.API().MessagesGetMessagesReactions(ctx, &tg.MessagesGetMessagesReactionsRequest{
+		Peer: inputPeer,
+		ID:   []int{msgID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var updates []tg.UpdateClass
+	switch r := result.(type) {
+	case *tg.Updates:
+		updates = r.Updates
+	case *tg.UpdatesCombined:
+		updates = r.Updates
+	}
+	for _, update := range updates {
+		if u, ok := update.(*tg.UpdateMessageReactions); ok && u.MsgID == msgID {
+			return reactionResults(u.Reactions), nil
+		}
+	}
+	// Telegram leaves out messages without reactions
+	return map[string]int{}, nil
+}
+
+// countReactions replaces the reaction counts of a message with what count
+// makes of a copy of the last ones, and passes the changed counts on to Ping.
+func (c *Client) countReactions(peer tg.PeerClass, msgID int, count func(map[string]int) map[string]int) {
+	peerType, peerID, ok := routedPeer(peer)
+	if !ok {
+		return
+	}
+	key := messageKey(peerType, peerID, msgID)
+
+	reactionsMu.Lock()
+	old := reactionCounts[key]
+	counts := make(map[string]int)
+	for emoji, n := range old {
+		counts[emoji] = n
+	}
+	counts = count(counts)
+	if old == nil {
+		reactionOrder = append(reactionOrder, key)
+		if len(reactionOrder) > maxForwarded {
+			delete(reactionCounts, reactionOrder[0])
+			reactionOrder = reactionOrder[1:]
+		}
+	}
+	reactionCounts[key] = counts
+	reactionsMu.Unlock()
+
+	for emoji, n := range counts {
+		if n != old[emoji] {
+			c.inbound(bridge.Message{Event: bridge.EventReaction, ID: key, Emoji: emoji, Count: n})
+		}
+	}
+	for emoji := range old {
+		if _, ok := counts[emoji]; !ok && old[emoji] != 0 {
+			c.inbound(bridge.Message{Event: bridge.EventReaction, ID: key, Emoji: emoji, Count: 0})
+		}
+	}
+}
+
+// routedPeer returns the type and ID of a peer, and whether the mapping has
+// it.
+func routedPeer(peer tg.PeerClass) (PeerType, int64, bool) {
+	peerType, peerID := peerOf(peer)
+	config := routeConfig.Load()
+	if peerType == "" || (config != nil && !config.Mapped(peerKey(peerType, peerID))) {
+		return peerType, peerID, false
+	}
+	return peerType, peerID, true
+}
+
+// reactionEmoji returns the normalized emoji of a reaction. Custom emoji and
+// paid reactions have none.
+func reactionEmoji(reaction tg.ReactionClass) (string, bool) {
+	r, ok := reaction.(*tg.ReactionEmoji)
+	if !ok {
+		return "", false
+	}
+	return bridge.NormalizeEmoji(r.Emoticon), true
+}
+
+// React mirrors the reactions made elsewhere on a message, id is its message
+// key. Telegram allows one reaction per user unless they have Premium, so
+// the bridge reacts with the most common one Telegram offers.
+func (c *Client) React(id string, emoji []string, msg *ping.MessageResponse) error {
+	key, msgID, err := parseMessageKey(id)
+	if err != nil {
+		return err
+	}
+	peer, err := resolvePeer(c.C, key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// No reaction takes the bridge's back
+	var reactions []tg.ReactionClass
+	emoticon, ok := mostCommonReaction(emoji, availableReactions(ctx, c.C.API()))
+	if ok {
+		reactions = append(reactions, &tg.ReactionEmoji{Emoticon: emoticon})
+	}
+
+	fmt.Printf("Setting reaction of Telegram message %s to %q\n", id, emoticon)
+	_, err = c.C.API().MessagesSendReaction(ctx, &tg.MessagesSendReactionRequest{
+		Peer:     peer,
+		MsgID:    msgID,
+		Reaction: reactions,
+	})
+	if tgerr.Is(err, "MESSAGE_ID_INVALID") {
+		return nil // Deleted on Telegram
+	}
+	if tgerr.Is(err, "REACTION_INVALID") {
+		return fmt.Errorf("%s doesn't allow reaction %s", key, emoticon)
+	}
+	if tgerr.Is(err, "CHAT_WRITE_FORBIDDEN", "CHAT_ADMIN_REQUIRED") {
+		return fmt.Errorf("no permission to react in %s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to react to Telegram message: %v", err)
+	}
+	return nil
+}
+
+// mostCommonReaction returns the first of emoji that Telegram offers, spelled
+// the way Telegram does. Without offered reactions it tries the first one.
+func mostCommonReaction(emoji []string, offered map[string]string) (string, bool) {
+	for _, e := range emoji {
+		if offered == nil {
+			return e, true
+		}
+		if emoticon, ok := offered[e]; ok {
+			return emoticon, true
+		}
+	}
+	return "", false
+}
+
+// availableReactions returns the emoji reactions everyone may use, by their
+// normalized emoji, or nil if Telegram didn't tell. They are fetched once.
+func availableReactions(ctx context.Context, api *tg.Client) map[string]string {
+	availableMu.Lock()
+	defer availableMu.Unlock()
+	if available != nil {
+		return available
+	}
+	result, err := api.MessagesGetAvailableReactions(ctx, 0)
+	if err != nil {
+		fmt.Printf("failed to fetch Telegram reactions: %v\n", err)
+		return nil
+	}
+	list, ok := result.(*tg.MessagesAvailableReactions)
+	if !ok {
+		return nil
+	}
+	available = make(map[string]string)
+	for _, r := range list.Reactions {
+		if !r.Inactive && !r.Premium {
+			available[bridge.NormalizeEmoji(r.Reaction)] = r.Reaction
+		}
+	}
+	return available
+}
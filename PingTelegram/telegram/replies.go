@@ -0,0 +1,51 @@
+package telegram
+
+import (
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
+)
+
+// inboundReply returns the message an update replies to, if any. Replies to
+// messages in other chats are left out, the other side couldn't find them.
+func inboundReply(update *ext.Update) *bridge.Reply {
+	header, ok := update.EffectiveMessage.ReplyTo.(*tg.MessageReplyHeader)
+	if !ok {
+		return nil
+	}
+	msgID, ok := header.GetReplyToMsgID()
+	if !ok {
+		return nil
+	}
+	if _, ok := header.GetReplyToPeerID(); ok {
+		return nil
+	}
+	// Messages in forum topics "reply" to the topic unless they reply to a
+	// message in it
+	if _, ok := header.GetReplyToTopID(); header.ForumTopic && !ok {
+		return nil
+	}
+
+	peerType, peerID := GetPeer(update)
+	reply := &bridge.Reply{ID: messageKey(peerType, peerID, msgID)}
+	if quote, ok := header.GetQuoteText(); ok {
+		reply.Excerpt = quote
+	} else if parent := update.EffectiveMessage.ReplyToMessage; parent != nil && parent.Message != nil {
+		reply.Excerpt = parent.Message.Message
+	}
+	return reply
+}
+
+// outboundText renders msg for peer. Replies to a message that is in the chat
+// reply to it natively, the returned reply is nil for others, which quote
+// their parent instead.
+func outboundText(msg *ping.MessageResponse, peer tg.InputPeerClass) (string, tg.InputReplyToClass) {
+	for _, copy := range msg.GetReplyTo().GetCopies() {
+		key, msgID, err := parseMessageKey(copy.GetMessageId())
+		if err == nil && key == inputPeerKey(peer) {
+			return formatMessage(msg), &tg.InputReplyToMessage{ReplyToMsgID: msgID}
+		}
+	}
+	return bridge.Quote(msg) + formatMessage(msg), nil
+}
@@ -0,0 +1,31 @@
+package telegram
+
+import (
+	"os"
+
+	"github.com/celestix/gotgproto/sessionMaker"
+	"github.com/glebarez/sqlite"
+)
+
+// DefaultSessionFile is where the session is kept if neither
+// TELEGRAM_SESSION_FILE nor TELEGRAM_STRING_SESSION is set.
+const DefaultSessionFile = "telegram.session"
+
+// sessionFromEnv picks the session backend:
+//
+//   - TELEGRAM_STRING_SESSION: a string session made by the login subcommand.
+//     Handy for containers, the peer cache is kept in memory.
+//   - TELEGRAM_SESSION_FILE: an SQLite file holding the session and the peer
+//     cache, DefaultSessionFile by default.
+//
+// The second return value is the InMemory client option.
+func sessionFromEnv() (sessionMaker.SessionConstructor, bool) {
+	if value := os.Getenv("TELEGRAM_STRING_SESSION"); value != "" {
+		return sessionMaker.StringSession(value), true
+	}
+	path := os.Getenv("TELEGRAM_SESSION_FILE")
+	if path == "" {
+		path = DefaultSessionFile
+	}
+	return sessionMaker.SqlSession(sqlite.Open(path)), false
+}
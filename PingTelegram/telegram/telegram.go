@@ -8,24 +8,91 @@ import (
 	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/celestix/gotgproto"
 	"github.com/celestix/gotgproto/dispatcher/handlers"
 	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	tgerrors "github.com/celestix/gotgproto/errors"
 	"github.com/celestix/gotgproto/ext"
-	"github.com/celestix/gotgproto/sessionMaker"
 	"github.com/gotd/td/tg"
-	ping "github.com/kallazz/ping/pb"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	bridge "github.com/kallazz/Ping/PingBridge"
+	ping "github.com/kallazz/Ping/PingBridge/pb"
 )
 
+// botMode is set when the bridge logs in with BOT_TOKEN instead of a phone
+// number. Bots cannot look up users or chats they have not seen, and only see
+// group messages with privacy mode off, so a few code paths differ.
+var botMode bool
+
+// Client is the Telegram side of the bridge.
 type Client struct {
-	C *gotgproto.Client
+	C       *gotgproto.Client
+	inbound func(bridge.Message)
 }
 
 func NewClient() (*Client, error) {
+	client, err := newTelegramClient(false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Optional mapping of peers to rooms, see telegramPeers
+	if os.Getenv("TELEGRAM_ROUTES") != "" {
+		config, err := loadRoutes()
+		if err != nil {
+			return nil, err
+		}
+		routeConfig.Store(config)
+		fmt.Printf("Loaded %d Telegram mappings\n", len(config.Mappings))
+	}
+
+	// Deletions of messages sent before a restart need their keys
+	if err := loadForwarded(client.PeerStorage.SqlSession); err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		C: client,
+	}, nil
+}
+
+// Start passes messages, their edits, deletions and reactions to the inbound
+// handler.
+func (c *Client) Start(ctx context.Context) error {
+	clientDispatcher := c.C.Dispatcher
+
+	clientDispatcher.AddHandler(handlers.NewMessage(filters.Message.All, c.sendMessage))
+	clientDispatcher.AddHandler(handlers.NewAnyUpdate(c.deleteMessages))
+	clientDispatcher.AddHandler(handlers.NewAnyUpdate(c.reactMessages))
+	return nil
+}
+
+func (c *Client) OnInbound(handler func(bridge.Message)) {
+	c.inbound = handler
+}
+
+// Send broadcasts a message from the Ping server to Telegram. It returns the
+// message keys of the posted copies.
+func (c *Client) Send(msg *ping.MessageResponse) ([]string, error) {
+	log.Printf("Received message from PING server: %v\n", msg)
+	return broadcastMessageToTelegram(c.C, msg, nil)
+}
+
+// Rooms returns the rooms to receive messages from.
+func (c *Client) Rooms() []string {
+	if config := routeConfig.Load(); config != nil {
+		return config.Rooms()
+	}
+	return nil
+}
+
+// newTelegramClient connects to Telegram using the configured session, see
+// session.go. TELEGRAM_MODE picks a user account ("user", the default) or a
+// bot ("bot", with BOT_TOKEN). Unless interactive is set, a missing or expired
+// user session is an error instead of a prompt for the login code.
+func newTelegramClient(interactive bool) (*gotgproto.Client, error) {
 	appidstring, ok := os.LookupEnv("APPID")
 	if !ok {
 		return nil, errors.New("no APPID Env")
@@ -38,30 +105,67 @@ func NewClient() (*Client, error) {
 	if !ok {
 		return nil, errors.New("no APIHASH Env")
 	}
+	clientType := gotgproto.ClientTypePhone(os.Getenv("PHONE"))
+	switch mode := os.Getenv("TELEGRAM_MODE"); mode {
+	case "", "user":
+	case "bot":
+		token, ok := os.LookupEnv("BOT_TOKEN")
+		if !ok {
+			return nil, errors.New("no BOT_TOKEN Env")
+		}
+		clientType = gotgproto.ClientTypeBot(token)
+		botMode = true
+	default:
+		return nil, fmt.Errorf("TELEGRAM_MODE must be user or bot, not %q", mode)
+	}
+	session, inMemory := sessionFromEnv()
 	client, err := gotgproto.NewClient(
 		appid,
 		apihash,
-		gotgproto.ClientTypePhone(os.Getenv("PHONE")),
+		clientType,
 		&gotgproto.ClientOpts{
-			InMemory: true,
-			Session:  sessionMaker.SimpleSession(),
+			InMemory:   inMemory,
+			Session:    session,
+			NoAutoAuth: !interactive,
+			// Replies quote their parent when the other side can't find it
+			AutoFetchReply: true,
 		},
 	)
+	if errors.Is(err, tgerrors.ErrSessionUnauthorized) {
+		return nil, errors.New("no Telegram session, run the login subcommand first")
+	}
 	if err != nil {
-		return nil, errors.New("failed to create the telegram client.")
+		return nil, fmt.Errorf("failed to create the telegram client: %v", err)
 	}
+	return client, nil
+}
 
-	clientDispatcher := client.Dispatcher
-
-	clientDispatcher.AddHandler(handlers.NewMessage(filters.Message.Text, sendMessage))
-	//fmt.Println(client)
-
-	return &Client{
-		C: client,
-	}, nil
+// Login signs in interactively, saving the session to the session file, and
+// returns it as a string session for TELEGRAM_STRING_SESSION.
+func Login() (string, error) {
+	client, err := newTelegramClient(true)
+	if err != nil {
+		return "", err
+	}
+	defer client.Stop()
+	if botMode {
+		fmt.Println("Bots log in with BOT_TOKEN on start, the login subcommand is not needed.")
+	}
+	return client.ExportStringSession()
 }
 
-func sendMessage(ctx *ext.Context, update *ext.Update) error {
+func (c *Client) sendMessage(ctx *ext.Context, update *ext.Update) error {
+	// Users have to /start a bot before it may message them, keep such
+	// commands out of Ping
+	if botMode && strings.HasPrefix(update.EffectiveMessage.GetMessage(), "/") {
+		return nil
+	}
+	if isEcho(update) {
+		return nil
+	}
+	if isEdit(update) {
+		return c.editMessage(update)
+	}
 	recipients := GetRecipients(update)
 	user, chat, channel := GetSender(update)
 	var senderUsername string
@@ -74,128 +178,120 @@ func sendMessage(ctx *ext.Context, update *ext.Update) error {
 	} else {
 		fmt.Println("Sender could not be determined")
 	}
-	r, err := sendMessageToPingGRPCServer(senderUsername, fmt.Sprintf("%v", recipients), update.EffectiveMessage.GetMessage())
-	if err != nil {
-		return fmt.Errorf("failed to send message: %v", err)
-	}
-	fmt.Printf("Response from PING server: %v\n", r)
-	return nil
-}
 
-func sendMessageToPingGRPCServer(author, recipient, message string) (string, error) {
-    host := os.Getenv("HOST")
-    port := os.Getenv("PORT")
-	address := fmt.Sprintf("%s:%s", host, port)
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return "", fmt.Errorf("failed to connect with server: %v", err)
+	// With a mapping only mapped peers are forwarded, to their rooms
+	peerType, peerID := GetPeer(update)
+	targets := []string{fmt.Sprintf("%v", recipients)}
+	if config := routeConfig.Load(); config != nil {
+		targets = nil
+		for _, mapping := range config.InboundMappings(peerKey(peerType, peerID)) {
+			if mapping.Room != "" {
+				targets = append(targets, mapping.Room)
+			} else {
+				targets = append(targets, fmt.Sprintf("%v", recipients))
+			}
+		}
 	}
-	defer conn.Close()
-	c := ping.NewPingServiceClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	msgRequest := &ping.MessageRequest{}
-	msgRequest.Client = "Telegram"
-	msgRequest.Author = author
-	msgRequest.Recipient = recipient
-	msgRequest.Message = message
-	r, err := c.SendMessage(ctx, msgRequest)
-	// log.Printf("Response from gRPC server's SayHello function: %s", r.GetMessage())
-	return r.GetMessage(), nil
-}
 
-// receiveMessagesFromPingGRPCServer connects to your gRPC server, listens for messages,
-// and broadcasts them to Telegram using the provided gotgproto.Client.
-func ReceiveMessagesFromPingGRPCServer(client *gotgproto.Client) error {
-	fmt.Println("In receive")
-    host := os.Getenv("HOST")
-    port := os.Getenv("PORT")
-	address := fmt.Sprintf("%s:%s", host, port)
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return fmt.Errorf("failed to connect with gRPC server: %v", err)
+	if len(targets) == 0 {
+		return nil
 	}
-	defer conn.Close()
-
-	c := ping.NewPingServiceClient(conn)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	connect_req := &ping.Empty{Client: "TelegramBot"}
-
-	// Start the server-streaming RPC.
-	stream, err := c.ReceiveMessages(ctx, connect_req)
-	if err != nil {
-		return fmt.Errorf("error starting gRPC stream: %v", err)
+	content, attachments := inboundAttachments(c.C, update, update.EffectiveMessage.GetMessage())
+	if content == "" && len(attachments) == 0 {
+		return nil // E.g. a service message, poll or location
 	}
 
-	// Continuously read messages from the stream.
-	for {
-		serverMsg, err := stream.Recv()
-		if err != nil {
-			return fmt.Errorf("error receiving message from gRPC stream: %v", err)
-		}
-
-		log.Printf("Received message from PING server: %v\n", serverMsg)
-
-		// Relay that message to Telegram.
-		// If msg is already a Telegram message, you can skip this step.
-		if serverMsg.GetMessageResponse().GetType() != "Telegram" {
-			if err := broadcastMessageToTelegram(client, serverMsg); err != nil {
-				log.Printf("failed to broadcast message to Telegram: %v\n", err)
-			}
-		}
+	rememberForwarded(peerType, peerID, update.EffectiveMessage.ID)
+	for _, target := range targets {
+		c.inbound(bridge.Message{
+			ID:          messageKey(peerType, peerID, update.EffectiveMessage.ID),
+			Author:      senderUsername,
+			Recipient:   target,
+			Content:     content,
+			Attachments: attachments,
+			ReplyTo:     inboundReply(update),
+		})
 	}
+	return nil
 }
 
-// broadcastMessageToTelegram sends the incoming gRPC ServerMessage to a particular
-// Telegram chat (or multiple chats, if you adapt it). In this example, we pull the
-// chat ID from an environment variable called TELEGRAM_BROADCAST_CHAT_ID.
-func broadcastMessageToTelegram(client *gotgproto.Client, msg *ping.ServerMessage) error {
+// broadcastMessageToTelegram sends the incoming Ping message to every
+// Telegram peer mapped for its room and source. Without a mapping, we pull
+// the chat ID from an environment variable called TELEGRAM_BROADCAST_CHAT_ID.
+// It returns the message keys of the posted copies.
+func broadcastMessageToTelegram(client *gotgproto.Client, msg *ping.MessageResponse, attachments []bridge.Attachment) ([]string, error) {
 	fmt.Println("in broadcast message to telegram")
 
-	// The text you want to send to Telegram.
-	text := fmt.Sprintf("[%s] %s: %s",
-		msg.GetMessageResponse().GetType(),
-		msg.GetMessageResponse().GetSender(),
-		msg.GetMessageResponse().GetContent(),
-	)
+	if config := routeConfig.Load(); config != nil {
+		var copies []string
+		var errs []error
+		for _, key := range config.OutboundChannels(msg.GetRoom(), msg.GetType()) {
+			peer, err := resolvePeer(client, key)
+			if err == nil {
+				var sent []string
+				sent, err = sendToPeer(client, peer, msg, attachments)
+				copies = append(copies, sent...)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", key, err))
+			}
+		}
+		return copies, errors.Join(errs...)
+	}
 
 	// Get the channel ID from environment or config
 	chatIDString, ok := os.LookupEnv("TELEGRAM_BROADCAST_CHAT_ID")
 	if !ok {
-		return fmt.Errorf("environment variable TELEGRAM_BROADCAST_CHAT_ID not set")
+		return nil, fmt.Errorf("environment variable TELEGRAM_BROADCAST_CHAT_ID not set")
 	}
 
 	channelID, err := strconv.ParseInt(chatIDString, 10, 64)
 	if err != nil {
-		return fmt.Errorf("invalid channel ID: %v", err)
+		return nil, fmt.Errorf("invalid channel ID: %v", err)
 	}
 
-	// Fetch the AccessHash for the channel
-	accessHash, err := GetChannelAccessHash(client, channelID)
+	peer, err := resolvePeer(client, peerKey(PeerChannel, channelID))
 	if err != nil {
-		return fmt.Errorf("failed to get access hash: %v", err)
+		return nil, fmt.Errorf("failed to get access hash: %v", err)
 	}
+	return sendToPeer(client, peer, msg, attachments)
+}
+
+// formatMessage renders a Ping message as Telegram text.
+func formatMessage(msg *ping.MessageResponse) string {
+	return fmt.Sprintf("[%s] %s: %s",
+		msg.GetType(),
+		msg.GetSender(),
+		msg.GetContent(),
+	)
+}
 
-	// Use InputPeerChannel to send the message
+// sendTextToPeer sends a plain text message to a user, chat or channel, as a
+// reply if replyTo is not nil. It returns the message key of the sent
+// message, empty if Telegram didn't say.
+func sendTextToPeer(client *gotgproto.Client, peer tg.InputPeerClass, text string, replyTo tg.InputReplyToClass) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	log.Printf("Sending message to channel: %s\n", text)
-	_, err = client.API().MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
-		Peer: &tg.InputPeerChannel{
-			ChannelID:  channelID,
-			AccessHash: accessHash,
-		},
+	log.Printf("Sending message to %T: %s\n", peer, text)
+	beginSend(peer)
+	updates, err := client.API().MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
+		Peer:     peer,
 		Message:  text,
 		RandomID: rand.Int63(),
+		ReplyTo:  replyTo,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to send Telegram message: %v", err)
+		endSend(peer, 0)
+		return "", fmt.Errorf("failed to send Telegram message: %v", err)
 	}
 
-	return nil
+	msgID, ok := sentMessageID(updates)
+	endSend(peer, msgID)
+	if !ok {
+		return "", nil
+	}
+	return inputPeerKey(peer) + ":" + strconv.Itoa(msgID), nil
 }
 
 func printMessageToConsole(ctx *ext.Context, update *ext.Update) error {
@@ -247,6 +343,27 @@ func GetRecipients(u *ext.Update) []string {
 	return recipients
 }
 
+// GetPeer returns the type and ID of the chat the update's message was sent in.
+func GetPeer(u *ext.Update) (PeerType, int64) {
+	if u.EffectiveMessage == nil {
+		return "", 0
+	}
+	return peerOf(u.EffectiveMessage.PeerID)
+}
+
+// peerOf returns the type and ID of a peer.
+func peerOf(peer tg.PeerClass) (PeerType, int64) {
+	switch p := peer.(type) {
+	case *tg.PeerUser:
+		return PeerUser, p.UserID
+	case *tg.PeerChat:
+		return PeerChat, p.ChatID
+	case *tg.PeerChannel:
+		return PeerChannel, p.ChannelID
+	}
+	return "", 0
+}
+
 func GetSender(u *ext.Update) (*tg.User, *tg.Chat, *tg.Channel) {
 	if u.EffectiveMessage == nil || u.Entities == nil {
 		return nil, nil, nil